@@ -18,10 +18,12 @@ type (
 	checkOptions struct {
 		UsageWatermark *uint
 		SrcStorePath   *string
+		Source         *string
 		Locally        *bool
 		Format         string
 		CheckInstall   bool
 		Quick          bool
+		LowStorage     bool
 	}
 
 	CheckAppResult struct {
@@ -46,9 +48,7 @@ type (
 )
 
 const (
-	MinUsageWatermark     = 20
-	MaxUsageWatermark     = 99
-	DefaultUsageWatermark = 95
+	sourceFlagUsage = "A source of app blobs: a path to a store root directory, or `oci:<path>` to an OCI image layout directory"
 )
 
 func init() {
@@ -59,10 +59,11 @@ func init() {
 		Args:  cobra.MinimumNArgs(1),
 	}
 	opts := checkOptions{}
-	opts.UsageWatermark = checkCmd.Flags().UintP("storage-usage-watermark", "u", DefaultUsageWatermark,
-		fmt.Sprintf("The maximum allowed storage usage in percentage in range %d-%d", MinUsageWatermark, MaxUsageWatermark))
+	opts.UsageWatermark = checkCmd.Flags().UintP("storage-usage-watermark", "u", compose.DefaultUsageWatermark,
+		fmt.Sprintf("The maximum allowed storage usage in percentage in range %d-%d", compose.MinUsageWatermark, compose.MaxUsageWatermark))
 	opts.SrcStorePath = checkCmd.Flags().StringP("source-store-path", "l", "",
 		"A path to the source store root directory")
+	opts.Source = checkCmd.Flags().String("source", "", sourceFlagUsage)
 	opts.Locally = checkCmd.Flags().BoolP("local", "", false,
 		"Check whether app is fetched without getting app manifest from registry")
 	checkCmd.Flags().StringVar(&opts.Format, "format", "plain",
@@ -71,6 +72,8 @@ func init() {
 		"Check both whether app is fetched and installed")
 	checkCmd.Flags().BoolVar(&opts.Quick, "quick", false,
 		"Skip checking hash of app blobs; verify only their presence and size")
+	checkCmd.Flags().BoolVar(&opts.LowStorage, "low-storage", false,
+		"Estimate the required storage for the low-storage fetch which discards image layers once images are loaded")
 	checkCmd.Run = func(cmd *cobra.Command, args []string) {
 		if opts.Format != "plain" && opts.Format != "json" {
 			DieNotNil(cmd.Usage())
@@ -85,9 +88,9 @@ func init() {
 }
 
 func checkWatermark(watermark uint) {
-	if watermark < MinUsageWatermark || watermark > MaxUsageWatermark {
+	if watermark < compose.MinUsageWatermark || watermark > compose.MaxUsageWatermark {
 		DieNotNilWithCode(fmt.Errorf("invalid `--storage-usage-watermark` value: %d; should be between %d and %d",
-			watermark, MinUsageWatermark, MaxUsageWatermark), 1, "invalid argument")
+			watermark, compose.MinUsageWatermark, compose.MaxUsageWatermark), 1, "invalid argument")
 	}
 }
 
@@ -97,13 +100,14 @@ func checkAppsCmd(cmd *cobra.Command, args []string, opts *checkOptions) {
 		quietCheck = true
 	}
 
-	blobProvider, cs, err := getAppStoreAndDstBlobProvider(*opts.SrcStorePath, *opts.Locally)
+	source := getBlobSource(*opts.SrcStorePath, *opts.Source)
+	blobProvider, cs, err := getAppStoreAndDstBlobProvider(source, *opts.Locally)
 	DieNotNil(err)
-	if len(*opts.SrcStorePath) == 0 && *opts.Locally {
-		opts.SrcStorePath = &config.StoreRoot
+	if len(source) == 0 && *opts.Locally {
+		source = config.StoreRoot
 	}
 	cr, ui, _, err := checkApps(cmd.Context(), args, blobProvider,
-		*opts.UsageWatermark, *opts.SrcStorePath, quietCheck, opts.Quick)
+		*opts.UsageWatermark, source, quietCheck, opts.Quick, opts.LowStorage)
 	DieNotNil(err, "failed to check apps status")
 
 	var ir InstallCheckResult
@@ -154,9 +158,10 @@ func checkApps(ctx context.Context,
 	appRefs []string,
 	srcBlobProvider compose.BlobProvider,
 	usageWatermark uint,
-	srcStorePath string,
+	source string,
 	quiet bool,
-	quick bool) (*CheckAppResult, *compose.UsageInfo, []compose.App, error) {
+	quick bool,
+	lowStorage bool) (*CheckAppResult, *compose.UsageInfo, []compose.App, error) {
 
 	status, err := compose.CheckAppsStatus(ctx, config, appRefs,
 		compose.WithCheckRunning(false),
@@ -169,8 +174,8 @@ func checkApps(ctx context.Context,
 
 	if !quiet {
 		for _, app := range status.Apps {
-			if len(srcStorePath) > 0 {
-				fmt.Printf("Loaded %s metadata from %s...\n", app.Ref(), srcStorePath)
+			if len(source) > 0 {
+				fmt.Printf("Loaded %s metadata from %s...\n", app.Ref(), source)
 			} else {
 				fmt.Printf("Loaded %s metadata from registry...\n", app.Ref())
 			}
@@ -204,6 +209,10 @@ func checkApps(ctx context.Context,
 		}
 		checkResult.TotalRuntimeSize += bi.RuntimeSize
 	}
+	if lowStorage {
+		// The layers are kept in the store only until their images are loaded into docker
+		checkResult.TotalStoreSize = compose.GetLowStoragePeakStoreSize(status.Apps, status.MissingBlobs)
+	}
 	ui, err := compose.GetUsageInfo(config.StoreRoot,
 		checkResult.TotalStoreSize+checkResult.TotalRuntimeSize, usageWatermark)
 	if err != nil {
@@ -239,7 +248,7 @@ func checkIfInstalled(ctx context.Context, appRefs []string, blobProvider compos
 
 	checkResult := InstallCheckResult{}
 	for _, appRef := range appRefs {
-		app, err := v1.NewAppLoader().LoadAppTree(ctx, blobProvider, platforms.OnlyStrict(config.Platform), appRef)
+		app, err := config.AppLoader.LoadAppTree(ctx, blobProvider, platforms.OnlyStrict(config.Platform), appRef)
 		DieNotNil(err)
 		var missingImages []string
 		appComposeRoot := app.GetComposeRoot()
@@ -260,7 +269,8 @@ func checkIfInstalled(ctx context.Context, appRefs []string, blobProvider compos
 				}
 			}
 		}
-		errMap, err := app.CheckComposeInstallation(ctx, blobProvider, config.GetAppComposeDir(app.Name()))
+		errMap, err := app.CheckComposeInstallation(ctx, blobProvider, config.GetAppComposeDir(app.Name()),
+			compose.WithAllowedExtraFiles(config.AllowedBundleExtraFiles...))
 		if err != nil {
 			return nil, err
 		}
@@ -273,20 +283,31 @@ func checkIfInstalled(ctx context.Context, appRefs []string, blobProvider compos
 	return checkResult, nil
 }
 
-func getAppStoreAndDstBlobProvider(srcStorePath string, local bool) (srcBlobProvider compose.BlobProvider, store compose.AppStore, err error) {
+// getBlobSource returns the source of app blobs specified by either `--source-store-path` or `--source`.
+func getBlobSource(srcStorePath string, source string) string {
+	if len(srcStorePath) > 0 && len(source) > 0 {
+		DieNotNil(fmt.Errorf("`--source-store-path` and `--source` cannot be specified together"))
+	}
+	if len(srcStorePath) > 0 {
+		return srcStorePath
+	}
+	return source
+}
+
+func getAppStoreAndDstBlobProvider(source string, local bool) (srcBlobProvider compose.BlobProvider, store compose.AppStore, err error) {
 	// Create the skopeo store aware instance only if it is a local check
 	store, err = v1.NewAppStore(config.StoreRoot, config.Platform, local)
 	if err != nil {
 		return
 	}
-	if len(srcStorePath) > 0 {
-		srcBlobProvider = compose.NewStoreBlobProvider(compose.GetBlobsRootFor(srcStorePath))
+	if len(source) > 0 {
+		srcBlobProvider, err = compose.NewSourceBlobProvider(source)
 	} else if local {
 		// Use the local store as the source blob provider to check whether app is fetched without a need in connection
 		// to Registry. Requires app manifest and app archive presence in the local store, otherwise fails.
 		srcBlobProvider = store
 	} else {
-		srcBlobProvider = compose.NewRemoteBlobProviderFromConfig(config)
+		srcBlobProvider, err = compose.NewRemoteBlobProviderFromConfig(config)
 	}
 	return
 }
@@ -43,7 +43,9 @@ func doOutputComposeFile(cmd *cobra.Command, args []string, opts *composeOptions
 	if len(*opts.SrcStorePath) > 0 {
 		blobProvider = compose.NewStoreBlobProvider(compose.GetBlobsRootFor(*opts.SrcStorePath))
 	} else {
-		blobProvider = compose.NewRemoteBlobProviderFromConfig(config)
+		var err error
+		blobProvider, err = compose.NewRemoteBlobProviderFromConfig(config)
+		DieNotNil(err)
 	}
 	app, err := v1.NewAppLoader().LoadAppTree(cmd.Context(), blobProvider, platforms.OnlyStrict(config.Platform), args[0])
 	DieNotNil(err)
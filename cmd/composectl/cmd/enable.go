@@ -0,0 +1,38 @@
+package composectl
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	enableCmd := &cobra.Command{
+		Use:   "enable <app name>",
+		Short: "Enable the app, so it is started by app updates and the watchdog",
+		Long: `Enable the app previously disabled on the device. The app is not started by this command,
+it is started by the next app update or by the run command`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			DieNotNil(compose.SetAppEnabled(cmd.Context(), config, args[0], true))
+		},
+	}
+	disableCmd := &cobra.Command{
+		Use:   "disable <app name>",
+		Short: "Disable the app, so it is kept installed but not running",
+		Long: `Disable the app on the device and stop it if it is running. A disabled app is kept installed
+and updated, but it is not started by app updates, the watchdog and the reconciliation, and it is not reported
+as not running. The setting is kept across app updates and reboots`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			DieNotNil(compose.SetAppEnabled(cmd.Context(), config, args[0], false))
+			if _, err := os.Stat(config.GetAppComposeDir(args[0])); err == nil {
+				DieNotNil(compose.StopProject(cmd.Context(), config, args[0]))
+				fmt.Printf("%s has been stopped\n", args[0])
+			}
+		},
+	}
+	rootCmd.AddCommand(enableCmd, disableCmd)
+}
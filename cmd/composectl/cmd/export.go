@@ -0,0 +1,82 @@
+package composectl
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/containerd/containerd/platforms"
+	"github.com/foundriesio/composeapp/pkg/compose"
+	v1 "github.com/foundriesio/composeapp/pkg/compose/v1"
+	"github.com/spf13/cobra"
+)
+
+type (
+	exportOptions struct {
+		Output string
+	}
+)
+
+func init() {
+	exportCmd := &cobra.Command{
+		Use:   "export <app name> | <app URI> [<app name> | <app URI>]... -o <archive file>",
+		Short: "Export pulled apps to an archive",
+		Long: `Export the full trees of the apps pulled to the local store to an archive, so the apps can be
+imported to a store of a device without access to a registry. The apps are exported for the architecture
+specified by the --arch option or for the host architecture`,
+		Args: cobra.MinimumNArgs(1),
+	}
+	opts := exportOptions{}
+	exportCmd.Flags().StringVarP(&opts.Output, "output", "o", "", "path to the archive file to write")
+	_ = exportCmd.MarkFlagRequired("output")
+	exportCmd.Run = func(cmd *cobra.Command, args []string) {
+		exportApps(cmd, args, &opts)
+	}
+	rootCmd.AddCommand(exportCmd)
+
+	importCmd := &cobra.Command{
+		Use:   "import <archive file>",
+		Short: "Import apps from an archive",
+		Long: `Verify the blobs of an archive created by the export command and add them and the archived apps
+to the local store. The imported apps can be installed or used for an update without access to a registry`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			index, err := compose.ImportArchive(cmd.Context(), config, args[0])
+			DieNotNil(err)
+			for _, uri := range index.Apps {
+				fmt.Printf("Imported %s\n", uri)
+			}
+		},
+	}
+	rootCmd.AddCommand(importCmd)
+}
+
+func exportApps(cmd *cobra.Command, args []string, opts *exportOptions) {
+	appURIs := checkUserListedApps(cmd.Context(), config, args, true, true)
+	appStore, err := v1.NewAppStore(config.StoreRoot, config.Platform, false)
+	DieNotNil(err)
+	var apps []compose.App
+	for _, uri := range appURIs {
+		app, err := config.AppLoader.LoadAppTree(cmd.Context(), appStore, platforms.OnlyStrict(config.Platform), uri)
+		DieNotNil(err, "failed to load app tree, make sure the app is pulled")
+		apps = append(apps, app)
+	}
+
+	f, err := os.Create(opts.Output)
+	DieNotNil(err)
+	index, err := compose.ExportApps(cmd.Context(), config, apps, f)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(opts.Output)
+		DieNotNil(err, "failed to export apps")
+	}
+	var size int64
+	for _, desc := range index.Blobs {
+		size += desc.Size
+	}
+	fmt.Printf("Exported %d apps, %d blobs (%s) to %s\n", len(index.Apps), len(index.Blobs),
+		compose.FormatBytesInt64(size), opts.Output)
+}
@@ -42,7 +42,9 @@ func inspectApp(cmd *cobra.Command, args []string, opts *inspectOptions) {
 	if opts.Format == "plain" {
 		fmt.Printf("Inspecting App %s...", appRef)
 	}
-	app, err := v1.NewAppLoader().LoadAppTree(cmd.Context(), compose.NewRemoteBlobProviderFromConfig(config), platforms.All, appRef)
+	blobProvider, err := compose.NewRemoteBlobProviderFromConfig(config)
+	DieNotNil(err)
+	app, err := v1.NewAppLoader().LoadAppTree(cmd.Context(), blobProvider, platforms.All, appRef)
 	DieNotNil(err)
 	if opts.Format == "plain" {
 		fmt.Println("ok")
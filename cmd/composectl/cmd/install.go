@@ -6,18 +6,34 @@ import (
 	"github.com/spf13/cobra"
 )
 
+type (
+	installOptions struct {
+		StrictVars      bool
+		IgnoreConflicts bool
+	}
+)
+
 func init() {
 	installCmd := &cobra.Command{
 		Use:   "install <ref>",
 		Short: "install <ref>",
 		Long:  ``,
 		Args:  cobra.ExactArgs(1),
-		Run:   installApp,
+	}
+	opts := installOptions{}
+	installCmd.Flags().BoolVar(&opts.StrictVars, "strict-vars", false,
+		"fail if the app references device variables that are not defined and have no default value")
+	installCmd.Flags().BoolVar(&opts.IgnoreConflicts, "ignore-conflicts", false,
+		"install the app even if it claims the same host resources as the other installed apps")
+	installCmd.Run = func(cmd *cobra.Command, args []string) {
+		installApp(cmd, args, &opts)
 	}
 	rootCmd.AddCommand(installCmd)
 }
 
-func installApp(cmd *cobra.Command, args []string) {
+func installApp(cmd *cobra.Command, args []string, opts *installOptions) {
 	DieNotNil(compose.Install(cmd.Context(), config, args[0],
-		compose.WithInstallProgress(update.GetInstallProgressPrinter())))
+		compose.WithInstallProgress(update.GetInstallProgressPrinter()),
+		compose.WithIgnoreConflicts(opts.IgnoreConflicts),
+		compose.WithStrictDeviceVars(opts.StrictVars)))
 }
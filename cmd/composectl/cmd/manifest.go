@@ -42,7 +42,9 @@ func doOutputManifest(cmd *cobra.Command, args []string, opts *manifestOptions)
 	if len(*opts.SrcStorePath) > 0 {
 		blobProvider = compose.NewStoreBlobProvider(compose.GetBlobsRootFor(*opts.SrcStorePath))
 	} else {
-		blobProvider = compose.NewRemoteBlobProviderFromConfig(config)
+		var err error
+		blobProvider, err = compose.NewRemoteBlobProviderFromConfig(config)
+		DieNotNil(err)
 	}
 	b, err := compose.ReadBlobWithReadLimit(cmd.Context(), blobProvider, args[0], v1.AppManifestMaxSize)
 	DieNotNil(err)
@@ -0,0 +1,85 @@
+package composectl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/containerd/containerd/platforms"
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	overrideCmd := &cobra.Command{
+		Use:   "override",
+		Short: "Manage device-local compose overrides of apps",
+		Long: `Manage device-local compose overrides of apps. The override is a compose file that is merged with
+the app's compose file when the app is started. It is stored outside the app compose project,
+so it is kept across app updates and is not subject to the app bundle tamper check.
+The override may alter only services defined by the app, and it is validated against each app version
+being installed.`,
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set <app name> <override file | ->",
+		Short: "Set the compose override of the app",
+		Long: `Set the compose override of the app. If the app is in the local store, then the override is validated
+against all its versions found in the store, otherwise it is validated at the app installation`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			setOverride(cmd.Context(), args[0], args[1])
+		},
+	}
+	showCmd := &cobra.Command{
+		Use:   "show <app name>",
+		Short: "Print the compose override of the app",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			override, err := compose.GetComposeOverride(config, args[0])
+			DieNotNil(err)
+			if override == nil {
+				DieNotNil(fmt.Errorf("no compose override is set for app: %s", args[0]))
+			}
+			fmt.Print(string(override))
+		},
+	}
+	rmCmd := &cobra.Command{
+		Use:   "rm <app name>",
+		Short: "Remove the compose override of the app",
+		Long:  `Remove the compose override of the app. The change takes effect on the next app start`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			DieNotNil(compose.RemoveComposeOverride(config, args[0]))
+		},
+	}
+	overrideCmd.AddCommand(setCmd, showCmd, rmCmd)
+	rootCmd.AddCommand(overrideCmd)
+}
+
+func setOverride(ctx context.Context, appName string, overrideFile string) {
+	var override []byte
+	var err error
+	if overrideFile == "-" {
+		override, err = io.ReadAll(io.LimitReader(os.Stdin, compose.AppComposeOverrideMaxSize+1))
+	} else {
+		override, err = os.ReadFile(overrideFile)
+	}
+	DieNotNil(err)
+
+	// Validate the override against all versions of the app found in the local store
+	var apps []compose.App
+	appURIs := checkUserListedApps(ctx, config, []string{appName}, false, true)
+	if len(appURIs) > 0 {
+		appStore, err := config.AppStoreFactory()
+		DieNotNil(err)
+		for _, uri := range appURIs {
+			app, err := config.AppLoader.LoadAppTree(ctx, appStore, platforms.OnlyStrict(config.Platform), uri)
+			DieNotNil(err)
+			apps = append(apps, app)
+		}
+	}
+	DieNotNil(compose.SetComposeOverride(ctx, config, appName, override, apps...))
+	fmt.Printf("Compose override of %s is set, it takes effect on the next app start\n", appName)
+}
@@ -0,0 +1,97 @@
+package composectl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/containerd/containerd/platforms"
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	profilesCmd := &cobra.Command{
+		Use:   "profiles",
+		Short: "Manage compose profiles of apps active on the device",
+		Long: `Manage compose profiles of apps active on the device. Services that belong only to inactive profiles
+are not fetched, installed and started. By default, no profile is active, so only services that do not belong
+to any profile are run. The change takes effect on the next app update, or pull, install and run of the app`,
+	}
+
+	lsCmd := &cobra.Command{
+		Use:   "ls <app name>",
+		Short: "List active and available profiles of the app",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			settings, err := compose.LoadAppSettings(config, args[0])
+			DieNotNil(err)
+			fmt.Printf("active: %s\n", strings.Join(settings.Profiles, ", "))
+			if available := getAvailableProfiles(cmd.Context(), args[0]); available != nil {
+				fmt.Printf("available: %s\n", strings.Join(available, ", "))
+			}
+		},
+	}
+	setCmd := &cobra.Command{
+		Use:   "set <app name> <profile>...",
+		Short: "Set active profiles of the app",
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if available := getAvailableProfiles(cmd.Context(), args[0]); available != nil {
+				for _, profile := range args[1:] {
+					if i := sort.SearchStrings(available, profile); i == len(available) || available[i] != profile {
+						DieNotNil(fmt.Errorf("profile %s is not defined by app %s, available profiles: %s",
+							profile, args[0], strings.Join(available, ", ")))
+					}
+				}
+			}
+			settings, err := compose.LoadAppSettings(config, args[0])
+			DieNotNil(err)
+			settings.Profiles = args[1:]
+			DieNotNil(compose.SaveAppSettings(config, args[0], settings))
+		},
+	}
+	clearCmd := &cobra.Command{
+		Use:   "clear <app name>",
+		Short: "Deactivate all profiles of the app",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			settings, err := compose.LoadAppSettings(config, args[0])
+			DieNotNil(err)
+			settings.Profiles = nil
+			DieNotNil(compose.SaveAppSettings(config, args[0], settings))
+		},
+	}
+	profilesCmd.AddCommand(lsCmd, setCmd, clearCmd)
+	rootCmd.AddCommand(profilesCmd)
+}
+
+// getAvailableProfiles returns sorted profiles defined by all versions of the app found in the local store,
+// or nil if the app is not in the store.
+func getAvailableProfiles(ctx context.Context, appName string) []string {
+	appURIs := checkUserListedApps(ctx, config, []string{appName}, false, true)
+	if len(appURIs) == 0 {
+		return nil
+	}
+	appStore, err := config.AppStoreFactory()
+	DieNotNil(err)
+	profiles := map[string]struct{}{}
+	for _, uri := range appURIs {
+		app, err := config.AppLoader.LoadAppTree(ctx, appStore, platforms.OnlyStrict(config.Platform), uri)
+		DieNotNil(err)
+		project, err := app.GetCompose(ctx, appStore)
+		DieNotNil(err)
+		for _, s := range project.AllServices() {
+			for _, p := range s.Profiles {
+				profiles[p] = struct{}{}
+			}
+		}
+	}
+	available := []string{}
+	for p := range profiles {
+		available = append(available, p)
+	}
+	sort.Strings(available)
+	return available
+}
@@ -86,7 +86,7 @@ func getAppsStatus(ctx context.Context, appRefs []string, runningApps map[string
 			InStore: false,
 			State:   "undefined",
 		}
-		app, err := v1.NewAppLoader().LoadAppTree(ctx, store, platforms.OnlyStrict(config.Platform), appRef)
+		app, err := config.AppLoader.LoadAppTree(ctx, store, platforms.OnlyStrict(config.Platform), appRef)
 		if err == nil {
 			appStatus.Name = app.Name()
 			appStatus.InStore = true
@@ -30,6 +30,8 @@ type (
 		PinnedImageURIs         []string
 		LayersMetaFile          string
 		CreateAppLayersManifest bool
+		PolicyFile              string
+		DependsOn               []string
 	}
 )
 
@@ -40,13 +42,20 @@ func init() {
 		Args:  cobra.RangeArgs(1, 2),
 	}
 	opts := publishOptions{}
-	publishCmd.Flags().StringVarP(&opts.ComposeFile, "file", "f", "docker-compose.yml", "A path to a compose project file")
+	publishCmd.Flags().StringVarP(&opts.ComposeFile, "file", "f", "",
+		"A path to a compose project file; if not specified, then one of "+strings.Join(compose.ComposeFileNames, ", ")+
+			" is looked up in the current directory")
 	publishCmd.Flags().StringVarP(&opts.DigestFile, "digest-file", "d", "", "A file to store the published app sha256 digest to")
 	publishCmd.Flags().BoolVar(&opts.DryRun, "dryrun", false, "Show what would be done, but don't actually publish")
 	publishCmd.Flags().StringSliceVar(&opts.PinnedImageURIs, "pinned-images", nil, "A list of app images referred through digest URIs to pin app to")
 	publishCmd.Flags().StringVarP(&opts.LayersMetaFile, "layers-meta", "l", "", "Json file containing App layers' metadata (size, usage)")
 	publishCmd.Flags().BoolVar(&opts.CreateAppLayersManifest, "layers-manifest", false, "Add app layers manifests to the app manifest")
 
+	publishCmd.Flags().StringVar(&opts.PolicyFile, "policy", "", "A path to a policy file to check the app compose project against")
+
+	publishCmd.Flags().StringSliceVar(&opts.DependsOn, "depends-on", nil,
+		"A list of apps that must be started and healthy before the app is started on a device")
+
 	publishCmd.Run = func(cmd *cobra.Command, args []string) {
 		fmt.Println(banner)
 		appRef, err := compose.ParseAppRef(args[0])
@@ -87,6 +96,13 @@ func publishApp(cmd *cobra.Command, appRef *compose.AppRef, archList []string, o
 		}
 	}
 
+	publishOptions := []v1.PublishOption{v1.WithDependsOn(opts.DependsOn...)}
+	if len(opts.PolicyFile) > 0 {
+		policy, err := compose.LoadPolicy(opts.PolicyFile)
+		DieNotNil(err)
+		publishOptions = append(publishOptions, v1.WithPolicy(policy))
+	}
+
 	DieNotNil(v1.DoPublish(cmd.Context(), appRef.Name, opts.ComposeFile, appRef.String(), opts.DigestFile,
-		opts.DryRun, archList, pinnedImages, opts.LayersMetaFile, opts.CreateAppLayersManifest))
+		opts.DryRun, archList, pinnedImages, opts.LayersMetaFile, opts.CreateAppLayersManifest, publishOptions...))
 }
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/containerd/containerd/platforms"
+	updatectl "github.com/foundriesio/composeapp/cmd/composectl/cmd/update"
 	"github.com/foundriesio/composeapp/pkg/compose"
 	v1 "github.com/foundriesio/composeapp/pkg/compose/v1"
 	"github.com/moby/term"
@@ -18,8 +19,10 @@ type (
 	pullOptions struct {
 		UsageWatermark uint
 		SrcStorePath   string
+		Source         string
 		PrintUsageStat bool
 		Quick          bool
+		updatectl.FetchFlags
 	}
 )
 
@@ -36,11 +39,13 @@ func init() {
 	}
 	opts := pullOptions{}
 
-	pullCmd.Flags().UintVarP(&opts.UsageWatermark, "storage-usage-watermark", "u", DefaultUsageWatermark,
-		fmt.Sprintf("The maximum allowed storage usage in percentage in range %d-%d", MinUsageWatermark, MaxUsageWatermark))
+	pullCmd.Flags().UintVarP(&opts.UsageWatermark, "storage-usage-watermark", "u", compose.DefaultUsageWatermark,
+		fmt.Sprintf("The maximum allowed storage usage in percentage in range %d-%d", compose.MinUsageWatermark, compose.MaxUsageWatermark))
 	pullCmd.Flags().StringVarP(&opts.SrcStorePath, "source-store-path", "l", "", "A path to the source store root directory")
+	pullCmd.Flags().StringVar(&opts.Source, "source", "", sourceFlagUsage)
 	pullCmd.Flags().BoolVarP(&opts.PrintUsageStat, "print-usage-stat", "p", false, "A flag to enable/disable usage statistic output to stderr")
 	pullCmd.Flags().BoolVar(&opts.Quick, "quick", false, "Skip checking hash of app blobs; verify only their presence and size")
+	updatectl.AddFetchFlags(pullCmd, &opts.FetchFlags)
 	pullCmd.Run = func(cmd *cobra.Command, args []string) {
 		checkWatermark(opts.UsageWatermark)
 		pullApps(cmd, args, &opts)
@@ -56,11 +61,12 @@ func pullApps(cmd *cobra.Command, args []string, opts *pullOptions) {
 		fmt.Printf("Pulling %s to %s\n", args[0], config.StoreRoot)
 	}
 
-	srcBlobProvider, cs, err := getAppStoreAndDstBlobProvider(opts.SrcStorePath, false)
+	source := getBlobSource(opts.SrcStorePath, opts.Source)
+	srcBlobProvider, cs, err := getAppStoreAndDstBlobProvider(source, false)
 	DieNotNil(err)
 
 	cr, ui, apps, err := checkApps(cmd.Context(), args, srcBlobProvider, opts.UsageWatermark,
-		opts.SrcStorePath, false, opts.Quick)
+		source, false, opts.Quick, false)
 	DieNotNil(err, "failed to check apps status")
 	if len(cr.MissingBlobs) > 0 {
 		ui.Print()
@@ -75,10 +81,12 @@ func pullApps(cmd *cobra.Command, args []string, opts *pullOptions) {
 		cr.print()
 		fmt.Println("Pulling app blobs, starting at " + time.Now().UTC().Format("15:04:05 02 Jan 2006") + "...")
 
-		err := compose.FetchBlobs(cmd.Context(), config, cr.MissingBlobs,
+		fetchOpts, err := opts.GetFetchOptions()
+		DieNotNil(err)
+		err = compose.FetchBlobs(cmd.Context(), config, cr.MissingBlobs, append(fetchOpts,
 			compose.WithProgressPollInterval(1000),
 			compose.WithFetchProgress(getFetchProgressHandler()),
-			compose.WithSourcePath(opts.SrcStorePath))
+			compose.WithSourcePath(source))...)
 		DieNotNil(err, "failed to fetch blobs")
 		fmt.Println("\n\nApp blobs pull completed at " + time.Now().UTC().Format("15:04:05 02 Jan 2006"))
 	}
@@ -0,0 +1,65 @@
+package composectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/foundriesio/composeapp/pkg/update"
+	"github.com/spf13/cobra"
+)
+
+type (
+	reconcileOptions struct {
+		Boot   bool
+		Format string
+	}
+)
+
+func init() {
+	reconcileCmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Bring apps to the state defined by the update DB",
+		Long: `Make sure that the apps of the last successful update are installed and running and that no other app
+from the compose root is running. If "--boot" is specified, then also resume or roll back an update that
+was interrupted, e.g. by a power loss; it must be specified only if no update client is running, e.g. at boot time`,
+		Args: cobra.NoArgs,
+	}
+	opts := reconcileOptions{}
+	reconcileCmd.Flags().BoolVar(&opts.Boot, "boot", false,
+		"resume or roll back an interrupted update, must be used only at boot time")
+	reconcileCmd.Flags().StringVar(&opts.Format, "format", "table", "Format the output. Values: [table | json]")
+	reconcileCmd.Run = func(cmd *cobra.Command, args []string) {
+		if opts.Format != "table" && opts.Format != "json" {
+			DieNotNil(fmt.Errorf("invalid value of `--format` option: %s", opts.Format))
+		}
+		reconcileApps(cmd, &opts)
+	}
+	rootCmd.AddCommand(reconcileCmd)
+}
+
+func reconcileApps(cmd *cobra.Command, opts *reconcileOptions) {
+	report, err := update.Reconcile(cmd.Context(), config, update.WithCurrentUpdateHandling(opts.Boot))
+	if report != nil {
+		if opts.Format == "json" {
+			b, err := json.MarshalIndent(report, "", "  ")
+			DieNotNil(err)
+			fmt.Println(string(b))
+		} else {
+			if len(report.UpdateID) > 0 {
+				fmt.Printf("Update: %s, state: %s, action: %s\n", report.UpdateID,
+					report.UpdateState.String(), report.UpdateAction)
+			}
+			if len(report.InstalledApps) > 0 {
+				fmt.Printf("Installed apps: %s\n", strings.Join(report.InstalledApps, ", "))
+			}
+			if len(report.StartedApps) > 0 {
+				fmt.Printf("Started apps: %s\n", strings.Join(report.StartedApps, ", "))
+			}
+			if len(report.StoppedProjects) > 0 {
+				fmt.Printf("Stopped apps: %s\n", strings.Join(report.StoppedProjects, ", "))
+			}
+		}
+	}
+	DieNotNil(err)
+}
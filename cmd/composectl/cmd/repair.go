@@ -0,0 +1,70 @@
+package composectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"github.com/spf13/cobra"
+)
+
+type (
+	repairOptions struct {
+		DryRun bool
+		Format string
+	}
+)
+
+func init() {
+	repairCmd := &cobra.Command{
+		Use:   "repair [<app name> | <app URI>]...",
+		Short: "Detect and repair drift of the installed and running apps",
+		Long: `Reinstall tampered app compose projects, reload missing app images and recreate app containers
+which config does not match the app's one. If no app is specified then all apps found in the local store are repaired`,
+		Args: cobra.ArbitraryArgs,
+	}
+	opts := repairOptions{}
+	repairCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Only detect and report the drift, do not repair it")
+	repairCmd.Flags().StringVar(&opts.Format, "format", "table", "Format the output. Values: [table | json]")
+	repairCmd.Run = func(cmd *cobra.Command, args []string) {
+		if opts.Format != "table" && opts.Format != "json" {
+			DieNotNil(fmt.Errorf("invalid value of `--format` option: %s", opts.Format))
+		}
+		repairApps(cmd, args, &opts)
+	}
+	rootCmd.AddCommand(repairCmd)
+}
+
+func repairApps(cmd *cobra.Command, args []string, opts *repairOptions) {
+	appURIs := checkUserListedApps(cmd.Context(), config, args, true)
+	if len(appURIs) == 0 {
+		DieNotNil(fmt.Errorf("no apps to repair are found in the local store"))
+	}
+	report, err := compose.Reconcile(cmd.Context(), config, appURIs, compose.WithReconcileDryRun(opts.DryRun))
+	DieNotNil(err)
+
+	if opts.Format == "json" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		DieNotNil(err)
+		fmt.Println(string(b))
+	} else {
+		for _, app := range report.Apps {
+			if !app.IsDrifted() {
+				fmt.Printf("%s: ok\n", app.Name)
+				continue
+			}
+			fmt.Printf("%s -> %s\n", app.Name, app.URI)
+			for _, action := range app.Actions {
+				fmt.Printf("  - %s: %s", action.Type, strings.Join(action.Targets, ", "))
+				if len(action.Error) > 0 {
+					fmt.Printf("; failed: %s", action.Error)
+				}
+				fmt.Println()
+			}
+		}
+	}
+	if report.Failed() {
+		DieNotNil(fmt.Errorf("failed to repair apps"))
+	}
+}
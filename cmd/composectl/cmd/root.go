@@ -26,6 +26,7 @@ var (
 	overrideConfigDir string
 	storeRoot         string
 	composeRoot       string
+	localRoot         string
 	arch              string
 	dockerHost        string
 	connectTimeout    int
@@ -53,10 +54,11 @@ func Execute() {
 
 func init() {
 	cobra.OnInitialize(initConfig)
-	// The `storeRoot`, `composeRoot`, `defConnectTimeout` can be set at compile time
+	// The `storeRoot`, `composeRoot`, `localRoot`, `defConnectTimeout` can be set at compile time
 	configOpts := []v1.ConfigOpt{
 		v1.WithStoreRoot(storeRoot),
 		v1.WithComposeRoot(composeRoot),
+		v1.WithLocalRoot(localRoot),
 		v1.WithSkopeoSupport(true),
 	}
 	if len(defConnectTimeout) > 0 {
@@ -70,6 +72,7 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVarP(&storeRoot, "store", "s", config.StoreRoot, "store root path")
 	rootCmd.PersistentFlags().StringVarP(&composeRoot, "compose", "i", config.ComposeRoot, "compose projects root path")
+	rootCmd.PersistentFlags().StringVarP(&localRoot, "local-root", "", config.LocalRoot, "device-local app data root path")
 	rootCmd.PersistentFlags().StringVarP(&arch, "arch", "a", "", "architecture of app/images to pull")
 	rootCmd.PersistentFlags().StringVarP(&dockerHost, "host", "H", "", "path to the socket on which the Docker daemon listens")
 	rootCmd.PersistentFlags().IntVarP(&connectTimeout, "connect-timeout", "", int(config.ConnectTimeout.Seconds()),
@@ -88,6 +91,7 @@ func initConfig() {
 	// override the default config
 	config.StoreRoot = storeRoot
 	config.ComposeRoot = composeRoot
+	config.LocalRoot = localRoot
 	config.ConnectTimeout = time.Duration(connectTimeout) * time.Second
 	config.ReadTimeout = time.Duration(readTimeout) * time.Second
 	config.DockerCfg = cfg
@@ -54,6 +54,8 @@ func runApps(cmd *cobra.Command, opts *runOptions) {
 				fmt.Printf("%s has been successfully started\n", app.Name())
 			case compose.AppStartStatusFailed:
 				fmt.Printf("failed to start %s\n", app.Name())
+			case compose.AppStartStatusDisabled:
+				fmt.Printf("%s is disabled, skipping it\n", app.Name())
 			}
 		})))
 }
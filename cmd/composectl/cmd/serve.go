@@ -0,0 +1,94 @@
+package composectl
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"github.com/spf13/cobra"
+)
+
+type (
+	serveOptions struct {
+		Listen   string
+		TLSCert  string
+		TLSKey   string
+		ClientCA string
+	}
+)
+
+func init() {
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the app store as a read-only registry",
+		Long: `Serve blobs of the app store over the read-only OCI distribution API, so other devices can use
+the store as a registry mirror. Blobs and manifests are served by digest under any repository name`,
+		Args: cobra.NoArgs,
+	}
+	opts := serveOptions{}
+	serveCmd.Flags().StringVar(&opts.Listen, "listen", ":5000", "address to listen on")
+	serveCmd.Flags().StringVar(&opts.TLSCert, "tls-cert", "", "path to the server TLS certificate, enables HTTPS")
+	serveCmd.Flags().StringVar(&opts.TLSKey, "tls-key", "", "path to the server TLS private key")
+	serveCmd.Flags().StringVar(&opts.ClientCA, "client-ca", "",
+		"path to the CA certificates to verify client certificates by, enables mutual TLS")
+	serveCmd.Run = func(cmd *cobra.Command, args []string) {
+		serveStore(cmd, &opts)
+	}
+	rootCmd.AddCommand(serveCmd)
+}
+
+func serveStore(cmd *cobra.Command, opts *serveOptions) {
+	if (len(opts.TLSCert) > 0) != (len(opts.TLSKey) > 0) {
+		DieNotNil(fmt.Errorf("both `--tls-cert` and `--tls-key` must be specified to enable HTTPS"))
+	}
+	if len(opts.ClientCA) > 0 && len(opts.TLSCert) == 0 {
+		DieNotNil(fmt.Errorf("mutual TLS requires HTTPS, specify `--tls-cert` and `--tls-key`"))
+	}
+
+	server := &http.Server{
+		Addr:              opts.Listen,
+		Handler:           compose.NewStoreRegistryHandler(config.GetBlobsRoot()),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	if len(opts.ClientCA) > 0 {
+		pem, err := os.ReadFile(opts.ClientCA)
+		DieNotNil(err, "failed to read client CA")
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			DieNotNil(fmt.Errorf("no valid certificates found in %s", opts.ClientCA))
+		}
+		server.TLSConfig = &tls.Config{
+			ClientCAs:  clientCAs,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelShutdown()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Serving %s on %s\n", config.StoreRoot, opts.Listen)
+	var err error
+	if len(opts.TLSCert) > 0 {
+		err = server.ListenAndServeTLS(opts.TLSCert, opts.TLSKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		DieNotNil(err)
+	}
+}
@@ -2,11 +2,10 @@ package composectl
 
 import (
 	"fmt"
+	"github.com/foundriesio/composeapp/pkg/compose"
 	"github.com/spf13/cobra"
-	"io/fs"
 	"os"
 	"os/exec"
-	"path/filepath"
 )
 
 type (
@@ -39,7 +38,7 @@ func stopApps(args []string, opts *stopOptions) {
 		DieNotNil(fmt.Errorf("either `--all` flag or app name should be specified"))
 	}
 
-	appsToStop, err := getAllAppsToStop(config.ComposeRoot)
+	appsToStop, err := compose.ListComposeProjects(config)
 	DieNotNil(err)
 
 	if len(args) > 0 && !opts.All {
@@ -66,15 +65,3 @@ func stopApps(args []string, opts *stopOptions) {
 		DieNotNil(cmd.Run())
 	}
 }
-
-func getAllAppsToStop(composeRoot string) ([]string, error) {
-	var apps []string
-	err := filepath.Walk(composeRoot, func(path string, info fs.FileInfo, err error) error {
-		if !info.IsDir() || path == config.ComposeRoot {
-			return nil
-		}
-		apps = append(apps, info.Name())
-		return nil
-	})
-	return apps, err
-}
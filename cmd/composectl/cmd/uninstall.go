@@ -1,6 +1,9 @@
 package composectl
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/foundriesio/composeapp/pkg/compose"
 	"github.com/spf13/cobra"
 )
@@ -9,6 +12,7 @@ type (
 	uninstallOptions struct {
 		ignoreNonInstalled bool
 		prune              bool
+		volumes            bool
 	}
 )
 
@@ -23,6 +27,8 @@ func init() {
 	uninstallCmd.Flags().BoolVar(&opts.ignoreNonInstalled, "ignore-non-installed", false,
 		"Do not yield error if app installation is not found")
 	uninstallCmd.Flags().BoolVar(&opts.prune, "prune", false, "prune unused images in the docker store")
+	uninstallCmd.Flags().BoolVar(&opts.volumes, "volumes", false,
+		"remove named volumes of the apps too, the app data stored in them is lost")
 	uninstallCmd.Run = func(cmd *cobra.Command, args []string) {
 		uninstallApps(cmd, args, &opts)
 	}
@@ -35,5 +41,20 @@ func uninstallApps(cmd *cobra.Command, args []string, opts *uninstallOptions) {
 	if opts.prune {
 		pruneType = compose.PruneTypeAllUnusedImages
 	}
-	DieNotNil(compose.UninstallApps(cmd.Context(), config, appURIs, compose.WithImagePruning(pruneType)))
+	report := compose.UninstallReport{}
+	err := compose.UninstallApps(cmd.Context(), config, appURIs, compose.WithImagePruning(pruneType),
+		compose.WithVolumeRemoval(opts.volumes), compose.WithUninstallReport(report))
+	for appName, removed := range report {
+		fmt.Printf("%s:\n", appName)
+		printRemovedResources("containers", removed.Containers)
+		printRemovedResources("networks", removed.Networks)
+		printRemovedResources("volumes", removed.Volumes)
+	}
+	DieNotNil(err)
+}
+
+func printRemovedResources(resourceType string, names []string) {
+	if len(names) > 0 {
+		fmt.Printf("  removed %s: %s\n", resourceType, strings.Join(names, ", "))
+	}
 }
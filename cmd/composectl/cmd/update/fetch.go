@@ -1,6 +1,10 @@
 package updatectl
 
 import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/go-units"
 	"github.com/foundriesio/composeapp/pkg/compose"
 	v1 "github.com/foundriesio/composeapp/pkg/compose/v1"
 	"github.com/foundriesio/composeapp/pkg/update"
@@ -8,7 +12,19 @@ import (
 )
 
 type (
+	// FetchFlags are the flags tuning the fetch of app blobs, they are shared by all commands fetching apps
+	FetchFlags struct {
+		Concurrency  int
+		MaxRate      string
+		RateSchedule []string
+		Retries      int
+		ChunkedSize  string
+		Chunks       int
+	}
+
 	fetchOptions struct {
+		FetchFlags
+		Source string
 	}
 )
 
@@ -20,6 +36,7 @@ func init() {
 	}
 
 	opts := fetchOptions{}
+	addFetchFlags(fetchCmd, &opts)
 
 	fetchCmd.Run = func(cmd *cobra.Command, args []string) {
 		fetchUpdateCmd(cmd, args, &opts)
@@ -28,6 +45,62 @@ func init() {
 	UpdateCmd.AddCommand(fetchCmd)
 }
 
+// AddFetchFlags registers the flags tuning the fetch of app blobs, so all commands fetching apps accept the same ones.
+func AddFetchFlags(cmd *cobra.Command, opts *FetchFlags) {
+	cmd.Flags().IntVar(&opts.Concurrency, "concurrency", 1, "A maximum number of blobs fetched in parallel")
+	cmd.Flags().StringVar(&opts.MaxRate, "max-rate", "", "A maximum overall fetch rate in bytes per second, e.g. 512KiB or 2MiB")
+	cmd.Flags().StringSliceVar(&opts.RateSchedule, "rate-schedule", nil,
+		"A maximum fetch rate during the time of a day in the HH:MM-HH:MM=<rate> format, e.g. 08:00-18:00=256KiB")
+	cmd.Flags().IntVar(&opts.Retries, "retry-attempts", compose.DefaultFetchRetryAttempts,
+		"A maximum number of attempts to fetch a single blob")
+	cmd.Flags().StringVar(&opts.ChunkedSize, "chunked-threshold", "",
+		"A minimum size of a blob, e.g. 256MiB, to fetch it by concurrent range requests")
+	cmd.Flags().IntVar(&opts.Chunks, "chunks", compose.DefaultFetchChunks,
+		"A number of concurrent range requests to fetch a blob which size exceeds the chunked fetch threshold")
+}
+
+// GetFetchOptions returns the fetch options set by the flags.
+func (f *FetchFlags) GetFetchOptions() ([]compose.FetchOption, error) {
+	rateLimiter, err := compose.ParseRateLimiter(f.MaxRate, f.RateSchedule)
+	if err != nil {
+		return nil, err
+	}
+	var chunkedThreshold int64
+	if len(f.ChunkedSize) > 0 {
+		if chunkedThreshold, err = units.RAMInBytes(f.ChunkedSize); err != nil {
+			return nil, err
+		}
+	}
+	return []compose.FetchOption{
+		compose.WithFetchConcurrency(f.Concurrency),
+		compose.WithRateLimiter(rateLimiter),
+		compose.WithFetchRetry(f.Retries, compose.DefaultFetchRetryBackoff),
+		compose.WithChunkedFetch(chunkedThreshold, f.Chunks),
+	}, nil
+}
+
+func addFetchFlags(cmd *cobra.Command, opts *fetchOptions) {
+	AddFetchFlags(cmd, &opts.FetchFlags)
+	cmd.Flags().StringVar(&opts.Source, "source", "",
+		"A source of app blobs instead of registry: a path to a store root directory, or `oci:<path>` to an OCI image layout directory")
+}
+
+func getFetchOptions(updateCtl update.Runner, opts *fetchOptions) []compose.FetchOption {
+	fetchOpts, err := opts.GetFetchOptions()
+	ExitIfNotNil(err)
+	fetchOpts = append(fetchOpts, compose.WithProgressPollInterval(500))
+	if len(opts.Source) > 0 {
+		// Check the source before starting the fetch, so an invalid source does not mark the update as failed
+		_, err := compose.NewSourceBlobProvider(opts.Source)
+		ExitIfNotNil(err)
+		fetchOpts = append(fetchOpts, compose.WithSourcePath(opts.Source))
+	}
+	if len(updateCtl.Status().URIs) > 0 {
+		fetchOpts = append(fetchOpts, compose.WithFetchProgress(update.GetFetchProgressPrinter()))
+	}
+	return fetchOpts
+}
+
 func fetchUpdateCmd(cmd *cobra.Command, args []string, opts *fetchOptions) {
 	cfg, err := v1.NewDefaultConfig()
 	ExitIfNotNil(err)
@@ -35,11 +108,13 @@ func fetchUpdateCmd(cmd *cobra.Command, args []string, opts *fetchOptions) {
 	updateCtl, err := update.GetCurrentUpdate(cfg)
 	ExitIfNotNil(err)
 
-	fetchOpts := []compose.FetchOption{
-		compose.WithProgressPollInterval(500),
-	}
-	if len(updateCtl.Status().URIs) > 0 {
-		fetchOpts = append(fetchOpts, compose.WithFetchProgress(update.GetFetchProgressPrinter()))
+	exitIfFetchPaused(updateCtl.Fetch(cmd.Context(), getFetchOptions(updateCtl, opts)...))
+}
+
+func exitIfFetchPaused(err error) {
+	if errors.Is(err, update.ErrUpdatePaused) {
+		fmt.Println("\nThe update fetch is paused, run `composectl update resume` to continue")
+		return
 	}
-	ExitIfNotNil(updateCtl.Fetch(cmd.Context(), fetchOpts...))
+	ExitIfNotNil(err)
 }
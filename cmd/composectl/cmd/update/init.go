@@ -2,6 +2,7 @@ package updatectl
 
 import (
 	"fmt"
+	"github.com/docker/go-units"
 	"github.com/foundriesio/composeapp/pkg/compose"
 	v1 "github.com/foundriesio/composeapp/pkg/compose/v1"
 	"github.com/foundriesio/composeapp/pkg/update"
@@ -12,6 +13,10 @@ type (
 	initOptions struct {
 		UpdateRef         string
 		AllowEmptyAppList bool // Allow empty app list to initialize the new update, which means update to the "no apps" state, hence removing all current apps.
+		VolumeSnapshots   string
+		FromArchive       string
+		LowStorage        bool
+		UsageWatermark    uint
 	}
 )
 
@@ -34,6 +39,16 @@ func init() {
 		"Update reference/ID to associate the update with.")
 	initCmd.Flags().BoolVarP(&opts.AllowEmptyAppList, "allow-empty-app-list", "r", false,
 		"Initialize the update to the \"no apps\" state")
+	initCmd.Flags().StringVar(&opts.VolumeSnapshots, "snapshot-volumes", "",
+		"Snapshot named volumes of the changed apps before the installation, limiting the total snapshot size, e.g. 512MiB")
+
+	initCmd.Flags().StringVar(&opts.FromArchive, "from-archive", "",
+		"Import the app archive created by the export command and initialize the update for its apps, unless apps are specified")
+
+	initCmd.Flags().BoolVar(&opts.LowStorage, "low-storage", false,
+		"Load each image into docker right after fetching it and discard its layers from the store to reduce storage usage")
+	initCmd.Flags().UintVarP(&opts.UsageWatermark, "storage-usage-watermark", "u", compose.DefaultUsageWatermark,
+		fmt.Sprintf("The maximum allowed storage usage in percentage in range %d-%d", compose.MinUsageWatermark, compose.MaxUsageWatermark))
 
 	initCmd.Run = func(cmd *cobra.Command, args []string) {
 		initUpdateCmd(cmd, args, &opts)
@@ -43,12 +58,22 @@ func init() {
 }
 
 func initUpdateCmd(cmd *cobra.Command, args []string, opts *initOptions) {
+	if opts.UsageWatermark < compose.MinUsageWatermark || opts.UsageWatermark > compose.MaxUsageWatermark {
+		ExitIfNotNil(fmt.Errorf("invalid `--storage-usage-watermark` value: %d; should be between %d and %d",
+			opts.UsageWatermark, compose.MinUsageWatermark, compose.MaxUsageWatermark))
+	}
 	cfg, err := v1.NewDefaultConfig()
 	ExitIfNotNil(err)
 
 	var updateCtl update.Runner
 	var renderProgress bool
 
+	if len(opts.FromArchive) > 0 && len(args) == 0 {
+		index, err := compose.ReadArchiveIndex(opts.FromArchive)
+		ExitIfNotNil(err)
+		args = index.Apps
+	}
+
 	if len(args) > 0 || opts.AllowEmptyAppList {
 		updateCtl, err = update.NewUpdate(cfg, opts.UpdateRef)
 	} else {
@@ -66,6 +91,17 @@ func initUpdateCmd(cmd *cobra.Command, args []string, opts *initOptions) {
 		update.WithInitAllowEmptyAppList(opts.AllowEmptyAppList),
 		update.WithInitCheckStatus(true),
 	}
+	if len(opts.FromArchive) > 0 {
+		initOpts = append(initOpts, update.WithInitFromArchive(opts.FromArchive))
+	}
+	if opts.LowStorage {
+		initOpts = append(initOpts, update.WithInitLowStorage(true))
+	}
+	if len(opts.VolumeSnapshots) > 0 {
+		maxSize, err := units.RAMInBytes(opts.VolumeSnapshots)
+		ExitIfNotNil(err)
+		initOpts = append(initOpts, update.WithInitVolumeSnapshots(maxSize))
+	}
 	if renderProgress {
 		initOpts = append(initOpts, update.WithInitProgress(update.GetInitProgressPrinter()))
 	}
@@ -76,4 +112,12 @@ func initUpdateCmd(cmd *cobra.Command, args []string, opts *initOptions) {
 	if len(us.URIs) > 0 {
 		fmt.Printf("Diff summary:\t\t\t\t  %d blobs (%s) to fetch\n", len(us.Blobs), compose.FormatBytesInt64(us.TotalBlobsBytes))
 	}
+	if len(us.Blobs) > 0 {
+		ui, err := us.GetUsageInfo(cfg, opts.UsageWatermark)
+		ExitIfNotNil(err)
+		ui.Print()
+		if ui.Required > ui.Available {
+			fmt.Println("Warning: not enough storage available to fetch and install the update")
+		}
+	}
 }
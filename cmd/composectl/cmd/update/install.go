@@ -8,7 +8,10 @@ import (
 )
 
 type (
-	installOptions struct{}
+	installOptions struct {
+		IgnoreConflicts bool
+		StrictVars      bool
+	}
 )
 
 func init() {
@@ -20,6 +23,11 @@ func init() {
 
 	opts := installOptions{}
 
+	installCmd.Flags().BoolVar(&opts.IgnoreConflicts, "ignore-conflicts", false,
+		"Install the apps even if they claim the same host resources")
+	installCmd.Flags().BoolVar(&opts.StrictVars, "strict-vars", false,
+		"Fail if the apps reference device variables that are not defined and have no default value")
+
 	installCmd.Run = func(cmd *cobra.Command, args []string) {
 		installUpdateCmd(cmd, args, &opts)
 	}
@@ -34,6 +42,8 @@ func installUpdateCmd(cmd *cobra.Command, args []string, opts *installOptions) {
 	updateCtl, err := update.GetCurrentUpdate(cfg)
 	ExitIfNotNil(err)
 
-	err = updateCtl.Install(cmd.Context(), compose.WithInstallProgress(update.GetInstallProgressPrinter()))
+	err = updateCtl.Install(cmd.Context(), compose.WithInstallProgress(update.GetInstallProgressPrinter()),
+		compose.WithIgnoreConflicts(opts.IgnoreConflicts),
+		compose.WithStrictDeviceVars(opts.StrictVars))
 	ExitIfNotNil(err)
 }
@@ -0,0 +1,44 @@
+package updatectl
+
+import (
+	"fmt"
+
+	v1 "github.com/foundriesio/composeapp/pkg/compose/v1"
+	"github.com/foundriesio/composeapp/pkg/update"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	pauseCmd := &cobra.Command{
+		Use:   "pause",
+		Short: "Pause fetching of the current update",
+		Long: `Pause fetching of the current update. An ongoing fetch is stopped and the partially fetched blobs
+are kept, so the fetch continues from where it stopped once resumed. A paused update is not fetched
+until the resume command is run. Only an update that is initialized or being fetched can be paused.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := v1.NewDefaultConfig()
+			ExitIfNotNil(err)
+			ExitIfNotNil(update.PauseCurrentUpdate(cmd.Context(), cfg))
+			fmt.Println("The update fetch is paused")
+		},
+	}
+
+	opts := fetchOptions{}
+	resumeCmd := &cobra.Command{
+		Use:   "resume",
+		Short: "Resume fetching of the paused update",
+		Long:  `Resume fetching of the paused update from where it was stopped.`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := v1.NewDefaultConfig()
+			ExitIfNotNil(err)
+			updateCtl, err := update.GetCurrentUpdate(cfg)
+			ExitIfNotNil(err)
+			exitIfFetchPaused(updateCtl.Resume(cmd.Context(), getFetchOptions(updateCtl, &opts)...))
+		},
+	}
+	addFetchFlags(resumeCmd, &opts)
+
+	UpdateCmd.AddCommand(pauseCmd, resumeCmd)
+}
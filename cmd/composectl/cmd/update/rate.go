@@ -0,0 +1,43 @@
+package updatectl
+
+import (
+	"fmt"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+	v1 "github.com/foundriesio/composeapp/pkg/compose/v1"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var maxRate string
+	var rateSchedule []string
+	rateCmd := &cobra.Command{
+		Use:   "rate",
+		Short: "Set the rate limit of the ongoing and following fetches",
+		Long: `Set the rate limit of the ongoing and following fetches of apps, it overrides the limit set by
+the fetch flags. An ongoing fetch applies the limit within a second. If no limit is specified,
+the limit set by this command is reset, so the fetches are limited according to their flags.`,
+		Example: `
+	# Limit the fetch rate to 256KiB per second during working hours, and to 2MiB per second otherwise:
+	composectl update rate --max-rate 2MiB --rate-schedule 08:00-18:00=256KiB
+
+	# Reset the rate limit:
+	composectl update rate`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := v1.NewDefaultConfig()
+			ExitIfNotNil(err)
+			ExitIfNotNil(compose.SetFetchRate(cfg, maxRate, rateSchedule))
+			if len(maxRate) == 0 && len(rateSchedule) == 0 {
+				fmt.Println("The fetch rate limit is reset")
+			} else {
+				fmt.Println("The fetch rate limit is set")
+			}
+		},
+	}
+	rateCmd.Flags().StringVar(&maxRate, "max-rate", "", "A maximum overall fetch rate in bytes per second, e.g. 512KiB or 2MiB")
+	rateCmd.Flags().StringSliceVar(&rateSchedule, "rate-schedule", nil,
+		"A maximum fetch rate during the time of a day in the HH:MM-HH:MM=<rate> format, e.g. 08:00-18:00=256KiB")
+
+	UpdateCmd.AddCommand(rateCmd)
+}
@@ -45,6 +45,8 @@ func runUpdateCmd(cmd *cobra.Command, args []string, opts *runOptions) {
 				fmt.Println("done")
 			case compose.AppStartStatusFailed:
 				fmt.Println("failed")
+			case compose.AppStartStatusDisabled:
+				fmt.Printf("\tskipping %s, it is disabled\n", app.Name())
 			}
 		})))
 }
@@ -55,7 +55,14 @@ func updateStatusCmd(cmd *cobra.Command, args []string, opts *statusOptions) {
 		cmd.Printf("Client Ref: \t%s\n", u.ClientRef)
 	}
 	cmd.Printf("Date: \t\t%s\n", u.CreationTime.String())
-	cmd.Printf("State: \t\t%s\n", u.State)
+	if u.State.IsOneOf(update.StateInitialized, update.StateFetching) && (u.Paused || update.IsFetchPaused(cfg)) {
+		cmd.Printf("State: \t\t%s (paused)\n", u.State)
+	} else {
+		cmd.Printf("State: \t\t%s\n", u.State)
+	}
+	if u.LowStorage {
+		cmd.Printf("Fetch Mode: \tlow-storage (%d images loaded)\n", len(u.LoadedImages))
+	}
 	cmd.Printf("Fetch Size: \t%s\n", compose.FormatBytesInt64(u.TotalBlobsBytes))
 	cmd.Printf("Blobs Number: \t%d\n", len(u.Blobs))
 	cmd.Printf("Progress: \t%d%%\n", u.Progress)
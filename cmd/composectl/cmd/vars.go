@@ -0,0 +1,78 @@
+package composectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	varsCmd := &cobra.Command{
+		Use:   "vars",
+		Short: "Manage device variables",
+		Long: `Manage device variables that are used to render templated values of app compose projects,
+e.g. ${DEVICE_SERIAL}. The variables referenced by an app are rendered at the app installation,
+so the change of the variables takes effect on the next app installation.
+If the strict mode is enabled (--strict-vars or ` + compose.EnvStrictDeviceVars + `=1), then the app installation fails
+if the app references variables that are not defined and have no default value`,
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set <name>=<value>...",
+		Short: "Set device variables",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			vars := map[string]string{}
+			for _, arg := range args {
+				name, value, found := strings.Cut(arg, "=")
+				if !found {
+					DieNotNil(fmt.Errorf("invalid device variable, expected <name>=<value>: %s", arg))
+				}
+				vars[name] = value
+			}
+			DieNotNil(compose.SetDeviceVars(config, vars))
+		},
+	}
+	var format string
+	lsCmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List device variables",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if format != "table" && format != "json" {
+				DieNotNil(fmt.Errorf("invalid value of `--format` option: %s", format))
+			}
+			vars, err := compose.LoadDeviceVars(config)
+			DieNotNil(err)
+			if format == "json" {
+				b, err := json.MarshalIndent(vars, "", "  ")
+				DieNotNil(err)
+				fmt.Println(string(b))
+				return
+			}
+			var names []string
+			for name := range vars {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("%s=%s\n", name, vars[name])
+			}
+		},
+	}
+	lsCmd.Flags().StringVar(&format, "format", "table", "Format the output. Values: [table | json]")
+	unsetCmd := &cobra.Command{
+		Use:   "unset <name>...",
+		Short: "Remove device variables",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			DieNotNil(compose.UnsetDeviceVars(config, args...))
+		},
+	}
+	varsCmd.AddCommand(setCmd, lsCmd, unsetCmd)
+	rootCmd.AddCommand(varsCmd)
+}
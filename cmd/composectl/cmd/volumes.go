@@ -0,0 +1,33 @@
+package composectl
+
+import (
+	"fmt"
+
+	"github.com/foundriesio/composeapp/pkg/update"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	volumesCmd := &cobra.Command{
+		Use:   "volumes",
+		Short: "Manage app volumes",
+	}
+
+	restoreCmd := &cobra.Command{
+		Use:   "restore <app name> <update ID>",
+		Short: "Restore app volumes from the snapshots taken by the given update",
+		Long: `Restore named volumes of the app from the snapshots taken before installation of the given update.
+The snapshots are taken only if the update is initialized with the --snapshot-volumes option.
+Containers using the volumes are stopped and left stopped, start the app once its volumes are restored`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			volumes, err := update.RestoreAppVolumes(cmd.Context(), config, args[0], args[1])
+			DieNotNil(err)
+			for _, v := range volumes {
+				fmt.Printf("restored volume %s\n", v)
+			}
+		},
+	}
+	volumesCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(volumesCmd)
+}
@@ -0,0 +1,92 @@
+package composectl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"github.com/foundriesio/composeapp/pkg/update"
+	"github.com/spf13/cobra"
+)
+
+type (
+	watchdogOptions struct {
+		Interval         int
+		RestartUnhealthy bool
+		RecreateMissing  bool
+		MaxFailures      int
+		StopOnEscalation bool
+		Rollback         bool
+	}
+)
+
+func init() {
+	watchdogCmd := &cobra.Command{
+		Use:   "watchdog [<app name> | <app URI>]...",
+		Short: "Watch the running apps and remediate their failures",
+		Long: `Periodically check the running status of the specified apps, or of all installed apps if none is specified,
+restart unhealthy services, recreate missing containers, and escalate if an app fails to recover`,
+		Args: cobra.ArbitraryArgs,
+	}
+	opts := watchdogOptions{}
+	watchdogCmd.Flags().IntVar(&opts.Interval, "interval", int(compose.DefaultWatchdogInterval.Seconds()),
+		"interval in seconds between app status checks")
+	watchdogCmd.Flags().BoolVar(&opts.RestartUnhealthy, "restart-unhealthy", true,
+		"restart unhealthy services")
+	watchdogCmd.Flags().BoolVar(&opts.RecreateMissing, "recreate-missing", true,
+		"recreate missing service containers")
+	watchdogCmd.Flags().IntVar(&opts.MaxFailures, "max-failures", compose.DefaultWatchdogMaxFailures,
+		"number of consecutive failed checks after which the watchdog gives up on an app; 0 - never give up")
+	watchdogCmd.Flags().BoolVar(&opts.StopOnEscalation, "stop", true,
+		"stop an app the watchdog gave up on")
+	watchdogCmd.Flags().BoolVar(&opts.Rollback, "rollback", false,
+		"roll back an app the watchdog gave up on to its version delivered by the last successful update")
+	watchdogCmd.Run = func(cmd *cobra.Command, args []string) {
+		if opts.Interval <= 0 {
+			DieNotNil(fmt.Errorf("invalid value of `--interval` option: %d", opts.Interval))
+		}
+		runWatchdog(cmd, args, &opts)
+	}
+	rootCmd.AddCommand(watchdogCmd)
+}
+
+func runWatchdog(cmd *cobra.Command, args []string, opts *watchdogOptions) {
+	var appURIs []string
+	if len(args) > 0 {
+		appURIs = checkUserListedApps(cmd.Context(), config, args, true)
+	}
+
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	watchdogOpts := []compose.WatchdogOption{
+		compose.WithWatchdogInterval(time.Duration(opts.Interval) * time.Second),
+		compose.WithUnhealthyServiceRestart(opts.RestartUnhealthy),
+		compose.WithMissingServiceRecreation(opts.RecreateMissing),
+		compose.WithEscalation(opts.MaxFailures, opts.StopOnEscalation),
+		compose.WithWatchdogEventHandler(func(event *compose.WatchdogEvent) {
+			log.Println(event)
+		}),
+	}
+	if opts.Rollback {
+		watchdogOpts = append(watchdogOpts, compose.WithWatchdogEscalationHandler(
+			func(ctx context.Context, app compose.App) error {
+				rolledBackTo, err := update.RollbackApp(ctx, config, app)
+				if err == nil {
+					log.Printf("%s has been rolled back to %s\n", app.Name(), rolledBackTo)
+				}
+				return err
+			}))
+	}
+
+	err := compose.RunWatchdog(ctx, config, appURIs, watchdogOpts...)
+	if !errors.Is(err, context.Canceled) {
+		DieNotNil(err)
+	}
+}
@@ -82,6 +82,16 @@ func (mb *ManifestBuilder) References() []distribution.Descriptor {
 	return mb.manifest.Layers
 }
 
+func (mb *ManifestBuilder) SetAnnotation(key, value string) {
+	// Copy the annotations, so the template ones are not modified
+	annotations := map[string]string{}
+	for k, v := range mb.manifest.Annotations {
+		annotations[k] = v
+	}
+	annotations[key] = value
+	mb.manifest.Annotations = annotations
+}
+
 func (mb *ManifestBuilder) SetLayerMetaManifests(manifests []distribution.Descriptor)  {
 	mb.manifest.Manifests = manifests
 }
@@ -28,15 +28,24 @@ type (
 		Tree() *AppTree
 		NodeCount() int
 		Ref() *AppRef
+		// Annotations returns annotations of the app manifest
+		Annotations() map[string]string
 		HasLayersMeta(arch string) bool
 		GetBlobRuntimeSize(desc *ocispec.Descriptor, arch string, blockSize int64) int64
 		GetComposeRoot() *TreeNode
 		GetCompose(ctx context.Context, provider BlobProvider) (*composetypes.Project, error)
-		CheckComposeInstallation(ctx context.Context, provider BlobProvider, installationRootDir string) (AppBundleErrs, error)
+		CheckComposeInstallation(ctx context.Context, provider BlobProvider, installationRootDir string, options ...CheckComposeOption) (AppBundleErrs, error)
 	}
 	AppLoader interface {
 		LoadAppTree(context.Context, BlobProvider, platforms.MatchComparer, string) (App, error)
 	}
+
+	CheckComposeOptions struct {
+		// Patterns of files (see `path.Match`) that are allowed to be in the app project directory
+		// in addition to the app bundle files.
+		AllowedExtraFiles []string
+	}
+	CheckComposeOption func(*CheckComposeOptions)
 )
 
 const (
@@ -49,6 +58,12 @@ var (
 	ErrAppIndexNotFound = errors.New("app blob index is not found")
 )
 
+func WithAllowedExtraFiles(patterns ...string) CheckComposeOption {
+	return func(o *CheckComposeOptions) {
+		o.AllowedExtraFiles = append(o.AllowedExtraFiles, patterns...)
+	}
+}
+
 func WithAppRef(ctx context.Context, ref *AppRef) context.Context {
 	return context.WithValue(ctx, ctxKeyAppRef, ref)
 }
@@ -0,0 +1,226 @@
+package compose
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/containerd/containerd/content/local"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type (
+	// ArchiveIndex describes the content of an app archive. The archive is a tar file which holds the index file
+	// followed by the blobs of the app trees laid out as in an app store, i.e. `blobs/sha256/<hash>`.
+	ArchiveIndex struct {
+		Version int                  `json:"version"`
+		Apps    []string             `json:"apps"`
+		Blobs   []ocispec.Descriptor `json:"blobs"`
+	}
+)
+
+const (
+	ArchiveIndexFile    = "index.json"
+	ArchiveIndexVersion = 1
+
+	maxArchiveIndexSize = 16 * 1024 * 1024
+)
+
+var (
+	ErrInvalidArchive = errors.New("invalid app archive")
+)
+
+// ExportApps writes the archive holding the full trees of the given apps which blobs are read from the local store.
+func ExportApps(ctx context.Context, cfg *Config, apps []App, w io.Writer) (*ArchiveIndex, error) {
+	index := &ArchiveIndex{Version: ArchiveIndexVersion}
+	blobs := map[digest.Digest]ocispec.Descriptor{}
+	for _, app := range apps {
+		index.Apps = append(index.Apps, app.Ref().String())
+		if err := app.Tree().Walk(func(node *TreeNode, depth int) error {
+			blobs[node.Descriptor.Digest] = ocispec.Descriptor{
+				MediaType: node.Descriptor.MediaType,
+				Digest:    node.Descriptor.Digest,
+				Size:      node.Descriptor.Size,
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for _, desc := range blobs {
+		index.Blobs = append(index.Blobs, desc)
+	}
+	sort.Slice(index.Blobs, func(i, j int) bool {
+		return index.Blobs[i].Digest < index.Blobs[j].Digest
+	})
+
+	tw := tar.NewWriter(w)
+	indexBytes, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	modTime := time.Now()
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     ArchiveIndexFile,
+		Mode:     0644,
+		Size:     int64(len(indexBytes)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(indexBytes); err != nil {
+		return nil, err
+	}
+	for _, desc := range index.Blobs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := exportBlob(tw, cfg.GetBlobsRoot(), desc, modTime); err != nil {
+			return nil, err
+		}
+	}
+	return index, tw.Close()
+}
+
+func exportBlob(tw *tar.Writer, blobsRoot string, desc ocispec.Descriptor, modTime time.Time) error {
+	f, err := os.Open(filepath.Join(blobsRoot, desc.Digest.Encoded()))
+	if err != nil {
+		return fmt.Errorf("failed to open blob %s, make sure the app is pulled: %w", desc.Digest, err)
+	}
+	defer f.Close()
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     getArchiveBlobPath(desc.Digest),
+		Mode:     0644,
+		Size:     desc.Size,
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	if n, err := io.Copy(tw, io.LimitReader(f, desc.Size)); err != nil {
+		return fmt.Errorf("failed to export blob %s: %w", desc.Digest, err)
+	} else if n != desc.Size {
+		return fmt.Errorf("failed to export blob %s: size mismatch, expected %d, got %d", desc.Digest, desc.Size, n)
+	}
+	return nil
+}
+
+func getArchiveBlobPath(d digest.Digest) string {
+	return path.Join("blobs", d.Algorithm().String(), d.Encoded())
+}
+
+// ReadArchiveIndex reads the index of the given app archive.
+func ReadArchiveIndex(archivePath string) (*ArchiveIndex, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readArchiveIndex(tar.NewReader(f))
+}
+
+func readArchiveIndex(tr *tar.Reader) (*ArchiveIndex, error) {
+	h, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read index: %s", ErrInvalidArchive, err.Error())
+	}
+	if h.Name != ArchiveIndexFile || h.Size > maxArchiveIndexSize {
+		return nil, fmt.Errorf("%w: the archive must start with %s", ErrInvalidArchive, ArchiveIndexFile)
+	}
+	index := &ArchiveIndex{}
+	if err := json.NewDecoder(tr).Decode(index); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse index: %s", ErrInvalidArchive, err.Error())
+	}
+	if index.Version != ArchiveIndexVersion {
+		return nil, fmt.Errorf("%w: unsupported version: %d", ErrInvalidArchive, index.Version)
+	}
+	for _, uri := range index.Apps {
+		if _, err := ParseAppRef(uri); err != nil {
+			return nil, fmt.Errorf("%w: invalid app URI: %s", ErrInvalidArchive, err.Error())
+		}
+	}
+	for _, desc := range index.Blobs {
+		if err := desc.Digest.Validate(); err != nil || desc.Size < 0 {
+			return nil, fmt.Errorf("%w: invalid blob descriptor: %s", ErrInvalidArchive, desc.Digest)
+		}
+	}
+	return index, nil
+}
+
+// ImportArchive verifies the blobs of the given app archive and adds them and the archive apps to the local store.
+// The blobs that are already in the store are skipped.
+func ImportArchive(ctx context.Context, cfg *Config, archivePath string) (*ArchiveIndex, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	index, err := readArchiveIndex(tr)
+	if err != nil {
+		return nil, err
+	}
+	ls, err := local.NewStore(cfg.StoreRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := map[string]ocispec.Descriptor{}
+	for _, desc := range index.Blobs {
+		blobs[getArchiveBlobPath(desc.Digest)] = desc
+	}
+	imported := map[digest.Digest]bool{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArchive, err.Error())
+		}
+		desc, ok := blobs[h.Name]
+		if !ok || h.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: unexpected archive entry: %s", ErrInvalidArchive, h.Name)
+		}
+		if h.Size != desc.Size {
+			return nil, fmt.Errorf("%w: size of %s does not match the index, expected %d, got %d",
+				ErrInvalidArchive, h.Name, desc.Size, h.Size)
+		}
+		if _, err := ls.Info(ctx, desc.Digest); err == nil {
+			imported[desc.Digest] = true
+			continue
+		} else if !errdefs.IsNotFound(err) {
+			return nil, err
+		}
+		// The store writer verifies the blob size and digest
+		if err := CopyBlob(ctx, io.NopCloser(tr), "import-"+desc.Digest.String(), desc, ls, true); err != nil {
+			return nil, fmt.Errorf("failed to import blob %s: %w", desc.Digest, err)
+		}
+		imported[desc.Digest] = true
+	}
+	for _, desc := range index.Blobs {
+		if !imported[desc.Digest] {
+			return nil, fmt.Errorf("%w: blob %s listed in the index is missing", ErrInvalidArchive, desc.Digest)
+		}
+	}
+
+	appStore, err := cfg.AppStoreFactory()
+	if err != nil {
+		return nil, err
+	}
+	if err := appStore.AddApps(index.Apps); err != nil {
+		return nil, fmt.Errorf("failed to add imported apps to the store: %w", err)
+	}
+	return index, nil
+}
@@ -0,0 +1,106 @@
+package compose
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type archiveTestStore struct {
+	AppStore
+	apps []string
+}
+
+func (s *archiveTestStore) AddApps(appURIs []string) error {
+	s.apps = append(s.apps, appURIs...)
+	return nil
+}
+
+func TestArchive(t *testing.T) {
+	srcCfg := &Config{StoreRoot: t.TempDir()}
+	if err := os.MkdirAll(srcCfg.GetBlobsRoot(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeBlob := func(data string, blobType BlobType, children ...*TreeNode) *TreeNode {
+		d := digest.FromString(data)
+		if err := os.WriteFile(filepath.Join(srcCfg.GetBlobsRoot(), d.Encoded()), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return &TreeNode{
+			Descriptor: &ocispec.Descriptor{Digest: d, Size: int64(len(data))},
+			Type:       blobType,
+			Children:   children,
+		}
+	}
+	layer := writeBlob("shared layer", BlobTypeImageLayer)
+	newApp := func(name string) App {
+		root := writeBlob(name+" manifest", BlobTypeAppManifest,
+			writeBlob(name+" bundle", BlobTypeAppBundle),
+			writeBlob(name+" image manifest", BlobTypeImageManifest, layer))
+		ref, err := ParseAppRef("hub.foundries.io/factory/" + name + "@" + root.Descriptor.Digest.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree := AppTree(*root)
+		return &testApp{name: name, ref: ref, tree: &tree}
+	}
+	apps := []App{newApp("app1"), newApp("app2")}
+
+	archivePath := filepath.Join(t.TempDir(), "apps.tar")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := ExportApps(context.Background(), srcCfg, apps, f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Blobs) != 7 {
+		t.Errorf("expected 7 unique blobs exported, got %d", len(index.Blobs))
+	}
+
+	store := &archiveTestStore{}
+	dstCfg := &Config{StoreRoot: t.TempDir(), AppStoreFactoryFunc: func(c *Config) (AppStore, error) { return store, nil }}
+	imported, err := ImportArchive(context.Background(), dstCfg, archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedApps := []string{apps[0].Ref().String(), apps[1].Ref().String()}
+	if !reflect.DeepEqual(imported.Apps, expectedApps) || !reflect.DeepEqual(store.apps, expectedApps) {
+		t.Errorf("unexpected imported apps: %v, added to store: %v", imported.Apps, store.apps)
+	}
+	for _, desc := range index.Blobs {
+		if _, err := os.Stat(filepath.Join(dstCfg.GetBlobsRoot(), desc.Digest.Encoded())); err != nil {
+			t.Errorf("blob %s is not imported: %s", desc.Digest, err)
+		}
+	}
+
+	// An archive with a blob not matching its digest is rejected
+	var tampered bytes.Buffer
+	tw := tar.NewWriter(&tampered)
+	indexBytes := []byte(`{"version":1,"apps":[],"blobs":[{"digest":"` + digest.FromString("data").String() + `","size":4}]}`)
+	_ = tw.WriteHeader(&tar.Header{Name: ArchiveIndexFile, Mode: 0644, Size: int64(len(indexBytes))})
+	_, _ = tw.Write(indexBytes)
+	_ = tw.WriteHeader(&tar.Header{Name: getArchiveBlobPath(digest.FromString("data")), Mode: 0644, Size: 4})
+	_, _ = tw.Write([]byte("date"))
+	_ = tw.Close()
+	tamperedPath := filepath.Join(t.TempDir(), "tampered.tar")
+	if err := os.WriteFile(tamperedPath, tampered.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportArchive(context.Background(), dstCfg, tamperedPath); err == nil {
+		t.Error("expected error for a blob not matching its digest")
+	}
+	if _, err := ReadArchiveIndex(filepath.Join(srcCfg.GetBlobsRoot(), layer.Descriptor.Digest.Encoded())); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("expected invalid archive error, got: %v", err)
+	}
+}
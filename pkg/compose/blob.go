@@ -35,6 +35,8 @@ const (
 	BlobMissing
 	BlobSizeInvalid
 	BlobDigestInvalid
+	// The blob was discarded from the store after loading it into docker, see GetLoadedLayers
+	BlobLoaded
 
 	BlobTypeUnknown          BlobType = "unknown blob type"
 	BlobTypeAppManifest      BlobType = "app manifest"
@@ -85,6 +87,8 @@ func (s BlobState) String() string {
 		ret = "invalid size"
 	case BlobDigestInvalid:
 		ret = "invalid hash"
+	case BlobLoaded:
+		ret = "loaded"
 	default:
 		ret = "undefined"
 	}
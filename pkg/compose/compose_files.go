@@ -0,0 +1,200 @@
+package compose
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/compose-spec/compose-go/loader"
+	"github.com/docker/docker/pkg/archive"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// AppEnvFile is the name of the app bundle file that defines default values of the compose project variables
+	AppEnvFile = ".env"
+)
+
+var (
+	// ComposeFileNames are names of the compose project file in the order of precedence, the same as `docker compose` uses
+	ComposeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+	ErrComposeFileNotFound = errors.New("compose file is not found")
+)
+
+// FindComposeFile returns the name of the compose project file found by the given function in the order of precedence.
+func FindComposeFile(exists func(fileName string) bool) (string, error) {
+	for _, fileName := range ComposeFileNames {
+		if exists(fileName) {
+			return fileName, nil
+		}
+	}
+	return "", fmt.Errorf("%w, expected one of: %s", ErrComposeFileNotFound, strings.Join(ComposeFileNames, ", "))
+}
+
+// FindComposeFileInDir returns the name of the compose project file found in the given directory.
+func FindComposeFileInDir(dir string) (string, error) {
+	return FindComposeFile(func(fileName string) bool {
+		fi, err := os.Stat(filepath.Join(dir, fileName))
+		return err == nil && fi.Mode().IsRegular()
+	})
+}
+
+// GetComposeFiles returns paths of all files that define the compose project, i.e. the main compose file and
+// the files referenced by it through `include` and `extends`, recursively. The paths are relative to the directory
+// of the main compose file, referencing files outside this directory or remote resources is not supported.
+func GetComposeFiles(mainFile string, readFile func(filePath string) ([]byte, error)) ([]string, error) {
+	var files []string
+	visited := map[string]struct{}{}
+
+	var walk func(filePath string, workingDir string) error
+	walk = func(filePath string, workingDir string) error {
+		if _, ok := visited[filePath]; ok {
+			return nil
+		}
+		visited[filePath] = struct{}{}
+		files = append(files, filePath)
+
+		b, err := readFile(filePath)
+		if err != nil {
+			return err
+		}
+		dict, err := loader.ParseYAML(b)
+		if err != nil {
+			return fmt.Errorf("failed to parse compose file %s: %w", filePath, err)
+		}
+
+		if include, ok := dict["include"].([]interface{}); ok {
+			for _, item := range include {
+				var paths []string
+				var projectDir string
+				switch v := item.(type) {
+				case string:
+					paths = []string{v}
+				case map[string]interface{}:
+					switch p := v["path"].(type) {
+					case string:
+						paths = []string{p}
+					case []interface{}:
+						for _, pp := range p {
+							if s, ok := pp.(string); ok {
+								paths = append(paths, s)
+							}
+						}
+					}
+					projectDir, _ = v["project_directory"].(string)
+				}
+				if len(paths) == 0 {
+					return fmt.Errorf("invalid `include` in compose file %s", filePath)
+				}
+				for i, p := range paths {
+					if paths[i], err = resolveComposeFilePath(workingDir, p); err != nil {
+						return fmt.Errorf("invalid `include` in compose file %s: %w", filePath, err)
+					}
+				}
+				includeWorkingDir := path.Dir(paths[0])
+				if len(projectDir) > 0 {
+					if includeWorkingDir, err = resolveComposeFilePath(workingDir, projectDir); err != nil {
+						return fmt.Errorf("invalid `include` in compose file %s: %w", filePath, err)
+					}
+				}
+				for _, p := range paths {
+					if err := walk(p, includeWorkingDir); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if services, ok := dict["services"].(map[string]interface{}); ok {
+			for name, s := range services {
+				service, ok := s.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				extends, ok := service["extends"].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				extendsFile, ok := extends["file"].(string)
+				if !ok || len(extendsFile) == 0 {
+					continue
+				}
+				p, err := resolveComposeFilePath(workingDir, extendsFile)
+				if err != nil {
+					return fmt.Errorf("invalid `extends` of service %s in compose file %s: %w", name, filePath, err)
+				}
+				if err := walk(p, path.Dir(p)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	mainFile = path.Clean(mainFile)
+	if err := walk(mainFile, path.Dir(mainFile)); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func resolveComposeFilePath(workingDir string, filePath string) (string, error) {
+	if strings.Contains(filePath, "://") {
+		return "", fmt.Errorf("remote resources are not supported: %s", filePath)
+	}
+	if path.IsAbs(filePath) {
+		return "", fmt.Errorf("absolute paths are not supported: %s", filePath)
+	}
+	p := path.Join(workingDir, filePath)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", fmt.Errorf("path is outside the app bundle: %s", filePath)
+	}
+	return p, nil
+}
+
+// ReadAppBundleFiles returns content of the app bundle regular files which size does not exceed the given limit,
+// the bigger files are skipped. The whole bundle is read, so its digest is verified.
+func ReadAppBundleFiles(ctx context.Context, provider BlobProvider, appRef *AppRef, bundleDesc *ocispec.Descriptor,
+	maxFileSize int64) (map[string][]byte, error) {
+	rc, err := provider.GetReadCloser(WithBlobType(WithAppRef(ctx, appRef), BlobTypeAppBundle),
+		WithRef(appRef.GetBlobRef(bundleDesc.Digest)),
+		WithExpectedDigest(bundleDesc.Digest), WithExpectedSize(bundleDesc.Size))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	r, err := archive.DecompressStream(rc)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	files := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.Size > maxFileSize {
+			continue
+		}
+		if files[path.Clean(hdr.Name)], err = io.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+	// Read the rest of the stream, so the blob digest is verified
+	if _, err := io.Copy(io.Discard, rc); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,79 @@
+package compose
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGetComposeFiles(t *testing.T) {
+	files := map[string]string{
+		"compose.yaml": `
+include:
+  - common/db.yaml
+  - path: [monitoring/compose.yaml]
+    project_directory: monitoring
+services:
+  web:
+    extends:
+      file: base.yaml
+      service: web
+  worker:
+    extends: web
+`,
+		"base.yaml": `
+services:
+  web:
+    image: nginx:latest
+`,
+		"common/db.yaml": `
+services:
+  db:
+    extends:
+      file: db-base.yaml
+      service: db
+`,
+		"common/db-base.yaml": `
+services:
+  db:
+    image: postgres:16
+`,
+		"monitoring/compose.yaml": `
+services:
+  agent:
+    image: agent:1
+    extends:
+      file: ../base.yaml
+      service: web
+`,
+	}
+	readFile := func(filePath string) ([]byte, error) {
+		if b, ok := files[filePath]; ok {
+			return []byte(b), nil
+		}
+		return nil, fmt.Errorf("not found: %s", filePath)
+	}
+
+	composeFiles, err := GetComposeFiles("compose.yaml", readFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"compose.yaml", "common/db.yaml", "common/db-base.yaml", "monitoring/compose.yaml", "base.yaml"}
+	if !reflect.DeepEqual(composeFiles, expected) {
+		t.Errorf("expected %v, got %v", expected, composeFiles)
+	}
+
+	for name, content := range map[string]string{
+		"outside of bundle": "include: [../other/compose.yaml]\n",
+		"absolute path":     "services:\n  web:\n    extends:\n      file: /etc/compose.yaml\n      service: web\n",
+		"remote resource":   "include: [oci://registry/app:1]\n",
+		"missing file":      "include: [missing.yaml]\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			files["invalid.yaml"] = content
+			if _, err := GetComposeFiles("invalid.yaml", readFile); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
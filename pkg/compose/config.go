@@ -12,8 +12,10 @@ import (
 
 type (
 	Config struct {
-		StoreRoot           string
-		ComposeRoot         string
+		StoreRoot   string
+		ComposeRoot string
+		// Root of the device-local app data, e.g. compose overrides, which is kept across app updates
+		LocalRoot           string
 		DockerCfg           *configfile.ConfigFile
 		DockerHost          string
 		Platform            specs.Platform
@@ -24,6 +26,10 @@ type (
 		BlockSize           int64
 		DBFilePath          string
 		Proxy               ProxyProvider
+		// Mirrors of registries, if not set, they are loaded from the registry mirrors file in the local root
+		RegistryMirrors *RegistryMirrors
+		// Files allowed to be in the app project directories in addition to the app bundle files
+		AllowedBundleExtraFiles []string
 	}
 	ProxyConfig struct {
 		ProxyURL   *url.URL
@@ -36,6 +42,22 @@ func (c *Config) GetAppComposeDir(appName string) string {
 	return filepath.Join(c.ComposeRoot, appName)
 }
 
+// GetAppComposePrevDir returns a path to the directory holding the previous version of the app compose project
+// that was replaced by the last installation.
+func (c *Config) GetAppComposePrevDir(appName string) string {
+	return filepath.Join(c.ComposeRoot, appName+AppComposePrevDirSuffix)
+}
+
+// GetAppLocalDir returns a path to the directory holding the device-local data of the app.
+// The directory is located outside the app compose project, so it is not affected by the app installation.
+func (c *Config) GetAppLocalDir(appName string) string {
+	return filepath.Join(c.LocalRoot, appName)
+}
+
+func (c *Config) GetAppComposeOverrideFile(appName string) string {
+	return filepath.Join(c.GetAppLocalDir(appName), AppComposeOverrideFile)
+}
+
 func (c *Config) GetBlobsRoot() string {
 	return GetBlobsRootFor(c.StoreRoot)
 }
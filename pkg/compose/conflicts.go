@@ -0,0 +1,314 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/compose-spec/compose-go/dotenv"
+	"github.com/compose-spec/compose-go/loader"
+	composetypes "github.com/compose-spec/compose-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	ConflictType string
+
+	// AppsConflict describes a host resource claimed by more than one app.
+	AppsConflict struct {
+		Type     ConflictType `json:"type"`
+		Resource string       `json:"resource"`
+		Apps     []string     `json:"apps"`
+	}
+
+	// ErrAppsConflict is returned if the apps that are going to run together claim the same host resources.
+	ErrAppsConflict struct {
+		Conflicts []AppsConflict
+	}
+
+	// appResource is a host resource claimed by a service of an app
+	appResource struct {
+		app     string
+		service string
+		// Host IP address the port is published on, empty if it is published on all addresses
+		hostIP string
+	}
+)
+
+const (
+	ConflictTypeHostPort      ConflictType = "host-port"
+	ConflictTypeContainerName ConflictType = "container-name"
+	ConflictTypeBindMount     ConflictType = "bind-mount"
+	ConflictTypeNetworkName   ConflictType = "network-name"
+	ConflictTypeVolumeName    ConflictType = "volume-name"
+	ConflictTypeDevice        ConflictType = "device"
+)
+
+func (e *ErrAppsConflict) Error() string {
+	var conflicts []string
+	for _, c := range e.Conflicts {
+		conflicts = append(conflicts, fmt.Sprintf("%s %s is claimed by apps: %s", c.Type, c.Resource, strings.Join(c.Apps, ", ")))
+	}
+	return "apps conflict: " + strings.Join(conflicts, "; ")
+}
+
+// CheckAppsConflicts checks whether the given apps claim the same host resources between each other,
+// and with the other apps installed on the device if checkInstalled is set. An ErrAppsConflict is returned
+// if any conflict is found. An installed app which compose project fails to load is skipped with a warning.
+func CheckAppsConflicts(ctx context.Context, cfg *Config, provider BlobProvider, apps []App, checkInstalled bool) error {
+	projects := map[string]*composetypes.Project{}
+	for _, app := range apps {
+		proj, err := app.GetCompose(ctx, provider)
+		if err != nil {
+			return fmt.Errorf("failed to load compose project of %s: %w", app.Name(), err)
+		}
+		projects[app.Name()] = proj
+	}
+	return checkProjectsConflicts(ctx, cfg, projects, checkInstalled)
+}
+
+// checkProjectsConflicts does the same as CheckAppsConflicts for the already loaded compose projects of the apps,
+// the installed projects are added to the given map if checkInstalled is set.
+func checkProjectsConflicts(ctx context.Context, cfg *Config, projects map[string]*composetypes.Project, checkInstalled bool) error {
+	if checkInstalled {
+		installed, err := ListComposeProjects(cfg)
+		if err != nil {
+			return err
+		}
+		for _, projectName := range installed {
+			if _, ok := projects[projectName]; ok {
+				continue
+			}
+			proj, err := loadInstalledComposeProject(ctx, cfg, projectName)
+			if err != nil {
+				// A broken project of an unrelated app should not block installation of the given apps,
+				// it cannot be started anyway
+				slog.Warn("skip checking conflicts with the installed app, failed to load its compose project",
+					"app", projectName, "error", err)
+				continue
+			}
+			projects[projectName] = proj
+		}
+	}
+	if conflicts := FindAppsConflicts(projects); len(conflicts) > 0 {
+		return &ErrAppsConflict{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// FindAppsConflicts returns host resources claimed by services of more than one of the given compose projects:
+// published host ports, container names, host paths bind mounted in the read-write mode, device mappings
+// and names of networks and volumes, including the external ones, used by the projects.
+func FindAppsConflicts(projects map[string]*composetypes.Project) []AppsConflict {
+	claims := map[ConflictType]map[string][]appResource{
+		ConflictTypeHostPort:      {},
+		ConflictTypeContainerName: {},
+		ConflictTypeBindMount:     {},
+		ConflictTypeNetworkName:   {},
+		ConflictTypeVolumeName:    {},
+		ConflictTypeDevice:        {},
+	}
+	claim := func(t ConflictType, resource string, r appResource) {
+		claims[t][resource] = append(claims[t][resource], r)
+	}
+	var bindMounts []struct {
+		path string
+		appResource
+	}
+
+	for appName, proj := range projects {
+		for _, s := range proj.Services {
+			r := appResource{app: appName, service: s.Name}
+			for _, p := range s.Ports {
+				ports, err := parsePublishedPorts(p.Published)
+				if err != nil || len(ports) == 0 {
+					continue
+				}
+				protocol := p.Protocol
+				if len(protocol) == 0 {
+					protocol = "tcp"
+				}
+				for _, port := range ports {
+					pr := r
+					if p.HostIP != "0.0.0.0" && p.HostIP != "::" {
+						pr.hostIP = p.HostIP
+					}
+					claim(ConflictTypeHostPort, fmt.Sprintf("%d/%s", port, protocol), pr)
+				}
+			}
+			if len(s.ContainerName) > 0 {
+				claim(ConflictTypeContainerName, s.ContainerName, r)
+			}
+			for _, v := range s.Volumes {
+				if v.Type != composetypes.VolumeTypeBind || v.ReadOnly || !filepath.IsAbs(v.Source) {
+					continue
+				}
+				source := filepath.Clean(v.Source)
+				if len(proj.WorkingDir) > 0 && isSubPath(filepath.Clean(proj.WorkingDir), source) {
+					// Paths relative to the project directory are specific to the app
+					continue
+				}
+				bindMounts = append(bindMounts, struct {
+					path string
+					appResource
+				}{path: source, appResource: r})
+			}
+			for _, d := range s.Devices {
+				hostPath, _, _ := strings.Cut(d, ":")
+				claim(ConflictTypeDevice, filepath.Clean(hostPath), r)
+			}
+		}
+		for key, n := range proj.Networks {
+			claim(ConflictTypeNetworkName, getResourceName(proj.Name, key, n.Name, n.External.External),
+				appResource{app: appName})
+		}
+		for key, v := range proj.Volumes {
+			claim(ConflictTypeVolumeName, getResourceName(proj.Name, key, v.Name, v.External.External),
+				appResource{app: appName})
+		}
+	}
+
+	// Bind mounts conflict if the same host path or one of its parents is mounted by another app
+	for i, m := range bindMounts {
+		claim(ConflictTypeBindMount, m.path, m.appResource)
+		for j, other := range bindMounts {
+			if i != j && m.path != other.path && isSubPath(other.path, m.path) {
+				claim(ConflictTypeBindMount, m.path, other.appResource)
+			}
+		}
+	}
+
+	var conflicts []AppsConflict
+	for t, resources := range claims {
+		for resource, rs := range resources {
+			apps := map[string]struct{}{}
+			for i, r := range rs {
+				for _, other := range rs[i+1:] {
+					if r.app == other.app {
+						continue
+					}
+					if t == ConflictTypeHostPort && len(r.hostIP) > 0 && len(other.hostIP) > 0 && r.hostIP != other.hostIP {
+						// The port is published on different host addresses
+						continue
+					}
+					apps[r.app] = struct{}{}
+					apps[other.app] = struct{}{}
+				}
+			}
+			if len(apps) == 0 {
+				continue
+			}
+			c := AppsConflict{Type: t, Resource: resource}
+			for app := range apps {
+				c.Apps = append(c.Apps, app)
+			}
+			sort.Strings(c.Apps)
+			conflicts = append(conflicts, c)
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Type != conflicts[j].Type {
+			return conflicts[i].Type < conflicts[j].Type
+		}
+		return conflicts[i].Resource < conflicts[j].Resource
+	})
+	return conflicts
+}
+
+func parsePublishedPorts(published string) ([]int, error) {
+	if len(published) == 0 {
+		// A random host port is allocated
+		return nil, nil
+	}
+	start, end, isRange := strings.Cut(published, "-")
+	first, err := strconv.Atoi(start)
+	if err != nil {
+		return nil, err
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.Atoi(end); err != nil {
+			return nil, err
+		}
+	}
+	if last < first {
+		return nil, fmt.Errorf("invalid port range: %s", published)
+	}
+	var ports []int
+	for p := first; p <= last; p++ {
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+// getResourceName returns the name of a network or volume, the name of an external one is not prefixed
+// with the project name.
+func getResourceName(projectName string, key string, name string, external bool) string {
+	if len(name) > 0 {
+		return name
+	}
+	if external {
+		return key
+	}
+	return projectName + "_" + key
+}
+
+func isSubPath(parent string, p string) bool {
+	return p == parent || strings.HasPrefix(p, strings.TrimSuffix(parent, "/")+"/")
+}
+
+// loadInstalledComposeProject loads the compose project installed on the device along with its device-local
+// override, device variables and active profiles, the same way as it is started.
+func loadInstalledComposeProject(ctx context.Context, cfg *Config, projectName string) (*composetypes.Project, error) {
+	appDir := cfg.GetAppComposeDir(projectName)
+	composeFile, err := FindComposeFileInDir(appDir)
+	if err != nil {
+		return nil, err
+	}
+	configFiles := []composetypes.ConfigFile{{Filename: filepath.Join(appDir, composeFile)}}
+	if _, err := os.Stat(cfg.GetAppComposeOverrideFile(projectName)); err == nil {
+		configFiles = append(configFiles, composetypes.ConfigFile{Filename: cfg.GetAppComposeOverrideFile(projectName)})
+	}
+	for i := range configFiles {
+		if configFiles[i].Content, err = os.ReadFile(configFiles[i].Filename); err != nil {
+			return nil, err
+		}
+	}
+	env := map[string]string{}
+	for _, envFile := range []string{filepath.Join(appDir, AppEnvFile), cfg.GetAppDeviceEnvFile(projectName)} {
+		vars, err := dotenv.Read(envFile)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		for k, v := range vars {
+			env[k] = v
+		}
+	}
+	settings, err := LoadAppSettings(cfg, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	prev := logrus.GetLevel()
+	logrus.SetLevel(logrus.ErrorLevel)
+	defer logrus.SetLevel(prev)
+
+	return loader.LoadWithContext(ctx, composetypes.ConfigDetails{
+		WorkingDir:  appDir,
+		ConfigFiles: configFiles,
+		Environment: env,
+	}, func(options *loader.Options) {
+		options.SetProjectName(projectName, true)
+		options.Profiles = settings.Profiles
+		options.SkipResolveEnvironment = true
+	})
+}
@@ -0,0 +1,151 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/compose-spec/compose-go/loader"
+	composetypes "github.com/compose-spec/compose-go/types"
+)
+
+func loadTestProject(t *testing.T, name string, content string) *composetypes.Project {
+	proj, err := loader.Load(composetypes.ConfigDetails{
+		WorkingDir:  "/var/sota/compose-apps/" + name,
+		ConfigFiles: []composetypes.ConfigFile{{Filename: "docker-compose.yml", Content: []byte(content)}},
+		Environment: map[string]string{},
+	}, func(options *loader.Options) {
+		options.SetProjectName(name, true)
+	})
+	if err != nil {
+		t.Fatalf("failed to load project %s: %s", name, err)
+	}
+	return proj
+}
+
+func TestFindAppsConflicts(t *testing.T) {
+	projects := map[string]*composetypes.Project{
+		"app1": loadTestProject(t, "app1", `
+services:
+  web:
+    image: nginx
+    container_name: web
+    ports:
+      - 8080:80
+      - 127.0.0.1:9000:9000
+      - 5000-5002:5000-5002/udp
+    volumes:
+      - /var/lib/shared:/data
+      - /etc/ssl:/etc/ssl:ro
+      - ./config:/config
+    devices:
+      - /dev/ttyUSB0:/dev/ttyUSB0
+networks:
+  backend:
+    name: backend
+  shared:
+    external: true
+`),
+		"app2": loadTestProject(t, "app2", `
+services:
+  proxy:
+    image: haproxy
+    container_name: web
+    ports:
+      - 8080:8080
+      - 127.0.0.2:9000:9000
+      - 5002:5002/udp
+      - 5003:5003
+    volumes:
+      - /var/lib/shared/app2:/data
+      - /etc/ssl:/etc/ssl:ro
+      - ./config:/config
+    devices:
+      - /dev/ttyUSB0
+networks:
+  backend:
+    name: backend
+  shared:
+    external: true
+volumes:
+  certs:
+    external: true
+    name: certs
+`),
+		"app3": loadTestProject(t, "app3", `
+services:
+  db:
+    image: postgres
+    ports:
+      - 5003:5003/udp
+    volumes:
+      - data:/var/lib/postgresql/data
+volumes:
+  data:
+  certs:
+    external: true
+`),
+	}
+
+	expected := []AppsConflict{
+		{Type: ConflictTypeBindMount, Resource: "/var/lib/shared/app2", Apps: []string{"app1", "app2"}},
+		{Type: ConflictTypeContainerName, Resource: "web", Apps: []string{"app1", "app2"}},
+		{Type: ConflictTypeDevice, Resource: "/dev/ttyUSB0", Apps: []string{"app1", "app2"}},
+		{Type: ConflictTypeHostPort, Resource: "5002/udp", Apps: []string{"app1", "app2"}},
+		{Type: ConflictTypeHostPort, Resource: "8080/tcp", Apps: []string{"app1", "app2"}},
+		{Type: ConflictTypeNetworkName, Resource: "backend", Apps: []string{"app1", "app2"}},
+		{Type: ConflictTypeNetworkName, Resource: "shared", Apps: []string{"app1", "app2"}},
+		{Type: ConflictTypeVolumeName, Resource: "certs", Apps: []string{"app2", "app3"}},
+	}
+	conflicts := FindAppsConflicts(projects)
+	if !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("unexpected conflicts:\n got: %+v\nwant: %+v", conflicts, expected)
+	}
+
+	delete(projects, "app2")
+	if conflicts := FindAppsConflicts(projects); len(conflicts) > 0 {
+		t.Errorf("no conflicts expected, got: %+v", conflicts)
+	}
+}
+
+func TestCheckAppsConflictsWithInstalled(t *testing.T) {
+	cfg := &Config{ComposeRoot: t.TempDir(), LocalRoot: t.TempDir()}
+	installed := map[string]string{
+		"app2": "services:\n  web:\n    image: nginx\n    ports:\n      - 8080:80\n",
+		// An unrelated app which project cannot be loaded does not block the check
+		"broken": "services: [\n",
+	}
+	for name, content := range installed {
+		if err := os.MkdirAll(cfg.GetAppComposeDir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(cfg.GetAppComposeDir(name), "docker-compose.yml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := &testApp{name: "app1", proj: loadTestProject(t, "app1", "services:\n  web:\n    image: nginx\n    ports:\n      - 8081:80\n")}
+	if err := CheckAppsConflicts(context.Background(), cfg, nil, []App{app}, true); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	app.proj = loadTestProject(t, "app1", "services:\n  web:\n    image: nginx\n    ports:\n      - 8080:80\n")
+	err := CheckAppsConflicts(context.Background(), cfg, nil, []App{app}, true)
+	var conflictErr *ErrAppsConflict
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected apps conflict error, got %v", err)
+	}
+	expected := []AppsConflict{{Type: ConflictTypeHostPort, Resource: "8080/tcp", Apps: []string{"app1", "app2"}}}
+	if !reflect.DeepEqual(conflictErr.Conflicts, expected) {
+		t.Errorf("expected conflicts %+v, got %+v", expected, conflictErr.Conflicts)
+	}
+
+	// The installed version of the app being checked is replaced by it, so it is not checked against
+	app2 := &testApp{name: "app2", proj: loadTestProject(t, "app2", "services:\n  web:\n    image: nginx\n    ports:\n      - 8080:80\n")}
+	if err := CheckAppsConflicts(context.Background(), cfg, nil, []App{app2}, true); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
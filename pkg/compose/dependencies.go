@@ -0,0 +1,131 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// ErrAppDependencyCycle is returned if apps depend on each other, so they cannot be ordered.
+	ErrAppDependencyCycle struct {
+		Apps []string
+	}
+)
+
+const (
+	// AppDependsOnAnnotationKey is the app manifest annotation listing comma-separated names of the apps
+	// that must be started and healthy before the app is started.
+	AppDependsOnAnnotationKey = "org.foundries.app.depends-on"
+
+	DefaultDependencyWaitTimeout = 5 * time.Minute
+	dependencyCheckInterval      = 2 * time.Second
+)
+
+var (
+	ErrAppDependencyNotHealthy = errors.New("app dependency is not healthy")
+)
+
+func (e *ErrAppDependencyCycle) Error() string {
+	return "app dependency cycle detected between apps: " + strings.Join(e.Apps, ", ")
+}
+
+// GetAppDependencies returns names of the apps that the given app depends on.
+func GetAppDependencies(app App) []string {
+	var deps []string
+	for _, name := range strings.Split(app.Annotations()[AppDependsOnAnnotationKey], ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 && name != app.Name() {
+			deps = append(deps, name)
+		}
+	}
+	return deps
+}
+
+// SortAppsByDependencies returns the given apps in the order in which they should be started, so each app
+// follows the apps it depends on. Dependencies on apps that are not in the given list are ignored.
+// Apps that do not depend on each other are ordered by name.
+func SortAppsByDependencies(apps []App) ([]App, error) {
+	byName := map[string]App{}
+	for _, app := range apps {
+		byName[app.Name()] = app
+	}
+	inDegree := map[string]int{}
+	dependents := map[string][]string{}
+	for name, app := range byName {
+		inDegree[name] += 0
+		for _, dep := range GetAppDependencies(app) {
+			if _, ok := byName[dep]; !ok {
+				continue
+			}
+			inDegree[name]++
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var ready []string
+	for name, degree := range inDegree {
+		if degree == 0 {
+			ready = append(ready, name)
+		}
+	}
+	var sorted []App
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, byName[name])
+		for _, dependent := range dependents[name] {
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	if len(sorted) < len(byName) {
+		var cycle []string
+		for name, degree := range inDegree {
+			if degree > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		sort.Strings(cycle)
+		return nil, &ErrAppDependencyCycle{Apps: cycle}
+	}
+	return sorted, nil
+}
+
+// waitForAppsHealthy waits until all services of the given apps are running and healthy.
+func waitForAppsHealthy(ctx context.Context, cfg *Config, apps []App, timeout time.Duration) error {
+	if len(apps) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		runningStatus, err := CheckAppsRunningStatus(ctx, cfg, apps)
+		if err != nil {
+			return err
+		}
+		var notHealthy []string
+		for _, app := range apps {
+			_, notRunning := runningStatus.NotRunningApps[app.Ref().Digest]
+			if notRunning || runningStatus.AppsRunningStatus[app.Ref().Digest].Health != "healthy" {
+				notHealthy = append(notHealthy, app.Name())
+			}
+		}
+		if len(notHealthy) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w: %s", ErrAppDependencyNotHealthy, strings.Join(notHealthy, ", "))
+			}
+			return ctx.Err()
+		case <-time.After(dependencyCheckInterval):
+		}
+	}
+}
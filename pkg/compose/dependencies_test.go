@@ -0,0 +1,112 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	composetypes "github.com/compose-spec/compose-go/types"
+	"github.com/containerd/containerd/platforms"
+)
+
+type testApp struct {
+	App
+	name        string
+	dependsOn   string
+	ref         *AppRef
+	tree        *AppTree
+	composeRoot *TreeNode
+	// bundle files expected in the app compose project directory
+	bundleFiles map[string]string
+	proj        *composetypes.Project
+	// number of times the compose project was loaded
+	getComposeCalls int
+}
+
+// testAppLoader loads the test apps by their URIs
+type testAppLoader map[string]*testApp
+
+func (l testAppLoader) LoadAppTree(ctx context.Context, provider BlobProvider, platform platforms.MatchComparer,
+	appURI string) (App, error) {
+	if app, ok := l[appURI]; ok {
+		return app, nil
+	}
+	return nil, ErrAppNotFound
+}
+
+func (a *testApp) Name() string {
+	return a.name
+}
+
+func (a *testApp) Ref() *AppRef {
+	return a.ref
+}
+
+func (a *testApp) Tree() *AppTree {
+	return a.tree
+}
+
+func (a *testApp) GetComposeRoot() *TreeNode {
+	return a.composeRoot
+}
+
+func (a *testApp) CheckComposeInstallation(ctx context.Context, provider BlobProvider, installationRootDir string,
+	options ...CheckComposeOption) (AppBundleErrs, error) {
+	errs := AppBundleErrs{}
+	for name, data := range a.bundleFiles {
+		if b, err := os.ReadFile(filepath.Join(installationRootDir, name)); err != nil {
+			errs[name] = err.Error()
+		} else if string(b) != data {
+			errs[name] = "content mismatch"
+		}
+	}
+	return errs, nil
+}
+
+func (a *testApp) GetCompose(ctx context.Context, provider BlobProvider) (*composetypes.Project, error) {
+	a.getComposeCalls++
+	return a.proj, nil
+}
+
+func (a *testApp) Annotations() map[string]string {
+	return map[string]string{AppDependsOnAnnotationKey: a.dependsOn}
+}
+
+func TestSortAppsByDependencies(t *testing.T) {
+	appNames := func(apps []App) []string {
+		var names []string
+		for _, app := range apps {
+			names = append(names, app.Name())
+		}
+		return names
+	}
+
+	apps := []App{
+		&testApp{name: "web", dependsOn: "db, cache"},
+		&testApp{name: "cache"},
+		&testApp{name: "db", dependsOn: "storage"},
+		&testApp{name: "monitor", dependsOn: "web,unknown"},
+		&testApp{name: "agent"},
+	}
+	sorted, err := SortAppsByDependencies(apps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"agent", "cache", "db", "web", "monitor"}
+	if names := appNames(sorted); !reflect.DeepEqual(names, expected) {
+		t.Errorf("unexpected order: got %v, want %v", names, expected)
+	}
+
+	apps = append(apps, &testApp{name: "storage", dependsOn: "monitor"})
+	_, err = SortAppsByDependencies(apps)
+	var errCycle *ErrAppDependencyCycle
+	if !errors.As(err, &errCycle) {
+		t.Fatalf("expected dependency cycle error, got: %v", err)
+	}
+	if expected := []string{"db", "monitor", "storage", "web"}; !reflect.DeepEqual(errCycle.Apps, expected) {
+		t.Errorf("unexpected apps in cycle: got %v, want %v", errCycle.Apps, expected)
+	}
+}
@@ -0,0 +1,259 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/compose-spec/compose-go/dotenv"
+	"github.com/compose-spec/compose-go/loader"
+	"github.com/compose-spec/compose-go/template"
+)
+
+type (
+	// ErrUndefinedDeviceVars is returned if the app compose project references variables that are not defined
+	// in the device variables file and have no default value.
+	ErrUndefinedDeviceVars struct {
+		App  string
+		Vars []string
+	}
+)
+
+const (
+	// DeviceVarsFile is the name of the file in the local root that holds the device variables, in the dotenv format.
+	DeviceVarsFile = "device-vars.env"
+	// AppDeviceEnvFile is the name of the file in the app's local directory that holds the device variables
+	// referenced by the installed app, it is rendered at the app installation and used when the app is started.
+	AppDeviceEnvFile = "device.env"
+
+	// EnvStrictDeviceVars enables the strict device variable mode if set to "1" or "true".
+	EnvStrictDeviceVars = "COMPOSE_APPS_STRICT_VARS"
+
+	// AppComposeFileMaxSize is the maximum size of a compose project file of an app bundle
+	AppComposeFileMaxSize = 1024 * 1024
+)
+
+var (
+	deviceVarNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+func (e *ErrUndefinedDeviceVars) Error() string {
+	return fmt.Sprintf("app %s references undefined device variables: %s", e.App, strings.Join(e.Vars, ", "))
+}
+
+func (c *Config) GetDeviceVarsFile() string {
+	return filepath.Join(c.LocalRoot, DeviceVarsFile)
+}
+
+func (c *Config) GetAppDeviceEnvFile(appName string) string {
+	return filepath.Join(c.GetAppLocalDir(appName), AppDeviceEnvFile)
+}
+
+// LoadDeviceVars returns the device variables, an empty map is returned if the device variables file is missing.
+func LoadDeviceVars(cfg *Config) (map[string]string, error) {
+	vars, err := dotenv.Read(cfg.GetDeviceVarsFile())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read device variables: %w", err)
+	}
+	return vars, nil
+}
+
+// SetDeviceVars adds the given variables to the device variables file or updates their values.
+// The change takes effect on the next app installation.
+func SetDeviceVars(cfg *Config, vars map[string]string) error {
+	for name, value := range vars {
+		if !deviceVarNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid device variable name: %q", name)
+		}
+		if strings.ContainsAny(value, "'\n\r") {
+			return fmt.Errorf("invalid value of device variable %s: single quotes and line breaks are not allowed", name)
+		}
+	}
+	deviceVars, err := LoadDeviceVars(cfg)
+	if err != nil {
+		return err
+	}
+	for name, value := range vars {
+		deviceVars[name] = value
+	}
+	return writeEnvFile(cfg.GetDeviceVarsFile(), deviceVars)
+}
+
+// UnsetDeviceVars removes the given variables from the device variables file.
+func UnsetDeviceVars(cfg *Config, names ...string) error {
+	deviceVars, err := LoadDeviceVars(cfg)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		delete(deviceVars, name)
+	}
+	return writeEnvFile(cfg.GetDeviceVarsFile(), deviceVars)
+}
+
+// IsStrictDeviceVarsMode returns true if the strict device variable mode is enabled by the environment,
+// so installation of an app must fail if the app references variables that are not defined in the device
+// variables file. The mode can also be enabled per installation, see WithStrictDeviceVars.
+func IsStrictDeviceVarsMode() bool {
+	env := strings.ToLower(os.Getenv(EnvStrictDeviceVars))
+	return env == "1" || env == "true"
+}
+
+// resolveAppDeviceVars returns the device variables referenced by the app compose project.
+// Variables that are neither defined on the device nor have a default value are reported as an error
+// in the strict mode and if they are marked as required by the app, otherwise they are left to `docker compose`
+// to be substituted with an empty string.
+func resolveAppDeviceVars(ctx context.Context, cfg *Config, provider BlobProvider, app App, strict bool) (map[string]string, error) {
+	bundleFiles, err := ReadAppBundleFiles(ctx, provider, app.Ref(), app.GetComposeRoot().Descriptor, AppComposeFileMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	readFile := func(filePath string) ([]byte, error) {
+		if b, ok := bundleFiles[filePath]; ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("%s is not found in the bundle of %s", filePath, app.Ref().String())
+	}
+	mainFile, err := FindComposeFile(func(fileName string) bool {
+		_, ok := bundleFiles[fileName]
+		return ok
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle of %s: %w", app.Ref().String(), err)
+	}
+	composeFiles, err := GetComposeFiles(mainFile, readFile)
+	if err != nil {
+		return nil, err
+	}
+	// Variables defined in the bundled env file have default values
+	bundleVars := map[string]string{}
+	if b, ok := bundleFiles[AppEnvFile]; ok {
+		if bundleVars, err = dotenv.Parse(bytes.NewReader(b)); err != nil {
+			return nil, fmt.Errorf("failed to parse %s of %s: %w", AppEnvFile, app.Ref().String(), err)
+		}
+	}
+	referencedVars := map[string]template.Variable{}
+	for _, f := range composeFiles {
+		composeDict, err := loader.ParseYAML(bundleFiles[f])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse compose file %s of %s: %w", f, app.Ref().String(), err)
+		}
+		for name, v := range template.ExtractVariables(composeDict, nil) {
+			if _, ok := bundleVars[name]; ok {
+				v.DefaultValue = bundleVars[name]
+			}
+			referencedVars[name] = v
+		}
+	}
+	deviceVars, err := LoadDeviceVars(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	appVars := map[string]string{}
+	var undefinedVars []string
+	for name, v := range referencedVars {
+		if value, ok := deviceVars[name]; ok {
+			appVars[name] = value
+		} else if len(v.DefaultValue) == 0 && (strict || v.Required) {
+			undefinedVars = append(undefinedVars, name)
+		}
+	}
+	if len(undefinedVars) > 0 {
+		sort.Strings(undefinedVars)
+		return nil, &ErrUndefinedDeviceVars{App: app.Ref().String(), Vars: undefinedVars}
+	}
+	return appVars, nil
+}
+
+// writeAppDeviceEnv renders the app device variables to the app's device env file.
+// If keepPrev is set, then the current file is preserved, so it can be restored along with the previous version
+// of the app compose project.
+func writeAppDeviceEnv(cfg *Config, appName string, vars map[string]string, keepPrev bool) error {
+	envFile := cfg.GetAppDeviceEnvFile(appName)
+	if keepPrev {
+		if err := os.Rename(envFile, envFile+AppComposePrevDirSuffix); err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			// The previous version has no device variables
+			if err := os.Remove(envFile + AppComposePrevDirSuffix); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	if len(vars) == 0 {
+		if err := os.Remove(envFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return writeEnvFile(envFile, vars)
+}
+
+// restorePrevAppDeviceEnv restores the app device env file preserved by the last app installation.
+func restorePrevAppDeviceEnv(cfg *Config, appName string) error {
+	envFile := cfg.GetAppDeviceEnvFile(appName)
+	if err := os.Rename(envFile+AppComposePrevDirSuffix, envFile); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Remove(envFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeEnvFile(envFile string, vars map[string]string) error {
+	var names []string
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		// Single-quoted values are not subject to variable expansion
+		b.WriteString(fmt.Sprintf("%s='%s'\n", name, vars[name]))
+	}
+	return writeFileAtomically(envFile, []byte(b.String()), 0644)
+}
+
+func writeFileAtomically(filePath string, content []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpFile.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile.Name(), filePath); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
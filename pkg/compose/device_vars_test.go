@@ -0,0 +1,47 @@
+package compose
+
+import (
+	"testing"
+)
+
+func TestDeviceVars(t *testing.T) {
+	cfg := &Config{LocalRoot: t.TempDir()}
+	vars := map[string]string{
+		"DEVICE_SERIAL": "1234-abcd",
+		"FACTORY_TAG":   "main",
+		"WITH_SPACES":   " value with spaces # and hash ",
+		"NOT_EXPANDED":  "${DEVICE_SERIAL} $HOME \\n",
+		"EMPTY":         "",
+	}
+	if err := SetDeviceVars(cfg, vars); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadDeviceVars(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(vars) {
+		t.Errorf("expected %d variables, got: %v", len(vars), loaded)
+	}
+	for name, value := range vars {
+		if loaded[name] != value {
+			t.Errorf("unexpected value of %s: expected %q, got %q", name, value, loaded[name])
+		}
+	}
+
+	if err := UnsetDeviceVars(cfg, "FACTORY_TAG"); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err = LoadDeviceVars(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded["FACTORY_TAG"]; ok || len(loaded) != len(vars)-1 {
+		t.Errorf("unexpected variables after unset: %v", loaded)
+	}
+
+	for _, invalid := range []map[string]string{{"1INVALID": "foo"}, {"FOO": "it's"}, {"FOO": "line\nbreak"}} {
+		if err := SetDeviceVars(cfg, invalid); err == nil {
+			t.Errorf("expected error for invalid variable: %v", invalid)
+		}
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"github.com/containerd/containerd/content"
 	"github.com/containerd/containerd/content/local"
 	"github.com/containerd/containerd/errdefs"
+	remoteserrors "github.com/containerd/containerd/remotes/errors"
 	"github.com/foundriesio/composeapp/internal/progress"
 	"github.com/opencontainers/go-digest"
 	"io"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,6 +19,10 @@ import (
 
 const (
 	DefaultPollInterval = 300 // Default interval between polling/checking blob download status in milliseconds
+
+	DefaultFetchRetryAttempts = 3               // Default number of attempts to fetch a single blob
+	DefaultFetchRetryBackoff  = 2 * time.Second // Default delay before the second attempt, it doubles with each next attempt
+	maxFetchRetryBackoff      = time.Minute
 )
 
 type (
@@ -40,7 +46,14 @@ type (
 	FetchOptions struct {
 		ProgressHandler      FetchProgressFunc
 		ProgressPollInterval int    // interval between polling/checking blob download status in milliseconds
-		SourcePath           string // path to the source directory containing blobs to fetch, if specified, the blobs will be fetched from this directory instead of remote registry
+		SourcePath           string // source of blobs to fetch, see NewSourceBlobProvider, if specified, the blobs will be fetched from it instead of remote registry
+		Concurrency          int    // maximum number of blobs fetched in parallel
+		RateLimiter          *RateLimiter
+		RetryAttempts        int           // maximum number of attempts to fetch a single blob
+		RetryBackoff         time.Duration // delay before the second attempt to fetch a blob
+		// minimum number of bytes left to fetch for a blob to be fetched by concurrent range requests, 0 disables it
+		ChunkedFetchThreshold int64
+		ChunkedFetchChunks    int // number of concurrent range requests to fetch a blob
 	}
 
 	FetchOption       func(*FetchOptions)
@@ -73,14 +86,45 @@ func WithProgressPollInterval(pollInterval int) FetchOption {
 	}
 }
 
+// WithSourcePath makes FetchBlobs fetch blobs from the given store root directory, or from the OCI image layout
+// directory if the path is prefixed with `oci:`.
 func WithSourcePath(sourcePath string) FetchOption {
 	return func(opts *FetchOptions) {
 		opts.SourcePath = sourcePath
 	}
 }
 
+// WithFetchConcurrency makes FetchBlobs fetch up to n blobs in parallel. Metadata blobs are still fetched
+// before any image layer blob is started.
+func WithFetchConcurrency(n int) FetchOption {
+	return func(opts *FetchOptions) {
+		opts.Concurrency = n
+	}
+}
+
+// WithRateLimiter limits the overall read rate of the blobs being fetched.
+func WithRateLimiter(l *RateLimiter) FetchOption {
+	return func(opts *FetchOptions) {
+		opts.RateLimiter = l
+	}
+}
+
+// WithFetchRetry sets the maximum number of attempts to fetch a single blob and the delay before the second
+// attempt, which doubles with each next attempt. Each next attempt resumes the fetching from the point where
+// the previous one failed. Errors which cannot be fixed by retrying, like a missing blob, are not retried.
+// The number of attempts less than 1 is treated as 1, i.e. the blob fetch is not retried.
+func WithFetchRetry(attempts int, backoff time.Duration) FetchOption {
+	return func(opts *FetchOptions) {
+		opts.RetryAttempts = attempts
+		opts.RetryBackoff = backoff
+	}
+}
+
 func FetchBlobs(ctx context.Context, cfg *Config, blobs BlobsInfo, options ...FetchOption) error {
-	opts := FetchOptions{}
+	opts := FetchOptions{
+		RetryAttempts: DefaultFetchRetryAttempts,
+		RetryBackoff:  DefaultFetchRetryBackoff,
+	}
 	for _, o := range options {
 		o(&opts)
 	}
@@ -123,10 +167,14 @@ func FetchBlobs(ctx context.Context, cfg *Config, blobs BlobsInfo, options ...Fe
 	}
 
 	var blobProvider BlobProvider
+	var err error
 	if opts.SourcePath == "" {
-		blobProvider = NewRemoteBlobProviderFromConfig(cfg)
+		blobProvider, err = NewRemoteBlobProviderFromConfig(cfg)
 	} else {
-		blobProvider = NewStoreBlobProvider(GetBlobsRootFor(opts.SourcePath))
+		blobProvider, err = NewSourceBlobProvider(opts.SourcePath)
+	}
+	if err != nil {
+		return err
 	}
 
 	ls, err := local.NewStore(cfg.StoreRoot)
@@ -134,6 +182,24 @@ func FetchBlobs(ctx context.Context, cfg *Config, blobs BlobsInfo, options ...Fe
 		return err
 	}
 
+	if len(cfg.LocalRoot) > 0 {
+		// The rate limit can be set for the ongoing fetch by another process, see SetFetchRate
+		if opts.RateLimiter == nil {
+			opts.RateLimiter = NewRateLimiter(0)
+		}
+		rateCtx, cancelRate := context.WithCancel(ctx)
+		rateDone := make(chan struct{})
+		go func() {
+			defer close(rateDone)
+			opts.RateLimiter.followFetchRate(rateCtx, cfg, fetchRateCheckInterval)
+		}()
+		// The limiter can be reused by the next fetch, so wait for its initial limit to be restored
+		defer func() {
+			cancelRate()
+			<-rateDone
+		}()
+	}
+
 	var progressWg sync.WaitGroup
 	stopChan := make(chan struct{})
 	if progressReporter != nil {
@@ -164,33 +230,102 @@ func FetchBlobs(ctx context.Context, cfg *Config, blobs BlobsInfo, options ...Fe
 		}(stopChan)
 	}
 
-	for _, bi := range getOrderedBlobsToFetch(blobsToFetch) {
-		err = func() error {
-			// Get the reader without digest calculation and verification because the writer/ingester of
-			// the local store (`ls`) will do that.
-			//r, err := blobProvider.GetReadCloser(ctx, WithRef(bi.Ref()), WithDescriptor(*bi.Descriptor))
-			r, err := blobProvider.GetReadCloser(ctx, WithRef(bi.Ref()), WithDescriptor(*bi.Descriptor), WithSecureReadOff())
-			if err != nil {
-				return fmt.Errorf("failed to initiate request to fetch blob %s: %w", bi.Descriptor.Digest, err)
-			}
-			defer r.Close()
-			blobReader, ok := r.(io.ReadSeekCloser)
-			if !ok {
-				return fmt.Errorf("blob fetch reader for %s does not implement io.ReadSeekCloser", bi.Ref())
-			}
+	var chunked *chunkedFetcher
+	if opts.ChunkedFetchThreshold > 0 && blobProvider.Type() == BlobProviderTypeRemote {
+		chunked = &chunkedFetcher{
+			provider:    blobProvider,
+			store:       ls,
+			tmpDir:      cfg.StoreRoot,
+			chunks:      opts.ChunkedFetchChunks,
+			rateLimiter: opts.RateLimiter,
+		}
+		if chunked.chunks <= 0 {
+			chunked.chunks = DefaultFetchChunks
+		}
+	}
+
+	fetchBlobOnce := func(ctx context.Context, bi *BlobFetchProgress) error {
+		// Get the reader without digest calculation and verification because the writer/ingester of
+		// the local store (`ls`) will do that.
+		//r, err := blobProvider.GetReadCloser(ctx, WithRef(bi.Ref()), WithDescriptor(*bi.Descriptor))
+		r, err := blobProvider.GetReadCloser(ctx, WithRef(bi.Ref()), WithDescriptor(*bi.Descriptor), WithSecureReadOff())
+		if err != nil {
+			return fmt.Errorf("failed to initiate request to fetch blob %s: %w", bi.Descriptor.Digest, err)
+		}
+		defer r.Close()
+		blobReader, ok := r.(io.ReadSeekCloser)
+		if !ok {
+			return fmt.Errorf("blob fetch reader for %s does not implement io.ReadSeekCloser", bi.Ref())
+		}
+		if opts.RateLimiter != nil {
+			// The read monitor wraps the limited reader, so the read speed reflects the rate limit
+			blobReader = opts.RateLimiter.NewReader(ctx, blobReader)
+		}
+		if bi.FetchStartTime.IsZero() {
+			bi.FetchStartTime = time.Now()
+		}
+		rm := NewReadMonitor(ctx, blobReader, bi)
+		rm.Start()
+		defer rm.Stop()
+		if err := CopyBlob(ctx, rm, bi.Ref(), *bi.Descriptor, ls, true); err != nil {
+			return fmt.Errorf("failed to fetch blob %s: %w", bi.Descriptor.Digest, err)
+		}
+		return nil
+	}
+
+	retry := func(ctx context.Context, fetch func() error) error {
+		return retryFetch(ctx, opts.RetryAttempts, opts.RetryBackoff, fetch)
+	}
+	if chunked != nil {
+		chunked.retry = retry
+	}
+	fetchBlob := func(ctx context.Context, bi *BlobFetchProgress) error {
+		var offset int64
+		if s, err := ls.Status(ctx, bi.Ref()); err == nil {
+			offset = s.Offset
+		}
+		var err error
+		if chunked != nil && bi.Descriptor.Size-offset >= opts.ChunkedFetchThreshold {
+			// The chunks are retried individually
 			bi.FetchStartTime = time.Now()
-			rm := NewReadMonitor(ctx, blobReader, bi)
-			rm.Start()
-			defer rm.Stop()
-			if err := CopyBlob(ctx, rm, bi.Ref(), *bi.Descriptor, ls, true); err != nil {
-				return fmt.Errorf("failed to fetch blob %s: %w", bi.Descriptor.Digest, err)
+			err = chunked.fetch(ctx, bi, offset)
+		} else {
+			// The local store keeps the data ingested by a failed attempt, so the next attempt
+			// requests only the remaining part of the blob
+			err = retry(ctx, func() error {
+				err := fetchBlobOnce(ctx, bi)
+				if errors.Is(err, ErrMirrorDigestMismatch) {
+					// The data served by the mirror is invalid, so the next attempt fetches the whole blob
+					// from the next mirror
+					if abortErr := ls.Abort(ctx, bi.Ref()); abortErr != nil && !errdefs.IsNotFound(abortErr) {
+						return fmt.Errorf("%w; failed to remove its invalid data: %w", err, abortErr)
+					}
+				}
+				return err
+			})
+		}
+		if errdefs.IsFailedPrecondition(err) {
+			// The ingested data does not match the blob descriptor, so it cannot be resumed
+			if abortErr := ls.Abort(ctx, bi.Ref()); abortErr != nil && !errdefs.IsNotFound(abortErr) {
+				return fmt.Errorf("%w; failed to remove its invalid data: %w", err, abortErr)
 			}
-			return nil
-		}()
-		if err != nil {
+		}
+		return err
+	}
+
+	// Metadata blobs are fetched before data blobs, so the app trees can be loaded as early as possible
+	orderedBlobs := getOrderedBlobsToFetch(blobsToFetch)
+	dataStart := len(orderedBlobs)
+	for i, bi := range orderedBlobs {
+		if bi.Type == BlobTypeImageLayer {
+			dataStart = i
 			break
 		}
 	}
+	err = fetchBlobsConcurrently(ctx, orderedBlobs[:dataStart], opts.Concurrency, fetchBlob)
+	if err == nil {
+		err = fetchBlobsConcurrently(ctx, orderedBlobs[dataStart:], opts.Concurrency, fetchBlob)
+	}
 
 	if progressReporter != nil {
 		if ctx.Err() == nil {
@@ -205,6 +340,85 @@ func FetchBlobs(ctx context.Context, cfg *Config, blobs BlobsInfo, options ...Fe
 	return ctx.Err()
 }
 
+// fetchBlobsConcurrently fetches the given blobs by up to the given number of workers, blobs are picked up by
+// the workers in the given order. All workers are stopped on the first error, which is returned.
+func fetchBlobsConcurrently(ctx context.Context, blobs []*BlobFetchProgress, concurrency int,
+	fetchBlob func(ctx context.Context, bi *BlobFetchProgress) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(blobs) {
+		concurrency = len(blobs)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	blobChan := make(chan *BlobFetchProgress)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for bi := range blobChan {
+				if err := fetchBlob(ctx, bi); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+done:
+	for _, bi := range blobs {
+		select {
+		case blobChan <- bi:
+		case <-ctx.Done():
+			break done
+		}
+	}
+	close(blobChan)
+	wg.Wait()
+	return firstErr
+}
+
+// retryFetch calls the given fetch function until it succeeds, fails with a permanent error or the number of
+// attempts is exhausted.
+func retryFetch(ctx context.Context, attempts int, backoff time.Duration, fetch func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fetch()
+		if err == nil || ctx.Err() != nil || attempt >= attempts || isPermanentFetchError(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxFetchRetryBackoff {
+			backoff = maxFetchRetryBackoff
+		}
+	}
+}
+
+// isPermanentFetchError returns true if the error cannot be fixed by retrying the blob fetch.
+func isPermanentFetchError(err error) bool {
+	if errdefs.IsNotFound(err) || errdefs.IsInvalidArgument(err) || errdefs.IsFailedPrecondition(err) {
+		return true
+	}
+	var statusErr remoteserrors.ErrUnexpectedStatus
+	if errors.As(err, &statusErr) {
+		// Client errors except for the request timeout and throttling are permanent
+		return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 &&
+			statusErr.StatusCode != http.StatusRequestTimeout && statusErr.StatusCode != http.StatusTooManyRequests
+	}
+	return false
+}
+
 func checkAndUpdateBlobStatus(ctx context.Context, fetchProgress *FetchProgress, ls content.Store, sr progress.Reporter[FetchProgress]) {
 	for _, b := range fetchProgress.Blobs {
 		if b.State == BlobOk {
@@ -265,6 +479,17 @@ func NewReadMonitor(ctx context.Context, r io.ReadSeekCloser, b *BlobFetchProgre
 	}
 }
 
+// withReader returns the monitor of the given reader that reports the read statistics to this monitor,
+// so reads of several concurrent readers of the same blob are accounted together.
+func (r *readMonitor) withReader(rd io.ReadSeekCloser) *readMonitor {
+	return &readMonitor{
+		ReadSeekCloser: rd,
+		ctx:            r.ctx,
+		b:              r.b,
+		statChan:       r.statChan,
+	}
+}
+
 func (r *readMonitor) Read(p []byte) (n int, err error) {
 	readStartTime := time.Now()
 	n, err = r.ReadSeekCloser.Read(p)
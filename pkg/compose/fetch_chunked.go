@@ -0,0 +1,153 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/containerd/containerd/content"
+)
+
+const (
+	DefaultFetchChunks = 4 // Default number of concurrent range requests to fetch a large blob
+)
+
+type (
+	// chunkedFetcher fetches a blob by several concurrent range requests. The ranges are written to a sparse
+	// temporary file at their offsets in the blob, then the file is copied to the local store, which verifies
+	// the blob size and digest.
+	chunkedFetcher struct {
+		provider    BlobProvider
+		store       content.Store
+		tmpDir      string
+		chunks      int
+		rateLimiter *RateLimiter
+		retry       func(ctx context.Context, fetch func() error) error
+	}
+
+	blobChunk struct {
+		start   int64
+		size    int64
+		fetched int64
+	}
+)
+
+// WithChunkedFetch makes FetchBlobs fetch each blob that has at least the threshold number of bytes left to fetch
+// by the given number of concurrent range requests. It is applied only to blobs fetched from a registry.
+// The chunks are assembled in a temporary file in the store root, so a blob takes twice its size in the storage
+// until it is committed to the store.
+func WithChunkedFetch(threshold int64, chunks int) FetchOption {
+	return func(opts *FetchOptions) {
+		opts.ChunkedFetchThreshold = threshold
+		opts.ChunkedFetchChunks = chunks
+	}
+}
+
+// splitBlobIntoChunks splits the part of a blob of the given size that starts at the given offset into
+// the given number of chunks.
+func splitBlobIntoChunks(offset int64, size int64, n int) []*blobChunk {
+	remaining := size - offset
+	if n < 1 {
+		n = 1
+	}
+	if int64(n) > remaining {
+		n = int(remaining)
+	}
+	var chunks []*blobChunk
+	chunkSize := remaining / int64(n)
+	for i := 0; i < n; i++ {
+		c := &blobChunk{start: offset + int64(i)*chunkSize, size: chunkSize}
+		if i == n-1 {
+			c.size = size - c.start
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks
+}
+
+// fetch fetches the blob part starting at the given offset, the preceding part is expected to be ingested
+// into the local store already.
+func (f *chunkedFetcher) fetch(ctx context.Context, bi *BlobFetchProgress, offset int64) error {
+	tmpFile, err := os.CreateTemp(f.tmpDir, ".chunked-"+bi.Descriptor.Digest.Encoded()+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create file for blob chunks: %w", err)
+	}
+	defer func() {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+	}()
+
+	rm := NewReadMonitor(ctx, nil, bi)
+	rm.Start()
+	if err := f.fetchChunks(ctx, bi, rm, tmpFile, splitBlobIntoChunks(offset, bi.Descriptor.Size, f.chunks)); err != nil {
+		rm.Stop()
+		return err
+	}
+	rm.Stop()
+
+	// The local store seeks to the ingested offset of the blob and verifies its digest once the copying is done
+	if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := CopyBlob(ctx, tmpFile, bi.Ref(), *bi.Descriptor, f.store, true); err != nil {
+		return fmt.Errorf("failed to fetch blob %s: %w", bi.Descriptor.Digest, err)
+	}
+	return nil
+}
+
+func (f *chunkedFetcher) fetchChunks(ctx context.Context, bi *BlobFetchProgress, rm *readMonitor, w io.WriterAt, chunks []*blobChunk) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for _, c := range chunks {
+		wg.Add(1)
+		go func(c *blobChunk) {
+			defer wg.Done()
+			// Each next attempt requests only the part of the chunk which has not been fetched yet
+			if err := f.retry(ctx, func() error { return f.fetchChunk(ctx, bi, rm, w, c) }); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(c)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func (f *chunkedFetcher) fetchChunk(ctx context.Context, bi *BlobFetchProgress, rm *readMonitor, w io.WriterAt, c *blobChunk) error {
+	r, err := f.provider.GetReadCloser(ctx, WithRef(bi.Ref()), WithDescriptor(*bi.Descriptor), WithSecureReadOff())
+	if err != nil {
+		return fmt.Errorf("failed to initiate request to fetch blob %s: %w", bi.Descriptor.Digest, err)
+	}
+	defer r.Close()
+	chunkReader, ok := r.(io.ReadSeekCloser)
+	if !ok {
+		return fmt.Errorf("blob fetch reader for %s does not implement io.ReadSeekCloser", bi.Ref())
+	}
+	// The reader requests the blob from the offset it is positioned at
+	if _, err := chunkReader.Seek(c.start+c.fetched, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek blob %s: %w", bi.Descriptor.Digest, err)
+	}
+	if f.rateLimiter != nil {
+		chunkReader = f.rateLimiter.NewReader(ctx, chunkReader)
+	}
+	n, err := io.Copy(io.NewOffsetWriter(w, c.start+c.fetched), io.LimitReader(rm.withReader(chunkReader), c.size-c.fetched))
+	c.fetched += n
+	if err == nil && c.fetched < c.size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("failed to fetch chunk of blob %s at offset %d: %w", bi.Descriptor.Digest, c.start+c.fetched, err)
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/content/local"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type (
+	// flakyBlobProvider provides the blob readers which fail in the middle of reading once
+	flakyBlobProvider struct {
+		BlobProvider
+		data     []byte
+		failures int32
+	}
+	flakyReader struct {
+		*bytes.Reader
+		provider *flakyBlobProvider
+	}
+)
+
+func (p *flakyBlobProvider) GetReadCloser(ctx context.Context, opts ...SecureReadOptions) (io.ReadCloser, error) {
+	return &flakyReader{Reader: bytes.NewReader(p.data), provider: p}, nil
+}
+
+func (r *flakyReader) Read(b []byte) (int, error) {
+	if len(b) > 100 {
+		b = b[:100]
+	}
+	n, err := r.Reader.Read(b)
+	if atomic.AddInt32(&r.provider.failures, -1) == 0 {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *flakyReader) Close() error {
+	return nil
+}
+
+func TestChunkedFetch(t *testing.T) {
+	chunks := splitBlobIntoChunks(10, 110, 3)
+	if len(chunks) != 3 || chunks[0].start != 10 || chunks[1].start != 43 || chunks[2].start != 76 || chunks[2].size != 34 {
+		t.Errorf("unexpected chunks: %+v %+v %+v", *chunks[0], *chunks[1], *chunks[2])
+	}
+	if chunks := splitBlobIntoChunks(8, 10, 4); len(chunks) != 2 {
+		t.Errorf("expected one byte chunks, got %d chunks", len(chunks))
+	}
+
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(data)
+	desc := ocispec.Descriptor{
+		Digest: digest.FromBytes(data),
+		Size:   int64(len(data)),
+		URLs:   []string{"registry.example.com/factory/app@" + digest.FromBytes(data).String()},
+	}
+	bi := &BlobFetchProgress{BlobInfo: BlobInfo{Descriptor: &desc}}
+
+	storeRoot := t.TempDir()
+	ls, err := local.NewStore(storeRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &chunkedFetcher{
+		provider: &flakyBlobProvider{data: data, failures: 10},
+		store:    ls,
+		tmpDir:   storeRoot,
+		chunks:   4,
+		retry: func(ctx context.Context, fetch func() error) error {
+			return retryFetch(ctx, 2, time.Millisecond, fetch)
+		},
+	}
+	if err := f.fetch(context.Background(), bi, 0); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := content.ReadBlob(context.Background(), ls, desc); err != nil || !bytes.Equal(b, data) {
+		t.Errorf("fetched blob does not match the source data: %v", err)
+	}
+}
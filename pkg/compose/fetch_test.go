@@ -0,0 +1,117 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/errdefs"
+	remoteserrors "github.com/containerd/containerd/remotes/errors"
+)
+
+func TestFetchBlobsConcurrently(t *testing.T) {
+	var blobs []*BlobFetchProgress
+	for i := 0; i < 20; i++ {
+		blobs = append(blobs, &BlobFetchProgress{})
+	}
+
+	var (
+		running    int32
+		maxRunning int32
+		mu         sync.Mutex
+		fetched    = map[*BlobFetchProgress]bool{}
+	)
+	err := fetchBlobsConcurrently(context.Background(), blobs, 4, func(ctx context.Context, bi *BlobFetchProgress) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		fetched[bi] = true
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != len(blobs) {
+		t.Errorf("expected %d blobs fetched, got %d", len(blobs), len(fetched))
+	}
+	if maxRunning > 4 {
+		t.Errorf("expected at most 4 concurrent fetches, got %d", maxRunning)
+	}
+
+	errFetch := errors.New("fetch failed")
+	var started int32
+	err = fetchBlobsConcurrently(context.Background(), blobs, 2, func(ctx context.Context, bi *BlobFetchProgress) error {
+		if atomic.AddInt32(&started, 1) == 1 {
+			return errFetch
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, errFetch) {
+		t.Errorf("expected the first fetch error, got: %v", err)
+	}
+	if started >= int32(len(blobs)) {
+		t.Errorf("expected fetching to stop on the first error, %d blobs were started", started)
+	}
+}
+
+func TestIsPermanentFetchError(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		permanent bool
+	}{
+		{fmt.Errorf("content not found: %w", errdefs.ErrNotFound), true},
+		{fmt.Errorf("unexpected commit digest: %w", errdefs.ErrFailedPrecondition), true},
+		{remoteserrors.ErrUnexpectedStatus{StatusCode: http.StatusUnauthorized}, true},
+		{remoteserrors.ErrUnexpectedStatus{StatusCode: http.StatusTooManyRequests}, false},
+		{fmt.Errorf("failed: %w", remoteserrors.ErrUnexpectedStatus{StatusCode: http.StatusBadGateway}), false},
+		{fmt.Errorf("failed to fetch blob: %w", io.ErrUnexpectedEOF), false},
+		{syscall.ECONNRESET, false},
+	} {
+		if isPermanentFetchError(tc.err) != tc.permanent {
+			t.Errorf("unexpected classification of %q, expected permanent: %v", tc.err, tc.permanent)
+		}
+	}
+}
+
+func TestRetryFetch(t *testing.T) {
+	for _, tc := range []struct {
+		attempts int
+		err      error
+		calls    int
+	}{
+		{attempts: 3, err: io.ErrUnexpectedEOF, calls: 3},
+		{attempts: 1, err: io.ErrUnexpectedEOF, calls: 1},
+		// Less than one attempt is treated as a single attempt
+		{attempts: 0, err: io.ErrUnexpectedEOF, calls: 1},
+		{attempts: -1, err: io.ErrUnexpectedEOF, calls: 1},
+		{attempts: 3, err: fmt.Errorf("content not found: %w", errdefs.ErrNotFound), calls: 1},
+	} {
+		calls := 0
+		err := retryFetch(context.Background(), tc.attempts, 0, func() error {
+			calls++
+			return tc.err
+		})
+		if !errors.Is(err, tc.err) {
+			t.Errorf("expected error %q, got %v", tc.err, err)
+		}
+		if calls != tc.calls {
+			t.Errorf("expected %d fetch calls for %d attempts and error %q, got %d", tc.calls, tc.attempts, tc.err, calls)
+		}
+	}
+}
@@ -34,6 +34,8 @@ type (
 		RefWithDigest      bool
 		ProgressReporter   progress.Reporter[LoadImageProgress]
 		ProgressCallback   ProgressCallbackFn
+		// URIs of the app images to load, all app images are loaded if not set
+		Images map[string]bool
 	}
 
 	LoadImageOption func(*LoadImageOptions)
@@ -100,6 +102,17 @@ func WithBlobReadingFromStore() LoadImageOption {
 		options.ReadBlobsFromStore = true
 	}
 }
+
+// WithImages makes LoadImages load only the app images with the given URIs.
+func WithImages(imageURIs ...string) LoadImageOption {
+	return func(options *LoadImageOptions) {
+		options.Images = map[string]bool{}
+		for _, uri := range imageURIs {
+			options.Images[uri] = true
+		}
+	}
+}
+
 func WithRefWithDigest() LoadImageOption {
 	return func(options *LoadImageOptions) {
 		options.RefWithDigest = true
@@ -153,6 +166,9 @@ func LoadImages(ctx context.Context,
 	if err != nil {
 		return fmt.Errorf("failed to generate image load manifests: %w", err)
 	}
+	if len(imageLoadManifests) == 0 {
+		return nil
+	}
 
 	var blobPaths []string
 	if !options.ReadBlobsFromStore {
@@ -230,6 +246,9 @@ func generateImageLoadingManifestForApp(ctx context.Context, app App, blobsRoot
 	layersMap = make(map[string]string)
 	// Generate the image load manifests
 	for _, imageRoot := range app.GetComposeRoot().Children {
+		if options.Images != nil && !options.Images[imageRoot.Ref()] {
+			continue
+		}
 		// Generate the image load manifest
 		manifest, imageConfig, manifestErr := generateImageLoadManifest(ctx, imageRoot, blobsRoot, options)
 		if manifestErr != nil {
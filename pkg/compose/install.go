@@ -2,12 +2,20 @@ package compose
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	composetypes "github.com/compose-spec/compose-go/types"
 	"github.com/containerd/containerd/platforms"
+	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/foundriesio/composeapp/internal/progress"
+	"golang.org/x/sys/unix"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 )
 
 type (
@@ -27,10 +35,32 @@ type (
 
 	InstallOptions struct {
 		ProgressReporter progress.Reporter[InstallProgress]
+		ProgressCallback InstallProgressFunc
 		LoadedImages     map[string]struct{}
+		ReplacedProjects map[string]struct{}
+		CreatedProjects  map[string]struct{}
+		IgnoreConflicts  bool
+		TargetApps       []string
+		StrictDeviceVars bool
 	}
 
 	InstallOption func(*InstallOptions)
+
+	// composeInstallResult tells how the installation has changed the app compose project directory
+	composeInstallResult int
+)
+
+const (
+	composeUnchanged composeInstallResult = iota
+	composeCreated
+	composeReplaced
+)
+
+const (
+	AppComposePrevDirSuffix    = ".prev"
+	appComposeStagingDirSuffix = ".staging"
+	// The file listing the entries of the app project directory carried over to its new version by the last installation
+	appComposeCarriedFileSuffix = ".carried"
 )
 
 const (
@@ -42,7 +72,7 @@ const (
 func WithInstallProgress(pf InstallProgressFunc) InstallOption {
 	return func(o *InstallOptions) {
 		o.ProgressReporter = progress.NewReporter[InstallProgress](2)
-		o.ProgressReporter.Start(pf)
+		o.ProgressCallback = pf
 	}
 }
 func WithLoadedImages(li map[string]struct{}) InstallOption {
@@ -51,6 +81,93 @@ func WithLoadedImages(li map[string]struct{}) InstallOption {
 	}
 }
 
+// WithReplacedProjects makes Install record names of the apps which compose projects were replaced
+// by the installation, so their previous versions can be restored by RestorePrevCompose.
+func WithReplacedProjects(rp map[string]struct{}) InstallOption {
+	return func(o *InstallOptions) {
+		o.ReplacedProjects = rp
+	}
+}
+
+// WithCreatedProjects makes Install record names of the apps which compose projects were installed
+// by the installation with no previous project to replace, so they can be removed by RemoveCompose.
+func WithCreatedProjects(cp map[string]struct{}) InstallOption {
+	return func(o *InstallOptions) {
+		o.CreatedProjects = cp
+	}
+}
+
+// WithIgnoreConflicts makes Install skip checking whether the app claims the same host resources
+// as the other installed apps.
+func WithIgnoreConflicts(ignore bool) InstallOption {
+	return func(o *InstallOptions) {
+		o.IgnoreConflicts = ignore
+	}
+}
+
+// WithStrictDeviceVars makes Install fail if the app references device variables that are not defined
+// and have no default value. The strict mode is enabled for all installations if IsStrictDeviceVarsMode is true.
+func WithStrictDeviceVars(strict bool) InstallOption {
+	return func(o *InstallOptions) {
+		o.StrictDeviceVars = strict
+	}
+}
+
+// WithTargetApps specifies URIs of all apps that are going to run once the installation is completed,
+// CheckInstall checks the apps for conflicts against them instead of the currently installed apps.
+// Install does not check the app for conflicts if the target apps are specified, since all of them
+// are supposed to be checked together by CheckInstall beforehand.
+func WithTargetApps(appURIs []string) InstallOption {
+	return func(o *InstallOptions) {
+		o.TargetApps = appURIs
+	}
+}
+
+// CheckInstall checks whether the given apps can be installed without making any changes on the device:
+// the compose override and the policy are checked for each app, and the apps are checked for conflicts
+// unless WithIgnoreConflicts is specified. It allows a caller to verify the apps before it starts
+// changing the device state, e.g. taking the snapshots of the app volumes.
+func CheckInstall(ctx context.Context, cfg *Config, appURIs []string, options ...InstallOption) error {
+	opts := InstallOptions{}
+	for _, o := range options {
+		o(&opts)
+	}
+	cs, err := cfg.AppStoreFactory()
+	if err != nil {
+		return fmt.Errorf("failed to create app store instance: %w", err)
+	}
+	projects := map[string]*composetypes.Project{}
+	for _, appURI := range appURIs {
+		app, err := cfg.AppLoader.LoadAppTree(ctx, cs, platforms.OnlyStrict(cfg.Platform), appURI)
+		if err != nil {
+			return fmt.Errorf("failed to load app %s: %w", appURI, err)
+		}
+		if projects[app.Name()], err = checkInstall(ctx, cfg, cs, app); err != nil {
+			return err
+		}
+	}
+	if opts.IgnoreConflicts {
+		return nil
+	}
+	return checkInstallConflicts(ctx, cfg, cs, projects, appURIs, opts.TargetApps)
+}
+
+// checkInstall checks the compose override and the policy of the app, and returns its compose project,
+// so it is loaded only once for all checks.
+func checkInstall(ctx context.Context, cfg *Config, cs AppStore, app App) (*composetypes.Project, error) {
+	proj, err := app.GetCompose(ctx, cs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load compose project of %s: %w", app.Ref().String(), err)
+	}
+	if err := checkAppComposeOverride(cfg, app, proj); err != nil {
+		return nil, err
+	}
+	if err := CheckAppPolicy(cfg, app.Name(), proj); err != nil {
+		return nil, err
+	}
+	return proj, nil
+}
+
 func Install(ctx context.Context,
 	cfg *Config,
 	appURI string,
@@ -66,10 +183,22 @@ func Install(ctx context.Context,
 	}
 	app, err := cfg.AppLoader.LoadAppTree(ctx, cs, platforms.OnlyStrict(cfg.Platform), appURI)
 	if err != nil {
-		return fmt.Errorf("failed to load app %s: %w", app, err)
+		return fmt.Errorf("failed to load app %s: %w", appURI, err)
+	}
+	proj, err := checkInstall(ctx, cfg, cs, app)
+	if err != nil {
+		return err
+	}
+	// The apps installed along with the target ones are checked for conflicts together by CheckInstall
+	if !opts.IgnoreConflicts && opts.TargetApps == nil {
+		if err := checkProjectsConflicts(ctx, cfg, map[string]*composetypes.Project{app.Name(): proj}, true); err != nil {
+			return err
+		}
 	}
 
 	if opts.ProgressReporter != nil {
+		opts.ProgressReporter.Start(opts.ProgressCallback)
+		defer opts.ProgressReporter.Stop(true)
 		// TODO: Implement progress reporting for app compose installation
 		opts.ProgressReporter.Update(InstallProgress{
 			AppInstallState: AppInstallStateComposeInstalling,
@@ -106,21 +235,33 @@ func Install(ctx context.Context,
 		loadImageOptions = append(loadImageOptions, withProgressOpt)
 	}
 
-	loadImageOptionsRequiringPatch := append(loadImageOptions, WithRefWithDigest(), WithBlobReadingFromStore())
-	// Try to load app images with reading blobs directly from the store and specifying image digests (URI with hashes)
-	err = LoadImages(ctx, cli, app, cfg.GetBlobsRoot(), loadImageOptionsRequiringPatch...)
+	deviceVars, err := resolveAppDeviceVars(ctx, cfg, cs, app, opts.StrictDeviceVars || IsStrictDeviceVarsMode())
 	if err != nil {
-		// Retry loading images without reading blobs directly from the store and specifying the digest,
-		// in case if the docker daemon is not patch with the Foundries patches
-		err = LoadImages(ctx, cli, app, cfg.GetBlobsRoot(), loadImageOptions...)
+		return err
 	}
-	if err != nil {
-		return fmt.Errorf("failed to load images for app %s: %w", app.Ref().String(), err)
+
+	if imagesToLoad, err := GetImagesToLoad(ctx, cfg, app); err != nil {
+		return err
+	} else if len(imagesToLoad) < len(app.GetComposeRoot().Children) {
+		// Some images were loaded by the low-storage fetch and their layers are not in the store anymore
+		loadImageOptions = append(loadImageOptions, WithImages(imagesToLoad...))
+	}
+	if err := loadAppImages(ctx, cfg, cli, app, loadImageOptions...); err != nil {
+		return err
 	}
 
-	if err := InstallCompose(ctx, app, cs, cfg.ComposeRoot); err != nil {
+	result, err := installCompose(ctx, app, cs, cfg.ComposeRoot, WithAllowedExtraFiles(cfg.AllowedBundleExtraFiles...))
+	if err != nil {
 		return err
 	}
+	if result == composeReplaced && opts.ReplacedProjects != nil {
+		opts.ReplacedProjects[app.Name()] = struct{}{}
+	} else if result == composeCreated && opts.CreatedProjects != nil {
+		opts.CreatedProjects[app.Name()] = struct{}{}
+	}
+	if err := writeAppDeviceEnv(cfg, app.Name(), deviceVars, result == composeReplaced); err != nil {
+		return fmt.Errorf("failed to render device variables of %s: %w", app.Name(), err)
+	}
 	if opts.ProgressReporter != nil {
 		// TODO: Implement progress reporting for app compose installation checking
 		opts.ProgressReporter.Update(InstallProgress{
@@ -128,7 +269,8 @@ func Install(ctx context.Context,
 			AppID:           app.Ref().String(),
 		})
 	}
-	if checkErrMap, err := app.CheckComposeInstallation(ctx, cs, cfg.GetAppComposeDir(app.Name())); err != nil {
+	if checkErrMap, err := app.CheckComposeInstallation(ctx, cs, cfg.GetAppComposeDir(app.Name()),
+		WithAllowedExtraFiles(cfg.AllowedBundleExtraFiles...)); err != nil {
 		return err
 	} else if len(checkErrMap) > 0 {
 		// TODO: remove prints and return error map
@@ -141,11 +283,223 @@ func Install(ctx context.Context,
 	return err
 }
 
+// checkInstallConflicts checks the compose projects of the apps being installed for conflicts against the target apps
+// if specified, otherwise against the installed apps.
+func checkInstallConflicts(ctx context.Context, cfg *Config, cs AppStore, projects map[string]*composetypes.Project,
+	appURIs []string, targetApps []string) error {
+	if targetApps == nil {
+		return checkProjectsConflicts(ctx, cfg, projects, true)
+	}
+	loaded := map[string]bool{}
+	for _, appURI := range appURIs {
+		loaded[appURI] = true
+	}
+	for _, appURI := range targetApps {
+		if loaded[appURI] {
+			continue
+		}
+		targetApp, err := cfg.AppLoader.LoadAppTree(ctx, cs, platforms.OnlyStrict(cfg.Platform), appURI)
+		if err != nil {
+			return fmt.Errorf("failed to load app %s: %w", appURI, err)
+		}
+		if projects[targetApp.Name()], err = targetApp.GetCompose(ctx, cs); err != nil {
+			return fmt.Errorf("failed to load compose project of %s: %w", targetApp.Name(), err)
+		}
+	}
+	return checkProjectsConflicts(ctx, cfg, projects, false)
+}
+
+// LoadAppImages loads the app images into docker reading their blobs from the store.
+func LoadAppImages(ctx context.Context, cfg *Config, app App, loadImageOptions ...LoadImageOption) error {
+	cli, err := GetDockerClient(cfg.DockerHost)
+	if err != nil {
+		return err
+	}
+	return loadAppImages(ctx, cfg, cli, app, loadImageOptions...)
+}
+
+func loadAppImages(ctx context.Context, cfg *Config, cli *client.Client, app App, loadImageOptions ...LoadImageOption) error {
+	loadImageOptionsRequiringPatch := append(loadImageOptions, WithRefWithDigest(), WithBlobReadingFromStore())
+	// Try to load app images with reading blobs directly from the store and specifying image digests (URI with hashes)
+	err := LoadImages(ctx, cli, app, cfg.GetBlobsRoot(), loadImageOptionsRequiringPatch...)
+	if err != nil {
+		// Retry loading images without reading blobs directly from the store and specifying the digest,
+		// in case if the docker daemon is not patch with the Foundries patches
+		err = LoadImages(ctx, cli, app, cfg.GetBlobsRoot(), loadImageOptions...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load images for app %s: %w", app.Ref().String(), err)
+	}
+	return nil
+}
+
+// InstallCompose installs the app compose project into the `<composeRoot>/<app name>` directory.
+// The app bundle is extracted into a staging directory and verified against the bundle index at first,
+// then the staging directory is swapped with the app project directory. The project directory of the previous
+// app version is kept as `<composeRoot>/<app name>.prev`, so it can be restored by RestorePrevCompose.
+// The entries of the project directory which are not part of the new app bundle, e.g. data written by the app
+// containers through relative bind mounts, are carried over to the new project directory, unless the new
+// app bundle does not allow them.
+// If the same app version is already installed then the installation is skipped.
 func InstallCompose(ctx context.Context, app App, provider BlobProvider, composeRoot string) error {
+	_, err := installCompose(ctx, app, provider, composeRoot)
+	return err
+}
+
+func installCompose(ctx context.Context, app App, provider BlobProvider, composeRoot string, checkOpts ...CheckComposeOption) (composeInstallResult, error) {
 	appInstallDir := path.Join(composeRoot, app.Name())
-	if err := os.MkdirAll(appInstallDir, 0755); err != nil {
+	prevDir := appInstallDir + AppComposePrevDirSuffix
+	stagingDir := path.Join(composeRoot, "."+app.Name()+appComposeStagingDirSuffix)
+	if isComposeInstalled(ctx, app, provider, appInstallDir, checkOpts...) {
+		return composeUnchanged, nil
+	}
+	if isComposeInstalled(ctx, app, provider, prevDir) {
+		// The app is being rolled back to the previous version, just swap the project directories
+		if _, err := os.Stat(appInstallDir); os.IsNotExist(err) {
+			return composeCreated, os.Rename(prevDir, appInstallDir)
+		}
+		if err := replaceCompose(ctx, app, provider, composeRoot, prevDir, stagingDir, checkOpts...); err != nil {
+			return composeUnchanged, err
+		}
+		return composeReplaced, nil
+	}
+
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return composeUnchanged, err
+	}
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		return composeUnchanged, err
+	}
+	if err := extractCompose(ctx, app, provider, stagingDir); err != nil {
+		os.RemoveAll(stagingDir)
+		return composeUnchanged, err
+	}
+	if errs, err := app.CheckComposeInstallation(ctx, provider, stagingDir); err != nil || len(errs) > 0 {
+		os.RemoveAll(stagingDir)
+		if err == nil {
+			err = &ErrComposeInstall{Errs: errs}
+		}
+		return composeUnchanged, fmt.Errorf("failed to verify extracted app bundle of %s: %w", app.Ref().String(), err)
+	}
+
+	if _, err := os.Stat(appInstallDir); os.IsNotExist(err) {
+		if err := os.Rename(stagingDir, appInstallDir); err != nil {
+			return composeUnchanged, err
+		}
+		return composeCreated, syncDir(composeRoot)
+	}
+	if err := os.RemoveAll(prevDir); err != nil {
+		return composeUnchanged, err
+	}
+	if err := replaceCompose(ctx, app, provider, composeRoot, stagingDir, prevDir, checkOpts...); err != nil {
+		return composeUnchanged, err
+	}
+	if err := os.Rename(stagingDir, prevDir); err != nil {
+		return composeUnchanged, err
+	}
+	return composeReplaced, syncDir(composeRoot)
+}
+
+// replaceCompose swaps the app project directory with the new project directory, which becomes the current one.
+// The entries carried over from the current project directory to the new one are recorded,
+// so RestorePrevCompose can carry them back.
+func replaceCompose(ctx context.Context, app App, provider BlobProvider, composeRoot string, newDir string,
+	tmpDir string, checkOpts ...CheckComposeOption) error {
+	appInstallDir := path.Join(composeRoot, app.Name())
+	carried, err := getCarriedEntries(ctx, app, provider, appInstallDir, newDir, checkOpts...)
+	if err != nil {
+		return err
+	}
+	if err := writeCarriedEntries(composeRoot, app.Name(), carried); err != nil {
+		return err
+	}
+	if err := moveEntries(appInstallDir, newDir, carried); err != nil {
+		return err
+	}
+	if err := exchangeDirs(newDir, appInstallDir, tmpDir); err != nil {
+		// The current project directory is not replaced, so return the carried entries to it
+		if moveErr := moveEntries(newDir, appInstallDir, carried); moveErr != nil {
+			return fmt.Errorf("%w; failed to return the carried entries: %w", err, moveErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// RestorePrevCompose restores the app compose project replaced by the last installation of the app.
+// The entries carried over to the replaced project by the installation are carried back.
+func RestorePrevCompose(cfg *Config, appName string) error {
+	prevDir := cfg.GetAppComposePrevDir(appName)
+	if _, err := os.Stat(prevDir); err != nil {
+		return err
+	}
+	appDir := cfg.GetAppComposeDir(appName)
+	carried, err := readCarriedEntries(cfg.ComposeRoot, appName)
+	if err != nil {
+		return err
+	}
+	if err := moveEntries(appDir, prevDir, carried); err != nil {
+		return err
+	}
+	replacedDir := path.Join(cfg.ComposeRoot, "."+appName+appComposeStagingDirSuffix)
+	if err := os.RemoveAll(replacedDir); err != nil {
+		return err
+	}
+	if err := exchangeDirs(prevDir, appDir, replacedDir); err != nil {
+		return err
+	}
+	// Move the replaced project out of the previous project location at once, so a repeated restore does not
+	// swap the projects back
+	if err := os.Rename(prevDir, replacedDir); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(replacedDir); err != nil {
+		return err
+	}
+	if err := os.Remove(getCarriedEntriesFile(cfg.ComposeRoot, appName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := restorePrevAppDeviceEnv(cfg, appName); err != nil {
+		return err
+	}
+	return syncDir(cfg.ComposeRoot)
+}
+
+// RemoveCompose removes the app compose project along with the app device env file.
+// It is used to undo the installation of an app which had no compose project installed before.
+func RemoveCompose(cfg *Config, appName string) error {
+	if err := os.RemoveAll(cfg.GetAppComposeDir(appName)); err != nil {
+		return err
+	}
+	if err := os.Remove(getCarriedEntriesFile(cfg.ComposeRoot, appName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(cfg.GetAppDeviceEnvFile(appName)); err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	return syncDir(cfg.ComposeRoot)
+}
+
+// ListComposeProjects returns names of the app compose projects installed in the compose root.
+func ListComposeProjects(cfg *Config) ([]string, error) {
+	entries, err := os.ReadDir(cfg.ComposeRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var projects []string
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") || strings.HasSuffix(e.Name(), AppComposePrevDirSuffix) {
+			continue
+		}
+		projects = append(projects, e.Name())
+	}
+	return projects, nil
+}
+
+func extractCompose(ctx context.Context, app App, provider BlobProvider, dstDir string) error {
 	tarOptions := archive.TarOptions{
 		NoLchown: true,
 	}
@@ -158,8 +512,118 @@ func InstallCompose(ctx context.Context, app App, provider BlobProvider, compose
 	}
 	defer rc.Close()
 
-	if err := archive.Untar(rc, path.Join(composeRoot, app.Name()), &tarOptions); err != nil {
+	return archive.Untar(rc, dstDir, &tarOptions)
+}
+
+// exchangeDirs swaps two directories atomically so the app project directory is always present.
+// If the filesystem does not support the exchange, then the directories are swapped by renaming them via tmpDir.
+func exchangeDirs(dirA string, dirB string, tmpDir string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, dirA, unix.AT_FDCWD, dirB, unix.RENAME_EXCHANGE)
+	if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOSYS) {
+		if err = os.Rename(dirB, tmpDir); err == nil {
+			if err = os.Rename(dirA, dirB); err == nil {
+				err = os.Rename(tmpDir, dirA)
+			}
+		}
+	}
+	if err != nil {
 		return err
 	}
+	return syncDir(path.Dir(dirB))
+}
+
+// getCarriedEntries returns the top-most entries of the current app project directory which are absent
+// from the new project directory. The entries reported by the installation check of the app are not carried,
+// since the new project would not pass the check with them.
+func getCarriedEntries(ctx context.Context, app App, provider BlobProvider, appInstallDir string, newDir string,
+	checkOpts ...CheckComposeOption) ([]string, error) {
+	errs, err := app.CheckComposeInstallation(ctx, provider, appInstallDir, checkOpts...)
+	if err != nil {
+		return nil, err
+	}
+	var entries []string
+	err = filepath.WalkDir(appInstallDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(appInstallDir, p)
+		if err != nil || relPath == "." {
+			return err
+		}
+		fi, err := os.Lstat(filepath.Join(newDir, relPath))
+		if err == nil {
+			if fi.IsDir() && d.IsDir() {
+				return nil
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		} else if _, ok := errs[filepath.ToSlash(relPath)]; !ok {
+			entries = append(entries, relPath)
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// moveEntries moves the entries from the source directory to the same location in the destination directory.
+// The entries missing in the source directory are skipped, since they could be moved by an interrupted call.
+func moveEntries(srcDir string, dstDir string, entries []string) error {
+	for _, entry := range entries {
+		if err := os.Rename(filepath.Join(srcDir, entry), filepath.Join(dstDir, entry)); err != nil {
+			if os.IsNotExist(err) {
+				if _, statErr := os.Lstat(filepath.Join(srcDir, entry)); os.IsNotExist(statErr) {
+					continue
+				}
+			}
+			return fmt.Errorf("failed to move %s of app project: %w", entry, err)
+		}
+	}
 	return nil
 }
+
+func getCarriedEntriesFile(composeRoot string, appName string) string {
+	return path.Join(composeRoot, "."+appName+appComposeCarriedFileSuffix)
+}
+
+func writeCarriedEntries(composeRoot string, appName string, entries []string) error {
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getCarriedEntriesFile(composeRoot, appName), b, 0644)
+}
+
+func readCarriedEntries(composeRoot string, appName string) ([]string, error) {
+	b, err := os.ReadFile(getCarriedEntriesFile(composeRoot, appName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []string
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("failed to read the entries carried over to the new app project: %w", err)
+	}
+	return entries, nil
+}
+
+func isComposeInstalled(ctx context.Context, app App, provider BlobProvider, dir string, checkOpts ...CheckComposeOption) bool {
+	if _, err := os.Stat(dir); err != nil {
+		return false
+	}
+	errs, err := app.CheckComposeInstallation(ctx, provider, dir, checkOpts...)
+	return err == nil && len(errs) == 0
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,200 @@
+package compose
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestInstallCompose(t *testing.T) {
+	cfg := &Config{StoreRoot: t.TempDir(), ComposeRoot: t.TempDir(), LocalRoot: t.TempDir()}
+	if err := os.MkdirAll(cfg.GetBlobsRoot(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	provider := NewStoreBlobProvider(cfg.GetBlobsRoot())
+	newApp := func(files map[string]string) *testApp {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for name, data := range files {
+			if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write([]byte(data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		d := digest.FromBytes(buf.Bytes())
+		if err := os.WriteFile(filepath.Join(cfg.GetBlobsRoot(), d.Encoded()), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return &testApp{
+			name:        "app",
+			ref:         &AppRef{Name: "app", Digest: d},
+			composeRoot: &TreeNode{Descriptor: &ocispec.Descriptor{Digest: d, Size: int64(buf.Len())}, Type: BlobTypeAppBundle},
+			bundleFiles: files,
+		}
+	}
+	install := func(app App, expected composeInstallResult) {
+		t.Helper()
+		if result, err := installCompose(context.Background(), app, provider, cfg.ComposeRoot); err != nil {
+			t.Fatal(err)
+		} else if result != expected {
+			t.Errorf("unexpected install result: got %d, want %d", result, expected)
+		}
+	}
+	checkFile := func(dir string, expected string) {
+		t.Helper()
+		if b, err := os.ReadFile(filepath.Join(dir, "docker-compose.yml")); err != nil {
+			t.Error(err)
+		} else if string(b) != expected {
+			t.Errorf("unexpected content of %s: %q, want %q", dir, b, expected)
+		}
+	}
+	appDir := cfg.GetAppComposeDir("app")
+	prevDir := cfg.GetAppComposePrevDir("app")
+
+	app1 := newApp(map[string]string{"docker-compose.yml": "v1"})
+	app2 := newApp(map[string]string{"docker-compose.yml": "v2"})
+	install(app1, composeCreated)
+	checkFile(appDir, "v1")
+	install(app1, composeUnchanged)
+
+	// The data written by the app into its project directory is carried over to the new project
+	dataFile := filepath.Join("data", "db.sqlite")
+	checkData := func(dir string) {
+		t.Helper()
+		if b, err := os.ReadFile(filepath.Join(dir, dataFile)); err != nil {
+			t.Errorf("app data is not found in %s: %s", dir, err)
+		} else if string(b) != "data" {
+			t.Errorf("unexpected app data in %s: %q", dir, b)
+		}
+		for _, d := range []string{appDir, prevDir} {
+			if _, err := os.Stat(filepath.Join(d, dataFile)); d != dir && !os.IsNotExist(err) {
+				t.Errorf("app data is left in %s: %v", d, err)
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(appDir, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(appDir, dataFile), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	install(app2, composeReplaced)
+	checkFile(appDir, "v2")
+	checkFile(prevDir, "v1")
+	checkData(appDir)
+	if err := RestorePrevCompose(cfg, "app"); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(appDir, "v1")
+	checkData(appDir)
+	if _, err := os.Stat(prevDir); !os.IsNotExist(err) {
+		t.Errorf("expected the previous project to be moved, got: %v", err)
+	}
+	if err := RestorePrevCompose(cfg, "app"); !os.IsNotExist(err) {
+		t.Errorf("expected no previous project to restore, got: %v", err)
+	}
+	checkFile(appDir, "v1")
+
+	// Rolling back to the previous version swaps the project directories
+	install(app2, composeReplaced)
+	install(app1, composeReplaced)
+	checkFile(appDir, "v1")
+	checkFile(prevDir, "v2")
+	checkData(appDir)
+
+	// The project is not touched if the extracted bundle does not pass verification
+	broken := newApp(map[string]string{"docker-compose.yml": "v3"})
+	broken.bundleFiles = map[string]string{"docker-compose.yml": "v4"}
+	if _, err := installCompose(context.Background(), broken, provider, cfg.ComposeRoot); err == nil {
+		t.Error("expected error for the bundle failing verification")
+	}
+	checkFile(appDir, "v1")
+	checkFile(prevDir, "v2")
+	if _, err := os.Stat(filepath.Join(cfg.ComposeRoot, ".app"+appComposeStagingDirSuffix)); !os.IsNotExist(err) {
+		t.Errorf("expected the staging directory to be removed, got: %v", err)
+	}
+
+	if err := RemoveCompose(cfg, "app"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(appDir); !os.IsNotExist(err) {
+		t.Errorf("expected the project to be removed, got: %v", err)
+	}
+	// A project installed after the removal is a new one
+	install(app2, composeCreated)
+}
+
+func TestCheckInstall(t *testing.T) {
+	newApp := func(name string, port string) *testApp {
+		uri := "registry.local/factory/" + name + "@" + digest.FromString(name).String()
+		ref, err := ParseAppRef(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &testApp{name: name, ref: ref,
+			proj: loadTestProject(t, name, "services:\n  web:\n    image: nginx\n    ports:\n      - "+port+":80\n")}
+	}
+	app1 := newApp("app1", "8080")
+	app2 := newApp("app2", "8080")
+	app3 := newApp("app3", "8081")
+	cfg := &Config{
+		StoreRoot:   t.TempDir(),
+		ComposeRoot: t.TempDir(),
+		LocalRoot:   t.TempDir(),
+		AppLoader: testAppLoader{
+			app1.ref.String(): app1,
+			app2.ref.String(): app2,
+			app3.ref.String(): app3,
+		},
+		AppStoreFactoryFunc: func(c *Config) (AppStore, error) {
+			return nil, nil
+		},
+	}
+
+	err := CheckInstall(context.Background(), cfg, []string{app1.ref.String(), app2.ref.String()},
+		WithTargetApps([]string{app1.ref.String(), app2.ref.String()}))
+	var conflictErr *ErrAppsConflict
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected apps conflict error, got %v", err)
+	}
+	// The compose project is loaded once for the override, policy and conflicts checks
+	if app1.getComposeCalls != 1 || app2.getComposeCalls != 1 {
+		t.Errorf("expected compose projects loaded once, got %d and %d", app1.getComposeCalls, app2.getComposeCalls)
+	}
+
+	if err := CheckInstall(context.Background(), cfg, []string{app1.ref.String(), app2.ref.String()},
+		WithTargetApps([]string{app1.ref.String(), app2.ref.String()}), WithIgnoreConflicts(true)); err != nil {
+		t.Errorf("unexpected error with conflicts ignored: %s", err)
+	}
+
+	// The target apps which are not installed are loaded to be checked against
+	if err := CheckInstall(context.Background(), cfg, []string{app3.ref.String()},
+		WithTargetApps([]string{app1.ref.String(), app3.ref.String()})); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := CheckInstall(context.Background(), cfg, []string{app1.ref.String()},
+		WithTargetApps([]string{app1.ref.String(), app2.ref.String()})); !errors.As(err, &conflictErr) {
+		t.Errorf("expected apps conflict error with a target app, got %v", err)
+	}
+
+	if err := os.WriteFile(cfg.GetPolicyFile(), []byte("mode: enforce\nrequire_mem_limit: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var policyErr *ErrPolicyViolation
+	if err := CheckInstall(context.Background(), cfg, []string{app3.ref.String()}); !errors.As(err, &policyErr) {
+		t.Errorf("expected policy violation error, got %v", err)
+	}
+}
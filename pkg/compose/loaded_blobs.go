@@ -0,0 +1,193 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// The low-storage fetch loads each image into docker right after its layers are fetched, then replaces the layers
+// in the store by small "loaded" markers, so the layers are not kept twice, compressed in the store and extracted
+// in docker. The markers are stored outside the blobs directory, so the store content stays verifiable.
+// A layer replaced by a marker is considered fetched as long as all images referencing it are present in docker.
+
+const (
+	LoadedBlobsDir = "loaded"
+)
+
+func GetLoadedBlobsRootFor(storeRoot string) string {
+	return filepath.Join(storeRoot, LoadedBlobsDir, "sha256")
+}
+
+func (c *Config) GetLoadedBlobsRoot() string {
+	return GetLoadedBlobsRootFor(c.StoreRoot)
+}
+
+// DiscardLoadedBlob replaces the blob in the store by the marker telling that the blob is loaded into docker.
+func DiscardLoadedBlob(cfg *Config, desc ocispec.Descriptor) error {
+	b, err := json.Marshal(ocispec.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size})
+	if err != nil {
+		return err
+	}
+	// The marker is written before the blob removal, so the blob is never lost without the marker
+	if err := writeFileAtomically(filepath.Join(cfg.GetLoadedBlobsRoot(), desc.Digest.Encoded()), b, 0644); err != nil {
+		return fmt.Errorf("failed to write loaded marker of blob %s: %w", desc.Digest, err)
+	}
+	if err := os.Remove(filepath.Join(cfg.GetBlobsRoot(), desc.Digest.Encoded())); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to discard loaded blob %s: %w", desc.Digest, err)
+	}
+	return nil
+}
+
+// IsBlobLoaded returns true if the blob was discarded from the store after loading it into docker.
+func IsBlobLoaded(cfg *Config, d digest.Digest) bool {
+	_, err := os.Stat(filepath.Join(cfg.GetLoadedBlobsRoot(), d.Encoded()))
+	return err == nil
+}
+
+// RemoveLoadedMarker removes the marker telling that the blob is loaded into docker, if any.
+func RemoveLoadedMarker(cfg *Config, d digest.Digest) error {
+	if err := os.Remove(filepath.Join(cfg.GetLoadedBlobsRoot(), d.Encoded())); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func hasLoadedBlobs(cfg *Config) bool {
+	entries, err := os.ReadDir(cfg.GetLoadedBlobsRoot())
+	return err == nil && len(entries) > 0
+}
+
+// GetLoadedLayers returns the layers of the given apps that were discarded from the store after loading their
+// images into docker and are not needed anymore to load any of the app images. Such layers are considered fetched.
+// A layer is not included if any app image referencing it is missing in docker, so the image can be loaded again
+// once the layer is fetched.
+func GetLoadedLayers(ctx context.Context, cfg *Config, apps []App) (map[digest.Digest]bool, error) {
+	loadedLayers := map[digest.Digest]bool{}
+	if !hasLoadedBlobs(cfg) {
+		// Skip checking the docker images if the low-storage fetch has never been used
+		return loadedLayers, nil
+	}
+	installedImages, err := GetInstalledImages(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	neededLayers := map[digest.Digest]bool{}
+	for _, app := range apps {
+		for _, imageNode := range app.GetComposeRoot().Children {
+			installed, err := checkImageInstallation(installedImages, imageNode.Ref())
+			if err != nil {
+				return nil, err
+			}
+			for _, layer := range getImageLayers(imageNode) {
+				if !installed {
+					neededLayers[layer.Descriptor.Digest] = true
+				} else if IsBlobLoaded(cfg, layer.Descriptor.Digest) {
+					loadedLayers[layer.Descriptor.Digest] = true
+				}
+			}
+		}
+	}
+	for d := range neededLayers {
+		delete(loadedLayers, d)
+	}
+	return loadedLayers, nil
+}
+
+// GetImagesToLoad returns URIs of the app images that can be loaded into docker. The images which layers were
+// discarded from the store after loading them cannot be loaded again, so they must be present in docker already.
+func GetImagesToLoad(ctx context.Context, cfg *Config, app App) ([]string, error) {
+	var imagesToLoad, loadedImages []string
+	for _, imageNode := range app.GetComposeRoot().Children {
+		discarded := false
+		for _, layer := range getImageLayers(imageNode) {
+			if _, err := os.Stat(filepath.Join(cfg.GetBlobsRoot(), layer.Descriptor.Digest.Encoded())); err != nil &&
+				IsBlobLoaded(cfg, layer.Descriptor.Digest) {
+				discarded = true
+				break
+			}
+		}
+		if discarded {
+			loadedImages = append(loadedImages, imageNode.Ref())
+		} else {
+			imagesToLoad = append(imagesToLoad, imageNode.Ref())
+		}
+	}
+	if len(loadedImages) == 0 {
+		return imagesToLoad, nil
+	}
+	installedImages, err := GetInstalledImages(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, imageURI := range loadedImages {
+		if installed, err := checkImageInstallation(installedImages, imageURI); err != nil {
+			return nil, err
+		} else if !installed {
+			return nil, fmt.Errorf("layers of image %s were discarded after loading it, but the image is missing in docker;"+
+				" the app has to be fetched again", imageURI)
+		}
+	}
+	return imagesToLoad, nil
+}
+
+func getImageLayers(imageNode *TreeNode) []*TreeNode {
+	var layers []*TreeNode
+	_ = imageNode.Walk(func(node *TreeNode, depth int) error {
+		if node.Type == BlobTypeImageLayer {
+			layers = append(layers, node)
+		}
+		return nil
+	})
+	return layers
+}
+
+// GetLowStoragePeakStoreSize returns the peak store size required to fetch the given missing blobs of the apps
+// by the low-storage fetch. The images are fetched in the order of the apps and their services, and each layer
+// is kept in the store only until all images referencing it are loaded.
+func GetLowStoragePeakStoreSize(apps []App, missingBlobs BlobsInfo) int64 {
+	var metaSize int64
+	for _, bi := range missingBlobs {
+		if bi.Type != BlobTypeImageLayer {
+			metaSize += bi.StoreSize
+		}
+	}
+
+	var images []*TreeNode
+	pending := map[digest.Digest]int{}
+	for _, app := range apps {
+		for _, imageNode := range app.GetComposeRoot().Children {
+			images = append(images, imageNode)
+			for _, layer := range getImageLayers(imageNode) {
+				pending[layer.Descriptor.Digest]++
+			}
+		}
+	}
+	var current, peak int64
+	stored := map[digest.Digest]bool{}
+	for _, imageNode := range images {
+		layers := getImageLayers(imageNode)
+		for _, layer := range layers {
+			if bi, ok := missingBlobs[layer.Descriptor.Digest]; ok && !stored[layer.Descriptor.Digest] {
+				stored[layer.Descriptor.Digest] = true
+				current += bi.StoreSize
+			}
+		}
+		if current > peak {
+			peak = current
+		}
+		for _, layer := range layers {
+			pending[layer.Descriptor.Digest]--
+			if pending[layer.Descriptor.Digest] == 0 && stored[layer.Descriptor.Digest] {
+				current -= missingBlobs[layer.Descriptor.Digest].StoreSize
+			}
+		}
+	}
+	return metaSize + peak
+}
@@ -0,0 +1,77 @@
+package compose
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestLoadedBlobs(t *testing.T) {
+	cfg := &Config{StoreRoot: t.TempDir()}
+	if err := os.MkdirAll(cfg.GetBlobsRoot(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("layer")
+	desc := ocispec.Descriptor{Digest: digest.FromBytes(data), Size: int64(len(data))}
+	blobPath := filepath.Join(cfg.GetBlobsRoot(), desc.Digest.Encoded())
+	if err := os.WriteFile(blobPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// No docker request is made if no blobs were discarded
+	if loaded, err := GetLoadedLayers(context.Background(), cfg, nil); err != nil || len(loaded) != 0 {
+		t.Fatalf("unexpected loaded layers: %v, err: %v", loaded, err)
+	}
+	if err := DiscardLoadedBlob(cfg, desc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(blobPath); !os.IsNotExist(err) {
+		t.Errorf("expected the blob to be removed from the store, got: %v", err)
+	}
+	if !IsBlobLoaded(cfg, desc.Digest) {
+		t.Error("expected the blob to be marked as loaded")
+	}
+	// Discarding is idempotent, so an interrupted low-storage fetch can be resumed
+	if err := DiscardLoadedBlob(cfg, desc); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveLoadedMarker(cfg, desc.Digest); err != nil || IsBlobLoaded(cfg, desc.Digest) {
+		t.Errorf("expected the loaded marker to be removed, err: %v", err)
+	}
+}
+
+func TestGetLowStoragePeakStoreSize(t *testing.T) {
+	missingBlobs := BlobsInfo{}
+	newNode := func(name string, blobType BlobType, size int64, children ...*TreeNode) *TreeNode {
+		d := digest.FromString(name)
+		node := &TreeNode{Descriptor: &ocispec.Descriptor{Digest: d, Size: size}, Type: blobType, Children: children}
+		missingBlobs[d] = &BlobInfo{Descriptor: node.Descriptor, Type: blobType, StoreSize: size}
+		return node
+	}
+	base := newNode("base", BlobTypeImageLayer, 100)
+	newImage := func(name string, layerSize int64) *TreeNode {
+		return newNode(name, BlobTypeImageManifest, 1,
+			newNode(name+" config", BlobTypeImageConfig, 1),
+			base,
+			newNode(name+" layer", BlobTypeImageLayer, layerSize))
+	}
+	apps := []App{
+		&testApp{name: "app1", composeRoot: newNode("app1", BlobTypeAppBundle, 2, newImage("image1", 10), newImage("image2", 50))},
+		&testApp{name: "app2", composeRoot: newNode("app2", BlobTypeAppBundle, 2, newImage("image3", 20))},
+	}
+
+	// metadata: 2 bundles + 3 manifests + 3 configs = 10,
+	// the base layer is kept until the last image is loaded, so the peak is at the second image: 100 + 50
+	if size := GetLowStoragePeakStoreSize(apps, missingBlobs); size != 10+150 {
+		t.Errorf("unexpected peak store size: %d", size)
+	}
+	// The layers already in the store do not add to the peak
+	delete(missingBlobs, base.Descriptor.Digest)
+	if size := GetLowStoragePeakStoreSize(apps, missingBlobs); size != 10+50 {
+		t.Errorf("unexpected peak store size: %d", size)
+	}
+}
@@ -0,0 +1,270 @@
+package compose
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/opencontainers/go-digest"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// RegistryMirror is a registry serving the same content as the registry it mirrors. The mirrored content
+	// is verified against its digest, so a mirror does not have to be trusted.
+	RegistryMirror struct {
+		// URL of the mirror, e.g. https://cache.local:5000, the path of the URL, if any, prefixes the API path
+		URL string `yaml:"url"`
+		// Path to a PEM file with the CA certificates to verify the mirror TLS certificate by
+		CAFile string `yaml:"ca_file"`
+	}
+
+	// RegistryMirrors defines the mirrors of registries, the mirrors are tried in the specified order,
+	// followed by the mirrored registry itself unless it is listed among its mirrors.
+	//
+	// Example of the mirrors file:
+	//
+	//	mirrors:
+	//	  hub.foundries.io:
+	//	    - url: https://cache.local:5000
+	//	      ca_file: /etc/ssl/certs/cache-ca.pem
+	//	    - url: https://hub.foundries.io
+	RegistryMirrors struct {
+		Mirrors map[string][]RegistryMirror `yaml:"mirrors"`
+	}
+
+	// mirrorsHealth tracks failures of the mirrors, so the mirrors that failed recently are tried after others.
+	mirrorsHealth struct {
+		mu          sync.Mutex
+		lastFailure map[string]time.Time
+	}
+
+	healthTrackingTripper struct {
+		base   http.RoundTripper
+		host   string
+		health *mirrorsHealth
+	}
+
+	// verifyingBody verifies the content served by a mirror against its digest once the content is read completely
+	verifyingBody struct {
+		io.ReadCloser
+		expected digest.Digest
+		verifier digest.Verifier
+		host     string
+		health   *mirrorsHealth
+	}
+)
+
+var (
+	// The health of mirrors is tracked across all registry requests made by the process
+	registryMirrorsHealth = newMirrorsHealth()
+
+	// ErrMirrorDigestMismatch is returned if the content served by a mirror does not match its digest,
+	// the mirror is considered unhealthy, so the fetch is retried from the next mirror or the registry itself.
+	ErrMirrorDigestMismatch = errors.New("content served by registry mirror does not match its digest")
+)
+
+const (
+	// RegistryMirrorsFile is the name of the file in the local root that defines the registry mirrors
+	RegistryMirrorsFile = "registry-mirrors.yml"
+
+	// A period after a mirror failure during which the mirror is considered unhealthy
+	mirrorFailureCooldown = 5 * time.Minute
+)
+
+func (c *Config) GetRegistryMirrorsFile() string {
+	return filepath.Join(c.LocalRoot, RegistryMirrorsFile)
+}
+
+// LoadRegistryMirrors returns the registry mirrors set in the config, or loaded from the registry mirrors file
+// if the config does not set them. Returns nil if no mirrors are defined.
+func LoadRegistryMirrors(cfg *Config) (*RegistryMirrors, error) {
+	if cfg.RegistryMirrors != nil {
+		return cfg.RegistryMirrors, nil
+	}
+	b, err := os.ReadFile(cfg.GetRegistryMirrorsFile())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load registry mirrors: %w", err)
+	}
+	return ParseRegistryMirrors(b)
+}
+
+func ParseRegistryMirrors(b []byte) (*RegistryMirrors, error) {
+	m := &RegistryMirrors{}
+	d := yaml.NewDecoder(strings.NewReader(string(b)))
+	d.KnownFields(true)
+	if err := d.Decode(m); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid registry mirrors: %w", err)
+	}
+	for host, mirrors := range m.Mirrors {
+		for _, mirror := range mirrors {
+			u, err := url.Parse(mirror.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+				return nil, fmt.Errorf("invalid registry mirrors: invalid URL of %s mirror: %q", host, mirror.URL)
+			}
+		}
+	}
+	return m, nil
+}
+
+// registryHosts returns the registry hosts configuration which puts the mirrors of a registry before
+// the registry itself. The resolver falls back to the next host if a request to a host fails.
+func (m *RegistryMirrors) registryHosts(base docker.RegistryHosts, authorizer docker.Authorizer,
+	client *http.Client, health *mirrorsHealth) (docker.RegistryHosts, error) {
+	mirrorHosts := map[string][]docker.RegistryHost{}
+	for registry, mirrors := range m.Mirrors {
+		for _, mirror := range mirrors {
+			u, _ := url.Parse(mirror.URL)
+			mirrorClient, err := getMirrorClient(client, mirror)
+			if err != nil {
+				return nil, err
+			}
+			transport := mirrorClient.Transport
+			if transport == nil {
+				transport = http.DefaultTransport
+			}
+			mirrorClient.Transport = &healthTrackingTripper{base: transport, host: u.Host, health: health}
+			mirrorHosts[registry] = append(mirrorHosts[registry], docker.RegistryHost{
+				Client:       mirrorClient,
+				Authorizer:   authorizer,
+				Host:         u.Host,
+				Scheme:       u.Scheme,
+				Path:         path.Join("/", u.Path, "v2"),
+				Capabilities: docker.HostCapabilityPull | docker.HostCapabilityResolve,
+			})
+		}
+	}
+
+	return func(host string) ([]docker.RegistryHost, error) {
+		hosts, err := base(host)
+		if err != nil || len(mirrorHosts[host]) == 0 {
+			return hosts, err
+		}
+		mirrors := health.sort(mirrorHosts[host])
+		for _, h := range hosts {
+			listed := false
+			for _, mirror := range mirrors {
+				if mirror.Host == h.Host {
+					listed = true
+					break
+				}
+			}
+			if !listed {
+				mirrors = append(mirrors, h)
+			}
+		}
+		return mirrors, nil
+	}, nil
+}
+
+func getMirrorClient(client *http.Client, mirror RegistryMirror) (*http.Client, error) {
+	mirrorClient := *client
+	if len(mirror.CAFile) == 0 {
+		return &mirrorClient, nil
+	}
+	t, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("cannot set CA of mirror %s for the HTTP client in use", mirror.URL)
+	}
+	pem, err := os.ReadFile(mirror.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA of mirror %s: %w", mirror.URL, err)
+	}
+	certs := x509.NewCertPool()
+	if !certs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid CA certificates found for mirror %s in %s", mirror.URL, mirror.CAFile)
+	}
+	mirrorTransport := t.Clone()
+	if mirrorTransport.TLSClientConfig == nil {
+		mirrorTransport.TLSClientConfig = &tls.Config{}
+	}
+	mirrorTransport.TLSClientConfig.RootCAs = certs
+	mirrorClient.Transport = mirrorTransport
+	return &mirrorClient, nil
+}
+
+func newMirrorsHealth() *mirrorsHealth {
+	return &mirrorsHealth{lastFailure: map[string]time.Time{}}
+}
+
+func (h *mirrorsHealth) setFailed(host string, failed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if failed {
+		h.lastFailure[host] = time.Now()
+	} else {
+		delete(h.lastFailure, host)
+	}
+}
+
+func (h *mirrorsHealth) isHealthy(host string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	lastFailure, ok := h.lastFailure[host]
+	return !ok || time.Since(lastFailure) > mirrorFailureCooldown
+}
+
+// sort returns the given mirrors with the healthy ones followed by the unhealthy ones,
+// the order of the mirrors within each group is kept.
+func (h *mirrorsHealth) sort(mirrors []docker.RegistryHost) []docker.RegistryHost {
+	sorted := append([]docker.RegistryHost{}, mirrors...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return h.isHealthy(sorted[i].Host) && !h.isHealthy(sorted[j].Host)
+	})
+	return sorted
+}
+
+func (t *healthTrackingTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		// A canceled request does not tell anything about the mirror health
+		if req.Context().Err() == nil {
+			t.health.setFailed(t.host, true)
+		}
+		return resp, err
+	}
+	t.health.setFailed(t.host, resp.StatusCode >= http.StatusInternalServerError)
+	if d, ok := getRequestedContentDigest(req); ok && resp.StatusCode == http.StatusOK {
+		resp.Body = &verifyingBody{ReadCloser: resp.Body, expected: d, verifier: d.Verifier(), host: t.host, health: t.health}
+	}
+	return resp, nil
+}
+
+// getRequestedContentDigest returns the digest of the blob or manifest requested from a registry by its digest,
+// a response to a range request cannot be verified, so no digest is returned for it.
+func getRequestedContentDigest(req *http.Request) (digest.Digest, bool) {
+	if req.Method != http.MethodGet || len(req.Header.Get("Range")) > 0 {
+		return "", false
+	}
+	kind := path.Base(path.Dir(req.URL.Path))
+	if kind != "blobs" && kind != "manifests" {
+		return "", false
+	}
+	d, err := digest.Parse(path.Base(req.URL.Path))
+	return d, err == nil
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.verifier.Write(p[:n])
+	if err == io.EOF && !b.verifier.Verified() {
+		b.health.setFailed(b.host, true)
+		return n, fmt.Errorf("%w: mirror %s, digest %s", ErrMirrorDigestMismatch, b.host, b.expected)
+	}
+	return n, err
+}
@@ -0,0 +1,149 @@
+package compose
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestRegistryMirrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	mirrors, err := ParseRegistryMirrors([]byte(`
+mirrors:
+  hub.foundries.io:
+    - url: ` + server.URL + `
+    - url: https://mirror.example.com/cache
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	health := newMirrorsHealth()
+	hosts, err := mirrors.registryHosts(docker.ConfigureDefaultRegistries(), nil, http.DefaultClient, health)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostNames := func(registry string) []string {
+		registryHosts, err := hosts(registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, h := range registryHosts {
+			names = append(names, h.Scheme+"://"+h.Host+h.Path)
+		}
+		return names
+	}
+
+	expected := []string{server.URL + "/v2", "https://mirror.example.com/cache/v2", "https://hub.foundries.io/v2"}
+	if names := hostNames("hub.foundries.io"); !reflect.DeepEqual(names, expected) {
+		t.Errorf("unexpected hosts: got %v, want %v", names, expected)
+	}
+	if names := hostNames("ghcr.io"); !reflect.DeepEqual(names, []string{"https://ghcr.io/v2"}) {
+		t.Errorf("unexpected hosts of a registry without mirrors: %v", names)
+	}
+
+	// The mirror responding with a server error is tried after the healthy mirrors
+	registryHosts, _ := hosts("hub.foundries.io")
+	resp, err := registryHosts[0].Client.Get(server.URL + "/v2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if health.isHealthy(serverURL.Host) {
+		t.Error("expected the mirror to be unhealthy after the server error")
+	}
+	expected = []string{"https://mirror.example.com/cache/v2", server.URL + "/v2", "https://hub.foundries.io/v2"}
+	if names := hostNames("hub.foundries.io"); !reflect.DeepEqual(names, expected) {
+		t.Errorf("unexpected hosts: got %v, want %v", names, expected)
+	}
+
+	if _, err := ParseRegistryMirrors([]byte("mirrors:\n  hub.foundries.io:\n    - url: cache.local:5000\n")); err == nil {
+		t.Error("expected error for mirror URL without scheme")
+	}
+}
+
+func TestRegistryMirrorDigestMismatch(t *testing.T) {
+	content := []byte("blob content")
+	contentDigest := digest.FromBytes(content)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/factory/app/blobs/"+contentDigest.String() && len(r.Header.Get("Range")) == 0 {
+			_, _ = w.Write(content)
+			return
+		}
+		_, _ = w.Write([]byte("tampered content"))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	mirrors, err := ParseRegistryMirrors([]byte("mirrors:\n  hub.foundries.io:\n    - url: " + server.URL + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	health := newMirrorsHealth()
+	hosts, err := mirrors.registryHosts(docker.ConfigureDefaultRegistries(), nil, http.DefaultClient, health)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registryHosts, err := hosts("hub.foundries.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string, rangeRequest bool) error {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		if rangeRequest {
+			req.Header.Set("Range", "bytes=0-")
+		}
+		resp, err := registryHosts[0].Client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		return err
+	}
+
+	if err := get("/v2/factory/app/blobs/"+contentDigest.String(), false); err != nil {
+		t.Errorf("unexpected error for matching content: %s", err)
+	}
+	// Neither a range response nor content requested by something other than a digest can be verified
+	if err := get("/v2/factory/app/blobs/"+contentDigest.String(), true); err != nil {
+		t.Errorf("unexpected error for range request: %s", err)
+	}
+	if err := get("/v2/factory/app/manifests/latest", false); err != nil {
+		t.Errorf("unexpected error for request by tag: %s", err)
+	}
+	if !health.isHealthy(serverURL.Host) {
+		t.Fatal("expected the mirror to be healthy")
+	}
+
+	otherDigest := digest.FromString("other content")
+	if err := get("/v2/factory/app/manifests/"+otherDigest.String(), false); !errors.Is(err, ErrMirrorDigestMismatch) {
+		t.Errorf("expected digest mismatch error, got %v", err)
+	}
+	if health.isHealthy(serverURL.Host) {
+		t.Error("expected the mirror to be unhealthy after serving mismatching content")
+	}
+}
+
+func TestNewRemoteBlobProviderFromConfigInvalidMirrors(t *testing.T) {
+	cfg := &Config{LocalRoot: t.TempDir()}
+	if err := os.WriteFile(cfg.GetRegistryMirrorsFile(), []byte("mirrors:\n  hub.foundries.io:\n    - url: cache.local:5000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRemoteBlobProviderFromConfig(cfg); err == nil {
+		t.Error("expected error for invalid registry mirrors file")
+	}
+}
@@ -0,0 +1,120 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type (
+	// ociLayoutBlobProvider reads blobs from a directory in the OCI image layout format, e.g. produced by
+	// `skopeo copy ... oci:<dir>` or `oras copy --to-oci-layout`. The blobs are looked up by digest,
+	// so the apps are referenced by their regular URIs regardless of the layout index content.
+	ociLayoutBlobProvider struct {
+		root string
+	}
+)
+
+const (
+	BlobProviderTypeOCILayout BlobProviderType = "blob-provider:oci-layout"
+
+	// SourceOCILayoutPrefix prefixes the path of a source of app blobs in the OCI image layout format
+	SourceOCILayoutPrefix = "oci:"
+)
+
+// NewOCILayoutBlobProvider returns the provider of blobs stored in the OCI image layout directory.
+func NewOCILayoutBlobProvider(root string) (BlobProvider, error) {
+	b, err := os.ReadFile(filepath.Join(root, ocispec.ImageLayoutFile))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an OCI image layout: %w", root, err)
+	}
+	var layout ocispec.ImageLayout
+	if err := json.Unmarshal(b, &layout); err != nil {
+		return nil, fmt.Errorf("%s is not an OCI image layout: invalid %s: %w", root, ocispec.ImageLayoutFile, err)
+	}
+	if layout.Version != ocispec.ImageLayoutVersion {
+		return nil, fmt.Errorf("unsupported OCI image layout version %q of %s", layout.Version, root)
+	}
+	return &ociLayoutBlobProvider{root: root}, nil
+}
+
+// NewSourceBlobProvider returns the provider of blobs for the given source which is either a path to a store root
+// directory or a path to an OCI image layout directory prefixed with `oci:`.
+func NewSourceBlobProvider(source string) (BlobProvider, error) {
+	if layoutPath, ok := strings.CutPrefix(source, SourceOCILayoutPrefix); ok {
+		return NewOCILayoutBlobProvider(layoutPath)
+	}
+	return NewStoreBlobProvider(GetBlobsRootFor(source)), nil
+}
+
+func (p *ociLayoutBlobProvider) Type() BlobProviderType {
+	return BlobProviderTypeOCILayout
+}
+
+func (p *ociLayoutBlobProvider) getBlobPath(d digest.Digest) string {
+	return filepath.Join(p.root, ocispec.ImageBlobsDir, d.Algorithm().String(), d.Encoded())
+}
+
+func (p *ociLayoutBlobProvider) GetReadCloser(ctx context.Context, opts ...SecureReadOptions) (io.ReadCloser, error) {
+	newOpts := opts
+	params := GetSecureReadParams(opts...)
+	if len(params.ExpectedDigest) == 0 {
+		if len(params.Descriptor.Digest) > 0 {
+			params.ExpectedDigest = params.Descriptor.Digest
+		} else if len(params.Ref) > 0 {
+			s, err := reference.Parse(params.Ref)
+			if err != nil {
+				return nil, err
+			}
+			params.ExpectedDigest = s.Digest()
+		} else {
+			return nil, fmt.Errorf("missing parameters: either `SecureReadOpts.Ref` or `SecureReadOpts.ExpectedDigest` should be specified")
+		}
+		newOpts = append(newOpts, WithExpectedDigest(params.ExpectedDigest))
+	}
+	if err := params.ExpectedDigest.Validate(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p.getBlobPath(params.ExpectedDigest))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("blob %s not found in OCI image layout %s: %w", params.ExpectedDigest, p.root, errdefs.ErrNotFound)
+		}
+		return nil, err
+	}
+	if params.DisableSecureRead {
+		// The file is returned as is, so the fetcher can seek to the offset to resume fetching from
+		return f, nil
+	}
+	return NewSecureReadCloser(f, newOpts...)
+}
+
+func (p *ociLayoutBlobProvider) Info(ctx context.Context, dgst digest.Digest) (content.Info, error) {
+	if err := dgst.Validate(); err != nil {
+		return content.Info{}, err
+	}
+	fi, err := os.Stat(p.getBlobPath(dgst))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return content.Info{}, fmt.Errorf("blob %s not found in OCI image layout %s: %w", dgst, p.root, errdefs.ErrNotFound)
+		}
+		return content.Info{}, err
+	}
+	return content.Info{
+		Digest:    dgst,
+		Size:      fi.Size(),
+		CreatedAt: fi.ModTime(),
+		UpdatedAt: fi.ModTime(),
+	}, nil
+}
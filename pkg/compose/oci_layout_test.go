@@ -0,0 +1,76 @@
+package compose
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestOCILayoutBlobProvider(t *testing.T) {
+	root := t.TempDir()
+	if _, err := NewSourceBlobProvider(SourceOCILayoutPrefix + root); err == nil {
+		t.Fatal("expected error for a directory without the OCI layout file")
+	}
+	if err := os.WriteFile(filepath.Join(root, ocispec.ImageLayoutFile), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("app manifest")
+	d := digest.FromBytes(data)
+	blobDir := filepath.Join(root, ocispec.ImageBlobsDir, d.Algorithm().String())
+	if err := os.MkdirAll(blobDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(blobDir, d.Encoded()), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewSourceBlobProvider(SourceOCILayoutPrefix + root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Type() != BlobProviderTypeOCILayout {
+		t.Fatalf("unexpected provider type: %s", p.Type())
+	}
+	ctx := context.Background()
+	r, err := p.GetReadCloser(ctx, WithRef("hub.foundries.io/factory/app@"+d.String()), WithExpectedSize(int64(len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(b) != string(data) {
+		t.Errorf("unexpected blob content %q, err: %v", b, err)
+	}
+
+	// The reader is seekable if secure read is off, so fetching can be resumed
+	r, err = p.GetReadCloser(ctx, WithDescriptor(ocispec.Descriptor{Digest: d, Size: int64(len(data))}), WithSecureReadOff())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.(io.ReadSeekCloser); !ok {
+		t.Error("expected seekable reader")
+	}
+	r.Close()
+
+	if info, err := p.Info(ctx, d); err != nil || info.Size != int64(len(data)) {
+		t.Errorf("unexpected blob info: %+v, err: %v", info, err)
+	}
+	missing := digest.FromString("missing")
+	if _, err := p.Info(ctx, missing); !errdefs.IsNotFound(err) {
+		t.Errorf("expected not found error, got: %v", err)
+	}
+	if _, err := p.GetReadCloser(ctx, WithExpectedDigest(missing)); !errdefs.IsNotFound(err) {
+		t.Errorf("expected not found error, got: %v", err)
+	}
+
+	// A source without the prefix is a store root
+	if p, err := NewSourceBlobProvider(root); err != nil || p.Type() != BlobProviderTypeStore {
+		t.Errorf("expected store blob provider, got: %v, err: %v", p, err)
+	}
+}
@@ -0,0 +1,187 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	composetypes "github.com/compose-spec/compose-go/types"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// AppComposeOverrideFile is the name of the device-local compose file that is merged with the app bundle's
+	// compose file when the app is started.
+	AppComposeOverrideFile = "docker-compose.override.yml"
+	// AppComposeOverrideMaxSize is the maximum size of the device-local compose override file
+	AppComposeOverrideMaxSize = 1024 * 1024
+)
+
+var (
+	ErrInvalidComposeOverride = errors.New("invalid app compose override")
+
+	// Service attributes that the device-local override must not change, since the app images and
+	// the service config hashes are defined by the published app.
+	forbiddenOverrideServiceKeys = []string{"image", "build"}
+)
+
+// GetComposeOverride returns the content of the device-local compose override of the app,
+// or nil if the app has no override.
+func GetComposeOverride(cfg *Config, appName string) ([]byte, error) {
+	if err := CheckAppName(appName); err != nil {
+		return nil, err
+	}
+	overrideFile := cfg.GetAppComposeOverrideFile(appName)
+	fi, err := os.Stat(overrideFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if fi.Size() > AppComposeOverrideMaxSize {
+		return nil, fmt.Errorf("%w: file size exceeds the maximum allowed size (%d): %s",
+			ErrInvalidComposeOverride, AppComposeOverrideMaxSize, overrideFile)
+	}
+	return os.ReadFile(overrideFile)
+}
+
+// SetComposeOverride validates the given device-local compose override against each of the given app versions
+// and stores it in the app's local directory, replacing the existing one if any.
+func SetComposeOverride(ctx context.Context, cfg *Config, appName string, override []byte, apps ...App) error {
+	if err := CheckAppName(appName); err != nil {
+		return err
+	}
+	if len(override) > AppComposeOverrideMaxSize {
+		return fmt.Errorf("%w: size exceeds the maximum allowed size (%d)", ErrInvalidComposeOverride, AppComposeOverrideMaxSize)
+	}
+	if len(apps) > 0 {
+		provider, err := cfg.AppStoreFactory()
+		if err != nil {
+			return err
+		}
+		for _, app := range apps {
+			project, err := app.GetCompose(ctx, provider)
+			if err != nil {
+				return fmt.Errorf("failed to load compose project of %s: %w", app.Ref().String(), err)
+			}
+			if err := checkComposeOverrideForApp(app, project, override); err != nil {
+				return err
+			}
+		}
+	} else if err := checkComposeOverride(override, nil); err != nil {
+		return err
+	}
+	if err := checkComposeOverridePolicy(cfg, appName, override); err != nil {
+		return err
+	}
+
+	return writeFileAtomically(cfg.GetAppComposeOverrideFile(appName), override, 0644)
+}
+
+// RemoveComposeOverride removes the device-local compose override of the app if it is present.
+func RemoveComposeOverride(cfg *Config, appName string) error {
+	if err := CheckAppName(appName); err != nil {
+		return err
+	}
+	if err := os.Remove(cfg.GetAppComposeOverrideFile(appName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// CheckComposeOverride verifies that the device-local compose override of the app, if present,
+// is applicable to the given app version, i.e. it overrides only services defined by the app,
+// and does not violate the policy set on the device.
+func CheckComposeOverride(ctx context.Context, cfg *Config, provider BlobProvider, app App) error {
+	project, err := app.GetCompose(ctx, provider)
+	if err != nil {
+		return fmt.Errorf("failed to load compose project of %s: %w", app.Ref().String(), err)
+	}
+	return checkAppComposeOverride(cfg, app, project)
+}
+
+// checkAppComposeOverride does the same as CheckComposeOverride for the already loaded compose project of the app.
+func checkAppComposeOverride(cfg *Config, app App, project *composetypes.Project) error {
+	override, err := GetComposeOverride(cfg, app.Name())
+	if err != nil {
+		return err
+	}
+	if override == nil {
+		return nil
+	}
+	if err := checkComposeOverrideForApp(app, project, override); err != nil {
+		return fmt.Errorf("%w; override file: %s", err, cfg.GetAppComposeOverrideFile(app.Name()))
+	}
+	return checkComposeOverridePolicy(cfg, app.Name(), override)
+}
+
+func checkComposeOverrideForApp(app App, project *composetypes.Project, override []byte) error {
+	// The override may refer to services of profiles inactive on the device
+	var services []string
+	for _, s := range project.AllServices() {
+		services = append(services, s.Name)
+	}
+	if err := checkComposeOverride(override, services); err != nil {
+		return fmt.Errorf("%w; app: %s", err, app.Ref().String())
+	}
+	return nil
+}
+
+// checkComposeOverride checks the override syntax and that it overrides only the specified services,
+// if services is nil then only the syntax is checked.
+func checkComposeOverride(override []byte, services []string) error {
+	var project struct {
+		Services map[string]map[string]interface{} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(override, &project); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidComposeOverride, err.Error())
+	}
+	var overriddenServices []string
+	for name := range project.Services {
+		overriddenServices = append(overriddenServices, name)
+	}
+	sort.Strings(overriddenServices)
+	for _, name := range overriddenServices {
+		for _, key := range forbiddenOverrideServiceKeys {
+			if _, ok := project.Services[name][key]; ok {
+				return fmt.Errorf("%w: service %q: overriding %q is not allowed", ErrInvalidComposeOverride, name, key)
+			}
+		}
+		if labels, ok := project.Services[name]["labels"]; ok && hasOverrideLabel(labels, AppServiceHashLabelKey) {
+			return fmt.Errorf("%w: service %q: overriding label %q is not allowed",
+				ErrInvalidComposeOverride, name, AppServiceHashLabelKey)
+		}
+	}
+	if services == nil {
+		return nil
+	}
+	appServices := map[string]struct{}{}
+	for _, s := range services {
+		appServices[s] = struct{}{}
+	}
+	for _, name := range overriddenServices {
+		if _, ok := appServices[name]; !ok {
+			return fmt.Errorf("%w: service %q is not defined by the app", ErrInvalidComposeOverride, name)
+		}
+	}
+	return nil
+}
+
+// hasOverrideLabel checks if the label is set by compose labels, which can be defined as a map or a list
+func hasOverrideLabel(labels interface{}, label string) bool {
+	switch l := labels.(type) {
+	case map[string]interface{}:
+		_, ok := l[label]
+		return ok
+	case []interface{}:
+		for _, item := range l {
+			if s, ok := item.(string); ok && (s == label || len(s) > len(label) && s[:len(label)+1] == label+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
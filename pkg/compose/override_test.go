@@ -0,0 +1,102 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckComposeOverride(t *testing.T) {
+	services := []string{"web", "db"}
+	tests := map[string]struct {
+		override string
+		valid    bool
+	}{
+		"env and ports": {
+			override: `
+services:
+  web:
+    environment:
+      - FOO=bar
+    ports:
+      - 8081:80
+`,
+			valid: true,
+		},
+		"device mapping": {
+			override: `
+services:
+  db:
+    devices:
+      - /dev/ttyUSB0:/dev/ttyUSB0
+`,
+			valid: true,
+		},
+		"undefined service": {
+			override: `
+services:
+  cache:
+    environment:
+      - FOO=bar
+`,
+		},
+		"image override": {
+			override: `
+services:
+  web:
+    image: nginx:latest
+`,
+		},
+		"config hash label override": {
+			override: `
+services:
+  web:
+    labels:
+      - io.compose-spec.config-hash=foo
+`,
+		},
+		"invalid syntax": {
+			override: "services: [",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkComposeOverride([]byte(tc.override), services)
+			if tc.valid && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if !tc.valid && !errors.Is(err, ErrInvalidComposeOverride) {
+				t.Errorf("expected invalid override error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestComposeOverrideAppName(t *testing.T) {
+	cfg := &Config{LocalRoot: filepath.Join(t.TempDir(), "local")}
+	override := []byte("services:\n  web:\n    environment:\n      - FOO=bar\n")
+	if err := SetComposeOverride(context.Background(), cfg, "../app1", override); !errors.Is(err, ErrInvalidAppName) {
+		t.Errorf("expected invalid app name error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(cfg.LocalRoot)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(cfg.LocalRoot), "app1")); !os.IsNotExist(err) {
+		t.Errorf("override is stored outside the local root")
+	}
+	if _, err := GetComposeOverride(cfg, "app1/../.."); !errors.Is(err, ErrInvalidAppName) {
+		t.Errorf("expected invalid app name error, got %v", err)
+	}
+	if err := RemoveComposeOverride(cfg, ".."); !errors.Is(err, ErrInvalidAppName) {
+		t.Errorf("expected invalid app name error, got %v", err)
+	}
+
+	if err := SetComposeOverride(context.Background(), cfg, "app1", override); err != nil {
+		t.Fatalf("failed to set override: %s", err)
+	}
+	if b, err := GetComposeOverride(cfg, "app1"); err != nil || string(b) != string(override) {
+		t.Errorf("unexpected override: %q, error: %v", b, err)
+	}
+}
@@ -0,0 +1,257 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/compose-spec/compose-go/loader"
+	composetypes "github.com/compose-spec/compose-go/types"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	PolicyMode string
+
+	// Policy defines restrictions on the compose projects of apps, it is checked when an app is published and
+	// installed on a device. In the enforcement mode, an app violating the policy is rejected, in the audit mode,
+	// the violations are just reported.
+	//
+	// Example of the policy file:
+	//
+	//	mode: enforce
+	//	forbid_privileged: true
+	//	forbid_host_network: true
+	//	restrict_bind_mounts: true
+	//	allowed_bind_mounts:
+	//	  - /var/run/docker.sock
+	//	  - /var/lib/shared
+	//	require_mem_limit: true
+	Policy struct {
+		Mode PolicyMode `yaml:"mode"`
+		// Forbid services running in the privileged mode
+		ForbidPrivileged bool `yaml:"forbid_privileged"`
+		// Forbid services using the host network stack, i.e. `network_mode: host`
+		ForbidHostNetwork bool `yaml:"forbid_host_network"`
+		// Allow bind mounts of only the app project directory and the host paths listed in AllowedBindMounts
+		RestrictBindMounts bool     `yaml:"restrict_bind_mounts"`
+		AllowedBindMounts  []string `yaml:"allowed_bind_mounts"`
+		// Require each service to have a memory limit set by `mem_limit` or `deploy.resources.limits.memory`
+		RequireMemLimit bool `yaml:"require_mem_limit"`
+	}
+
+	PolicyViolation struct {
+		App     string `json:"app"`
+		Service string `json:"service"`
+		Rule    string `json:"rule"`
+		Message string `json:"message"`
+	}
+
+	// ErrPolicyViolation is returned if an app violates the policy enforced on a device.
+	ErrPolicyViolation struct {
+		Violations []PolicyViolation
+	}
+)
+
+const (
+	PolicyModeEnforce PolicyMode = "enforce"
+	PolicyModeAudit   PolicyMode = "audit"
+
+	// PolicyFile is the name of the file in the local root that holds the policy enforced on the device
+	PolicyFile = "policy.yml"
+
+	policyRulePrivileged  = "forbid_privileged"
+	policyRuleHostNetwork = "forbid_host_network"
+	policyRuleBindMounts  = "restrict_bind_mounts"
+	policyRuleMemLimit    = "require_mem_limit"
+)
+
+func (v PolicyViolation) String() string {
+	return fmt.Sprintf("app %s, service %s: %s (%s)", v.App, v.Service, v.Message, v.Rule)
+}
+
+func (e *ErrPolicyViolation) Error() string {
+	var violations []string
+	for _, v := range e.Violations {
+		violations = append(violations, v.String())
+	}
+	return "policy violation: " + strings.Join(violations, "; ")
+}
+
+func (c *Config) GetPolicyFile() string {
+	return filepath.Join(c.LocalRoot, PolicyFile)
+}
+
+// LoadPolicy reads the policy from the given file.
+func LoadPolicy(policyFile string) (*Policy, error) {
+	b, err := os.ReadFile(policyFile)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(b)
+}
+
+// LoadDevicePolicy returns the policy enforced on the device, or nil if no policy is set.
+func LoadDevicePolicy(cfg *Config) (*Policy, error) {
+	p, err := LoadPolicy(cfg.GetPolicyFile())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load policy: %w", err)
+	}
+	return p, nil
+}
+
+func ParsePolicy(b []byte) (*Policy, error) {
+	p := &Policy{}
+	d := yaml.NewDecoder(strings.NewReader(string(b)))
+	d.KnownFields(true)
+	if err := d.Decode(p); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	switch p.Mode {
+	case "":
+		p.Mode = PolicyModeEnforce
+	case PolicyModeEnforce, PolicyModeAudit:
+	default:
+		return nil, fmt.Errorf("invalid policy: unsupported mode %q, expected %q or %q", p.Mode, PolicyModeEnforce, PolicyModeAudit)
+	}
+	for i, allowed := range p.AllowedBindMounts {
+		if !filepath.IsAbs(allowed) {
+			return nil, fmt.Errorf("invalid policy: allowed bind mount must be an absolute path: %s", allowed)
+		}
+		p.AllowedBindMounts[i] = filepath.Clean(allowed)
+	}
+	return p, nil
+}
+
+// Check returns violations of the policy by all services of the given compose project of the app,
+// including the services of inactive profiles.
+func (p *Policy) Check(appName string, proj *composetypes.Project) []PolicyViolation {
+	var violations []PolicyViolation
+	for _, s := range proj.AllServices() {
+		violations = append(violations, p.checkService(appName, s, proj.WorkingDir, true)...)
+	}
+	sortPolicyViolations(violations)
+	return violations
+}
+
+// Apply returns ErrPolicyViolation if there are violations and the policy is enforced,
+// in the audit mode the violations are just logged as warnings.
+func (p *Policy) Apply(violations []PolicyViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	if p.Mode == PolicyModeAudit {
+		for _, v := range violations {
+			slog.Warn("policy audit", "app", v.App, "service", v.Service, "rule", v.Rule, "message", v.Message)
+		}
+		return nil
+	}
+	return &ErrPolicyViolation{Violations: violations}
+}
+
+// CheckAppPolicy checks the compose project of the app against the policy set on the device, if any.
+func CheckAppPolicy(cfg *Config, appName string, proj *composetypes.Project) error {
+	p, err := LoadDevicePolicy(cfg)
+	if err != nil || p == nil {
+		return err
+	}
+	return p.Apply(p.Check(appName, proj))
+}
+
+// checkComposeOverridePolicy checks the services of the device-local compose override against the policy set
+// on the device. The memory limit rule is not checked since the override complements the app services.
+func checkComposeOverridePolicy(cfg *Config, appName string, override []byte) error {
+	p, err := LoadDevicePolicy(cfg)
+	if err != nil || p == nil {
+		return err
+	}
+	dict, err := loader.ParseYAML(override)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidComposeOverride, err.Error())
+	}
+	services, _ := dict["services"].(map[string]interface{})
+	var violations []PolicyViolation
+	for name, sd := range services {
+		serviceDict, ok := sd.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		s, err := loader.LoadService(name, serviceDict)
+		if err != nil {
+			return fmt.Errorf("%w: service %q: %s", ErrInvalidComposeOverride, name, err.Error())
+		}
+		violations = append(violations, p.checkService(appName, *s, cfg.GetAppComposeDir(appName), false)...)
+	}
+	sortPolicyViolations(violations)
+	return p.Apply(violations)
+}
+
+func (p *Policy) checkService(appName string, s composetypes.ServiceConfig, workingDir string, checkMemLimit bool) []PolicyViolation {
+	var violations []PolicyViolation
+	violate := func(rule string, format string, args ...interface{}) {
+		violations = append(violations, PolicyViolation{
+			App:     appName,
+			Service: s.Name,
+			Rule:    rule,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	if p.ForbidPrivileged && s.Privileged {
+		violate(policyRulePrivileged, "privileged mode is not allowed")
+	}
+	if p.ForbidHostNetwork && s.NetworkMode == "host" {
+		violate(policyRuleHostNetwork, "host network mode is not allowed")
+	}
+	if p.RestrictBindMounts {
+		for _, v := range s.Volumes {
+			if v.Type != composetypes.VolumeTypeBind {
+				continue
+			}
+			source := v.Source
+			if !filepath.IsAbs(source) {
+				source = filepath.Join(workingDir, source)
+			}
+			source = filepath.Clean(source)
+			if !p.isBindMountAllowed(source, workingDir) {
+				violate(policyRuleBindMounts, "bind mount of %s is not allowed", v.Source)
+			}
+		}
+	}
+	if checkMemLimit && p.RequireMemLimit && s.MemLimit <= 0 &&
+		(s.Deploy == nil || s.Deploy.Resources.Limits == nil || s.Deploy.Resources.Limits.MemoryBytes <= 0) {
+		violate(policyRuleMemLimit, "memory limit is not set")
+	}
+	return violations
+}
+
+func (p *Policy) isBindMountAllowed(source string, workingDir string) bool {
+	if len(workingDir) > 0 && isSubPath(filepath.Clean(workingDir), source) {
+		return true
+	}
+	for _, allowed := range p.AllowedBindMounts {
+		if isSubPath(allowed, source) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortPolicyViolations(violations []PolicyViolation) {
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Service != violations[j].Service {
+			return violations[i].Service < violations[j].Service
+		}
+		if violations[i].Rule != violations[j].Rule {
+			return violations[i].Rule < violations[j].Rule
+		}
+		return violations[i].Message < violations[j].Message
+	})
+}
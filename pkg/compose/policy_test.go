@@ -0,0 +1,100 @@
+package compose
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`
+forbid_privileged: true
+forbid_host_network: true
+restrict_bind_mounts: true
+allowed_bind_mounts:
+  - /var/lib/shared/
+require_mem_limit: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Mode != PolicyModeEnforce {
+		t.Errorf("expected the enforcement mode by default, got: %s", policy.Mode)
+	}
+
+	proj := loadTestProject(t, "app1", `
+services:
+  web:
+    image: nginx
+    privileged: true
+    mem_limit: 64m
+    volumes:
+      - /var/lib/shared/web:/data
+      - ./config:/config
+      - /etc:/host-etc:ro
+  agent:
+    image: agent
+    network_mode: host
+    deploy:
+      resources:
+        limits:
+          memory: 32m
+  db:
+    image: postgres
+    profiles: [debug]
+    volumes:
+      - data:/var/lib/postgresql/data
+volumes:
+  data:
+`)
+	expected := []PolicyViolation{
+		{App: "app1", Service: "agent", Rule: policyRuleHostNetwork, Message: "host network mode is not allowed"},
+		{App: "app1", Service: "db", Rule: policyRuleMemLimit, Message: "memory limit is not set"},
+		{App: "app1", Service: "web", Rule: policyRulePrivileged, Message: "privileged mode is not allowed"},
+		{App: "app1", Service: "web", Rule: policyRuleBindMounts, Message: "bind mount of /etc is not allowed"},
+	}
+	violations := policy.Check("app1", proj)
+	if !reflect.DeepEqual(violations, expected) {
+		t.Errorf("unexpected violations:\n got: %+v\nwant: %+v", violations, expected)
+	}
+	var errViolation *ErrPolicyViolation
+	if err := policy.Apply(violations); !errors.As(err, &errViolation) || len(errViolation.Violations) != len(expected) {
+		t.Errorf("expected policy violation error, got: %v", err)
+	}
+	policy.Mode = PolicyModeAudit
+	if err := policy.Apply(violations); err != nil {
+		t.Errorf("no error expected in the audit mode, got: %s", err)
+	}
+
+	if _, err := ParsePolicy([]byte("mode: warn\n")); err == nil {
+		t.Error("expected error for unsupported mode")
+	}
+	if _, err := ParsePolicy([]byte("forbid_root: true\n")); err == nil {
+		t.Error("expected error for unknown rule")
+	}
+}
+
+func TestComposeOverridePolicy(t *testing.T) {
+	cfg := &Config{LocalRoot: t.TempDir(), ComposeRoot: "/var/sota/compose-apps"}
+	override := []byte(`
+services:
+  web:
+    privileged: true
+    volumes:
+      - ./data:/data
+`)
+	if err := checkComposeOverridePolicy(cfg, "app1", override); err != nil {
+		t.Errorf("no error expected if no policy is set, got: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.LocalRoot, PolicyFile),
+		[]byte("forbid_privileged: true\nrestrict_bind_mounts: true\nrequire_mem_limit: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var errViolation *ErrPolicyViolation
+	if err := checkComposeOverridePolicy(cfg, "app1", override); !errors.As(err, &errViolation) ||
+		len(errViolation.Violations) != 1 || errViolation.Violations[0].Rule != policyRulePrivileged {
+		t.Errorf("expected privileged mode violation, got: %v", err)
+	}
+}
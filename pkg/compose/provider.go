@@ -86,7 +86,10 @@ func (t tokenProxyTripper) RoundTrip(req *http.Request) (*http.Response, error)
 	return t.base.RoundTrip(req2)
 }
 
-func NewRemoteBlobProviderFromConfig(config *Config) BlobProvider {
+// NewRemoteBlobProviderFromConfig returns the provider of blobs fetched from registries, or from their mirrors
+// if they are defined. An invalid mirrors definition is reported as an error instead of accessing the registries
+// directly, so a misconfiguration is not silently ignored.
+func NewRemoteBlobProviderFromConfig(config *Config) (BlobProvider, error) {
 	client := NewHttpClient(config.ConnectTimeout, config.ReadTimeout)
 	var proxyConfig *ProxyConfig
 	if config.Proxy != nil {
@@ -106,7 +109,14 @@ func NewRemoteBlobProviderFromConfig(config *Config) BlobProvider {
 	}
 	authorizer := NewRegistryAuthorizer(config.DockerCfg, client)
 	resolver := NewResolver(authorizer, client)
-	return newRemoteBlobProvider(resolver)
+	if mirrors, err := LoadRegistryMirrors(config); err != nil {
+		return nil, err
+	} else if mirrors != nil {
+		if resolver, err = NewResolverWithMirrors(authorizer, client, mirrors); err != nil {
+			return nil, fmt.Errorf("failed to set up registry mirrors: %w", err)
+		}
+	}
+	return newRemoteBlobProvider(resolver), nil
 }
 
 func newRemoteBlobProvider(resolver remotes.Resolver) BlobProvider {
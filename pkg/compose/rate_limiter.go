@@ -0,0 +1,332 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+type (
+	// RateLimiter limits the overall read rate of all blobs being fetched. The limit can be adjusted while
+	// blobs are being fetched, by SetFetchRate called by any process, or by SetRate and SetSchedule
+	// called by a process that embeds the fetch.
+	RateLimiter struct {
+		mu       sync.Mutex
+		rate     int64 // global cap in bytes per second, 0 - unlimited
+		schedule []RateSchedule
+		tokens   float64
+		last     time.Time
+		now      func() time.Time
+	}
+
+	// RateSchedule caps the read rate during the specified time of a day. If End precedes Start,
+	// the schedule spans midnight.
+	RateSchedule struct {
+		Start time.Duration // offset from midnight, local time
+		End   time.Duration // offset from midnight, local time
+		Rate  int64         // bytes per second
+	}
+
+	// FetchRate is the rate limit set by SetFetchRate, in the format accepted by ParseRateLimiter
+	FetchRate struct {
+		MaxRate  string   `json:"max_rate,omitempty"`
+		Schedule []string `json:"schedule,omitempty"`
+	}
+
+	rateLimitedReader struct {
+		io.ReadSeekCloser
+		ctx     context.Context
+		limiter *RateLimiter
+	}
+)
+
+const (
+	// maximum number of bytes read at once by a rate limited reader, it makes the read rate smoother
+	rateLimitedReadChunk = 32 * 1024
+
+	FetchRateFile = "fetch-rate.json"
+	// interval between checks of the fetch rate file by an ongoing fetch
+	fetchRateCheckInterval = time.Second
+)
+
+func (c *Config) GetFetchRateFile() string {
+	return filepath.Join(c.LocalRoot, FetchRateFile)
+}
+
+// SetFetchRate sets the rate limit of the ongoing fetch and all following fetches, it overrides the limit
+// the fetches are started with. The ongoing fetch applies the limit within a second. If neither the global rate
+// nor the schedules are specified, then the fetches are limited as they were started again.
+func SetFetchRate(cfg *Config, rate string, schedule []string) error {
+	if len(rate) == 0 && len(schedule) == 0 {
+		if err := os.Remove(cfg.GetFetchRateFile()); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to reset fetch rate: %w", err)
+		}
+		return nil
+	}
+	if _, err := ParseRateLimiter(rate, schedule); err != nil {
+		return err
+	}
+	b, err := json.Marshal(&FetchRate{MaxRate: rate, Schedule: schedule})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cfg.LocalRoot, 0755); err != nil {
+		return err
+	}
+	// The file is replaced atomically, so an ongoing fetch never reads it partially written
+	tmpFile := cfg.GetFetchRateFile() + ".tmp"
+	if err := os.WriteFile(tmpFile, b, 0644); err != nil {
+		return fmt.Errorf("failed to set fetch rate: %w", err)
+	}
+	return os.Rename(tmpFile, cfg.GetFetchRateFile())
+}
+
+// LoadFetchRate returns the rate limit set by SetFetchRate, or nil if it is not set.
+func LoadFetchRate(cfg *Config) (*FetchRate, error) {
+	b, err := os.ReadFile(cfg.GetFetchRateFile())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	r := &FetchRate{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("invalid fetch rate file: %w", err)
+	}
+	return r, nil
+}
+
+func NewRateLimiter(rate int64, schedule ...RateSchedule) *RateLimiter {
+	return &RateLimiter{
+		rate:     rate,
+		schedule: schedule,
+		now:      time.Now,
+	}
+}
+
+// ParseRateLimiter creates a rate limiter from the global rate, e.g. "1MiB", and the schedules in the
+// "HH:MM-HH:MM=<rate>" format, e.g. "08:00-18:00=256KiB". Returns nil if no limit is specified.
+func ParseRateLimiter(rate string, schedule []string) (*RateLimiter, error) {
+	if len(rate) == 0 && len(schedule) == 0 {
+		return nil, nil
+	}
+	var maxRate int64
+	if len(rate) > 0 {
+		var err error
+		if maxRate, err = parseRate(rate); err != nil {
+			return nil, err
+		}
+	}
+	var schedules []RateSchedule
+	for _, s := range schedule {
+		rs, err := ParseRateSchedule(s)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, rs)
+	}
+	return NewRateLimiter(maxRate, schedules...), nil
+}
+
+// ParseRateSchedule parses the rate schedule in the "HH:MM-HH:MM=<rate>" format, e.g. "22:00-06:00=2MiB".
+func ParseRateSchedule(s string) (RateSchedule, error) {
+	period, rate, found := strings.Cut(s, "=")
+	start, end, foundEnd := strings.Cut(period, "-")
+	if !found || !foundEnd {
+		return RateSchedule{}, fmt.Errorf("invalid rate schedule %q, expected HH:MM-HH:MM=<rate>", s)
+	}
+	var rs RateSchedule
+	var err error
+	if rs.Start, err = parseTimeOfDay(start); err != nil {
+		return RateSchedule{}, fmt.Errorf("invalid rate schedule %q: %w", s, err)
+	}
+	if rs.End, err = parseTimeOfDay(end); err != nil {
+		return RateSchedule{}, fmt.Errorf("invalid rate schedule %q: %w", s, err)
+	}
+	if rs.Rate, err = parseRate(rate); err != nil {
+		return RateSchedule{}, fmt.Errorf("invalid rate schedule %q: %w", s, err)
+	}
+	return rs, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func parseRate(s string) (int64, error) {
+	rate, err := units.RAMInBytes(strings.TrimSuffix(strings.TrimSpace(s), "/s"))
+	if err != nil || rate < 0 {
+		return 0, fmt.Errorf("invalid rate %q, expected bytes per second, e.g. 512KiB or 2MiB", s)
+	}
+	return rate, nil
+}
+
+func (s RateSchedule) contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	if s.Start <= s.End {
+		return offset >= s.Start && offset < s.End
+	}
+	return offset >= s.Start || offset < s.End
+}
+
+// SetRate sets the global rate cap in bytes per second, 0 removes the cap.
+func (l *RateLimiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+}
+
+// SetSchedule replaces the time of day rate caps.
+func (l *RateLimiter) SetSchedule(schedule []RateSchedule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.schedule = schedule
+}
+
+// followFetchRate applies the rate limit set by SetFetchRate until the context is canceled. The limit the
+// limiter was created with is restored if the set limit is reset, and once the context is canceled.
+func (l *RateLimiter) followFetchRate(ctx context.Context, cfg *Config, interval time.Duration) {
+	l.mu.Lock()
+	initialRate, initialSchedule := l.rate, l.schedule
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.rate, l.schedule = initialRate, initialSchedule
+		l.mu.Unlock()
+	}()
+
+	var applied *FetchRate
+	check := func() {
+		fetchRate, err := LoadFetchRate(cfg)
+		if err != nil {
+			slog.Warn("failed to load fetch rate, keeping the current rate", "error", err)
+			return
+		}
+		if reflect.DeepEqual(fetchRate, applied) {
+			return
+		}
+		rate, schedule := initialRate, initialSchedule
+		if fetchRate != nil {
+			limiter, err := ParseRateLimiter(fetchRate.MaxRate, fetchRate.Schedule)
+			if err != nil {
+				slog.Warn("invalid fetch rate, keeping the current rate", "error", err)
+				return
+			}
+			rate, schedule = limiter.rate, limiter.schedule
+		}
+		l.mu.Lock()
+		l.rate, l.schedule = rate, schedule
+		l.mu.Unlock()
+		applied = fetchRate
+	}
+
+	check()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
+
+// Rate returns the rate cap in effect, which is the lowest of the global cap and the caps of the schedules
+// matching the current time. 0 means no cap.
+func (l *RateLimiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.currentRate(l.now())
+}
+
+func (l *RateLimiter) currentRate(now time.Time) int64 {
+	rate := l.rate
+	for _, s := range l.schedule {
+		if s.contains(now) && (rate == 0 || (s.Rate > 0 && s.Rate < rate)) {
+			rate = s.Rate
+		}
+	}
+	return rate
+}
+
+// WaitN blocks until reading of n bytes is allowed by the rate cap in effect.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := l.now()
+	rate := l.currentRate(now)
+	if rate <= 0 {
+		l.tokens = 0
+		l.last = now
+		l.mu.Unlock()
+		return nil
+	}
+	// The bucket size is a one second worth of bytes at the current rate
+	if l.last.IsZero() {
+		l.tokens = float64(rate)
+	} else {
+		l.tokens += now.Sub(l.last).Seconds() * float64(rate)
+	}
+	if l.tokens > float64(rate) {
+		l.tokens = float64(rate)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / float64(rate) * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// NewReader returns the reader which reads from the given reader not faster than the limiter allows.
+func (l *RateLimiter) NewReader(ctx context.Context, r io.ReadSeekCloser) io.ReadSeekCloser {
+	return &rateLimitedReader{
+		ReadSeekCloser: r,
+		ctx:            ctx,
+		limiter:        l,
+	}
+}
+
+func (r *rateLimitedReader) Read(p []byte) (n int, err error) {
+	if len(p) > rateLimitedReadChunk {
+		p = p[:rateLimitedReadChunk]
+	}
+	n, err = r.ReadSeekCloser.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return
+}
@@ -0,0 +1,101 @@
+package compose
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l, err := ParseRateLimiter("2MiB", []string{"08:00-18:00=256KiB", "22:00-06:00=4MiB", "12:00-13:00=128KiB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for at, expected := range map[string]int64{
+		"07:59": 2 * 1024 * 1024,
+		"08:00": 256 * 1024,
+		"12:30": 128 * 1024,
+		"23:00": 2 * 1024 * 1024, // the global cap is lower than the scheduled one
+		"03:00": 2 * 1024 * 1024,
+	} {
+		now, _ := time.Parse("15:04", at)
+		l.now = func() time.Time { return now }
+		if rate := l.Rate(); rate != expected {
+			t.Errorf("unexpected rate at %s: got %d, want %d", at, rate, expected)
+		}
+	}
+
+	l.SetRate(0)
+	now, _ := time.Parse("15:04", "23:00")
+	l.now = func() time.Time { return now }
+	if rate := l.Rate(); rate != 4*1024*1024 {
+		t.Errorf("expected the scheduled rate if no global cap, got %d", rate)
+	}
+
+	// The first second worth of bytes is allowed at once, the next ones have to wait
+	l.SetSchedule(nil)
+	l.SetRate(1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := l.WaitN(ctx, 1000); err != nil {
+		t.Errorf("no wait expected, got: %s", err)
+	}
+	if err := l.WaitN(ctx, 1000); err == nil {
+		t.Error("expected waiting for the rate limit")
+	}
+
+	for _, s := range []string{"08:00-18:00", "8-18=1MiB", "08:00-18:00=fast", "25:00-18:00=1MiB"} {
+		if _, err := ParseRateSchedule(s); err == nil {
+			t.Errorf("expected error for invalid schedule %q", s)
+		}
+	}
+}
+
+func TestFollowFetchRate(t *testing.T) {
+	cfg := &Config{LocalRoot: t.TempDir()}
+	l := NewRateLimiter(1000)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.followFetchRate(ctx, cfg, 10*time.Millisecond)
+	}()
+	waitRate := func(expected int64) {
+		t.Helper()
+		for i := 0; i < 100 && l.Rate() != expected; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if rate := l.Rate(); rate != expected {
+			t.Errorf("unexpected rate: got %d, want %d", rate, expected)
+		}
+	}
+
+	if err := SetFetchRate(cfg, "2KiB", nil); err != nil {
+		t.Fatal(err)
+	}
+	waitRate(2048)
+	if r, err := LoadFetchRate(cfg); err != nil || r == nil || r.MaxRate != "2KiB" {
+		t.Errorf("unexpected fetch rate: %+v, error: %v", r, err)
+	}
+	if err := SetFetchRate(cfg, "fast", nil); err == nil {
+		t.Error("expected error for invalid rate")
+	}
+	waitRate(2048)
+
+	// The initial rate is restored once the set rate is reset
+	if err := SetFetchRate(cfg, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	waitRate(1000)
+
+	// and once the fetch is done
+	if err := SetFetchRate(cfg, "4KiB", nil); err != nil {
+		t.Fatal(err)
+	}
+	waitRate(4096)
+	cancel()
+	<-done
+	if rate := l.Rate(); rate != 1000 {
+		t.Errorf("expected the initial rate to be restored, got %d", rate)
+	}
+}
@@ -0,0 +1,195 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+type (
+	ReconcileActionType string
+
+	ReconcileAction struct {
+		Type    ReconcileActionType `json:"type"`
+		Targets []string            `json:"targets,omitempty"`
+		Error   string              `json:"error,omitempty"`
+	}
+
+	AppReconcileReport struct {
+		URI     string             `json:"uri"`
+		Name    string             `json:"name"`
+		Actions []*ReconcileAction `json:"actions"`
+	}
+
+	ReconcileReport struct {
+		Apps []*AppReconcileReport `json:"apps"`
+	}
+
+	ReconcileOptions struct {
+		DryRun bool
+	}
+	ReconcileOption func(*ReconcileOptions)
+)
+
+const (
+	ReconcileActionComposeReinstalled           ReconcileActionType = "compose-reinstalled"
+	ReconcileActionImagesReloaded               ReconcileActionType = "images-reloaded"
+	ReconcileActionContainersRecreated          ReconcileActionType = "containers-recreated"
+	ReconcileActionComposeReinstallRequired     ReconcileActionType = "compose-reinstall-required"
+	ReconcileActionImagesReloadRequired         ReconcileActionType = "images-reload-required"
+	ReconcileActionContainersRecreationRequired ReconcileActionType = "containers-recreation-required"
+)
+
+// WithReconcileDryRun makes Reconcile only detect and report the drift without repairing it.
+func WithReconcileDryRun(dryRun bool) ReconcileOption {
+	return func(o *ReconcileOptions) {
+		o.DryRun = dryRun
+	}
+}
+
+// IsDrifted returns true if at least one action was taken or is required to bring the app to its desired state.
+func (r *AppReconcileReport) IsDrifted() bool {
+	return len(r.Actions) > 0
+}
+
+// Failed returns true if at least one of the repair actions has failed.
+func (r *ReconcileReport) Failed() bool {
+	for _, app := range r.Apps {
+		for _, action := range app.Actions {
+			if len(action.Error) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reconcile brings the specified apps, which are supposed to be running, to the state defined by their versions
+// found in the local app store. For each app it:
+//  1. reinstalls the app compose project if any of its files are modified, removed or missing;
+//  2. reloads the app images that are missing in the docker store;
+//  3. recreates the app containers that are missing or which config hash does not match the app's one.
+//
+// All actions taken are reported in the returned report. An error is returned only if it was not possible
+// to evaluate the apps state; failures of the repair actions are reported per action.
+func Reconcile(ctx context.Context, cfg *Config, appURIs []string, options ...ReconcileOption) (*ReconcileReport, error) {
+	opts := &ReconcileOptions{}
+	for _, o := range options {
+		o(opts)
+	}
+
+	appStore, err := cfg.AppStoreFactory()
+	if err != nil {
+		return nil, err
+	}
+	// Repair apps only from the local store, it does not make sense to get the app from a registry
+	apps, err := loadAppTrees(ctx, cfg, appStore, appURIs, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load app trees: %w", err)
+	}
+	installStatus, err := CheckAppsInstallStatus(ctx, cfg, appStore, apps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check apps install status: %w", err)
+	}
+	cli, err := GetDockerClient(cfg.DockerHost)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconcileReport{}
+	for _, app := range apps {
+		appReport := &AppReconcileReport{URI: app.Ref().String(), Name: app.Name()}
+		report.Apps = append(report.Apps, appReport)
+		appInstallReport := installStatus.AppsInstallStatus[app.Ref().Digest]
+
+		if action := getComposeReinstallAction(appInstallReport); action != nil {
+			if !opts.DryRun {
+				action.Type = ReconcileActionComposeReinstalled
+				if err := InstallCompose(ctx, app, appStore, cfg.ComposeRoot); err != nil {
+					action.Error = err.Error()
+				}
+			}
+			appReport.Actions = append(appReport.Actions, action)
+		}
+
+		if action := getImagesReloadAction(app, appInstallReport); action != nil {
+			if !opts.DryRun {
+				action.Type = ReconcileActionImagesReloaded
+				if err := loadAppImages(ctx, cfg, cli, app); err != nil {
+					action.Error = err.Error()
+				}
+			}
+			appReport.Actions = append(appReport.Actions, action)
+		}
+	}
+
+	// Check the running status after the compose projects and images are repaired
+	runningStatus, err := CheckAppsRunningStatus(ctx, cfg, apps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check apps running status: %w", err)
+	}
+	for i, app := range apps {
+		appReport := report.Apps[i]
+		action := getContainersRecreationAction(runningStatus.AppsRunningStatus[app.Ref().Digest])
+		if action == nil {
+			continue
+		}
+		if !opts.DryRun {
+			action.Type = ReconcileActionContainersRecreated
+			if err := startApp(cfg, app, false); err != nil {
+				action.Error = err.Error()
+			}
+		}
+		appReport.Actions = append(appReport.Actions, action)
+	}
+	return report, nil
+}
+
+// getComposeReinstallAction returns the action required to repair the app compose project if any of its files
+// are modified, removed or missing, otherwise nil.
+func getComposeReinstallAction(installReport *InstallReport) *ReconcileAction {
+	if len(installReport.BundleErrors) == 0 {
+		return nil
+	}
+	action := &ReconcileAction{Type: ReconcileActionComposeReinstallRequired}
+	for filePath := range installReport.BundleErrors {
+		action.Targets = append(action.Targets, filePath)
+	}
+	sort.Strings(action.Targets)
+	return action
+}
+
+// getImagesReloadAction returns the action required to reload the app images missing in the docker store,
+// otherwise nil.
+func getImagesReloadAction(app App, installReport *InstallReport) *ReconcileAction {
+	var missingImages []string
+	for _, imageNode := range app.GetComposeRoot().Children {
+		if !installReport.Images[imageNode.Descriptor.Digest] {
+			missingImages = append(missingImages, imageNode.Ref())
+		}
+	}
+	if len(missingImages) == 0 {
+		return nil
+	}
+	return &ReconcileAction{Type: ReconcileActionImagesReloadRequired, Targets: missingImages}
+}
+
+// getContainersRecreationAction returns the action required to recreate the app containers that are missing
+// or which config hash does not match the app's one, otherwise nil.
+func getContainersRecreationAction(runningReport RunningReport) *ReconcileAction {
+	if runningReport.Disabled {
+		// Containers of a disabled app are not supposed to exist
+		return nil
+	}
+	var services []string
+	for _, srv := range runningReport.Services {
+		// The service is not found if its container is missing or its config hash does not match the app's one
+		if srv.State == "not created" {
+			services = append(services, srv.Name)
+		}
+	}
+	if len(services) == 0 {
+		return nil
+	}
+	return &ReconcileAction{Type: ReconcileActionContainersRecreationRequired, Targets: services}
+}
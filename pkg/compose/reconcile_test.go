@@ -0,0 +1,81 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestReconcileActions(t *testing.T) {
+	image1 := &TreeNode{Descriptor: &ocispec.Descriptor{Digest: digest.FromString("image1"), URLs: []string{"registry.local/image1"}}}
+	image2 := &TreeNode{Descriptor: &ocispec.Descriptor{Digest: digest.FromString("image2"), URLs: []string{"registry.local/image2"}}}
+	app := &testApp{name: "app1", composeRoot: &TreeNode{Children: []*TreeNode{image1, image2}}}
+
+	t.Run("no drift", func(t *testing.T) {
+		installReport := &InstallReport{
+			Images: map[digest.Digest]bool{image1.Descriptor.Digest: true, image2.Descriptor.Digest: true},
+		}
+		if action := getComposeReinstallAction(installReport); action != nil {
+			t.Errorf("unexpected compose reinstall action: %+v", action)
+		}
+		if action := getImagesReloadAction(app, installReport); action != nil {
+			t.Errorf("unexpected images reload action: %+v", action)
+		}
+		runningReport := RunningReport{Services: []*Service{{Name: "srv1", State: "running"}}}
+		if action := getContainersRecreationAction(runningReport); action != nil {
+			t.Errorf("unexpected containers recreation action: %+v", action)
+		}
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		installReport := &InstallReport{
+			Images:       map[digest.Digest]bool{image1.Descriptor.Digest: true},
+			BundleErrors: AppBundleErrs{"docker-compose.yml": "modified", ".env": "missing"},
+		}
+		expected := &ReconcileAction{Type: ReconcileActionComposeReinstallRequired, Targets: []string{".env", "docker-compose.yml"}}
+		if action := getComposeReinstallAction(installReport); !reflect.DeepEqual(action, expected) {
+			t.Errorf("expected compose reinstall action %+v, got %+v", expected, action)
+		}
+		expected = &ReconcileAction{Type: ReconcileActionImagesReloadRequired, Targets: []string{"registry.local/image2"}}
+		if action := getImagesReloadAction(app, installReport); !reflect.DeepEqual(action, expected) {
+			t.Errorf("expected images reload action %+v, got %+v", expected, action)
+		}
+		runningReport := RunningReport{Services: []*Service{
+			{Name: "srv1", State: "running"},
+			{Name: "srv2", State: "not created"},
+			{Name: "srv3", State: "exited"},
+		}}
+		expected = &ReconcileAction{Type: ReconcileActionContainersRecreationRequired, Targets: []string{"srv2"}}
+		if action := getContainersRecreationAction(runningReport); !reflect.DeepEqual(action, expected) {
+			t.Errorf("expected containers recreation action %+v, got %+v", expected, action)
+		}
+		// Containers of a disabled app are not supposed to exist
+		runningReport.Disabled = true
+		if action := getContainersRecreationAction(runningReport); action != nil {
+			t.Errorf("unexpected containers recreation action for disabled app: %+v", action)
+		}
+	})
+}
+
+func TestReconcileReport(t *testing.T) {
+	report := &ReconcileReport{Apps: []*AppReconcileReport{
+		{Name: "app1"},
+		{Name: "app2", Actions: []*ReconcileAction{{Type: ReconcileActionImagesReloaded}}},
+	}}
+	if report.Apps[0].IsDrifted() {
+		t.Errorf("app with no actions is reported as drifted")
+	}
+	if !report.Apps[1].IsDrifted() {
+		t.Errorf("app with actions is not reported as drifted")
+	}
+	if report.Failed() {
+		t.Errorf("report with no failed actions is reported as failed")
+	}
+	report.Apps[1].Actions = append(report.Apps[1].Actions,
+		&ReconcileAction{Type: ReconcileActionContainersRecreated, Error: "failed to start"})
+	if !report.Failed() {
+		t.Errorf("report with a failed action is not reported as failed")
+	}
+}
@@ -14,3 +14,15 @@ func NewResolver(authorizer docker.Authorizer, client *http.Client) remotes.Reso
 		),
 	})
 }
+
+// NewResolverWithMirrors returns the resolver which tries the mirrors of a registry before the registry itself.
+func NewResolverWithMirrors(authorizer docker.Authorizer, client *http.Client, mirrors *RegistryMirrors) (remotes.Resolver, error) {
+	hosts, err := mirrors.registryHosts(docker.ConfigureDefaultRegistries(
+		docker.WithAuthorizer(authorizer),
+		docker.WithClient(client),
+	), authorizer, client, registryMirrorsHealth)
+	if err != nil {
+		return nil, err
+	}
+	return docker.NewResolver(docker.ResolverOptions{Hosts: hosts}), nil
+}
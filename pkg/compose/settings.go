@@ -0,0 +1,115 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// AppSettings are device-side settings of an app, they are kept across app updates
+	AppSettings struct {
+		// Compose profiles activated on the device, services that belong only to other profiles are not fetched,
+		// installed or started
+		Profiles []string `json:"profiles,omitempty"`
+		// Disabled app is kept installed but is not started, neither by the app update nor by the watchdog
+		Disabled bool `json:"disabled,omitempty"`
+	}
+)
+
+const (
+	AppSettingsFile = "settings.json"
+)
+
+var (
+	ErrInvalidAppName = errors.New("invalid app name")
+)
+
+// CheckAppName returns ErrInvalidAppName if the app name cannot be used as a name of the app directories
+// on the device, e.g. it contains path separators.
+func CheckAppName(appName string) error {
+	if len(appName) == 0 || appName == "." || appName == ".." || strings.ContainsAny(appName, `/\`) {
+		return fmt.Errorf("%w: %q", ErrInvalidAppName, appName)
+	}
+	return nil
+}
+
+// checkAppIsKnown returns ErrAppNotFound if the app is neither installed on the device nor found in the local store.
+func checkAppIsKnown(ctx context.Context, cfg *Config, appName string) error {
+	if fi, err := os.Stat(cfg.GetAppComposeDir(appName)); err == nil && fi.IsDir() {
+		return nil
+	}
+	store, err := cfg.AppStoreFactory()
+	if err != nil {
+		return err
+	}
+	refs, err := store.ListApps(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if ref.Name == appName {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is neither installed nor found in the local store", ErrAppNotFound, appName)
+}
+
+func (c *Config) GetAppSettingsFile(appName string) string {
+	return filepath.Join(c.GetAppLocalDir(appName), AppSettingsFile)
+}
+
+// LoadAppSettings returns the device-side settings of the app, the default settings are returned if the app
+// has no settings.
+func LoadAppSettings(cfg *Config, appName string) (*AppSettings, error) {
+	settings := &AppSettings{}
+	b, err := os.ReadFile(cfg.GetAppSettingsFile(appName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse settings of app %s: %w", appName, err)
+	}
+	return settings, nil
+}
+
+// IsAppDisabled returns true if the app is disabled on the device.
+func IsAppDisabled(cfg *Config, appName string) (bool, error) {
+	settings, err := LoadAppSettings(cfg, appName)
+	if err != nil {
+		return false, err
+	}
+	return settings.Disabled, nil
+}
+
+// SetAppEnabled enables or disables the app on the device. The app must be either installed
+// or found in the local store.
+func SetAppEnabled(ctx context.Context, cfg *Config, appName string, enabled bool) error {
+	if err := CheckAppName(appName); err != nil {
+		return err
+	}
+	if err := checkAppIsKnown(ctx, cfg, appName); err != nil {
+		return err
+	}
+	settings, err := LoadAppSettings(cfg, appName)
+	if err != nil {
+		return err
+	}
+	settings.Disabled = !enabled
+	return SaveAppSettings(cfg, appName, settings)
+}
+
+func SaveAppSettings(cfg *Config, appName string, settings *AppSettings) error {
+	b, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(cfg.GetAppSettingsFile(appName), b, 0644)
+}
@@ -0,0 +1,155 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type testAppStore struct {
+	AppStore
+	refs []*AppRef
+}
+
+func (s *testAppStore) ListApps(ctx context.Context) ([]*AppRef, error) {
+	return s.refs, nil
+}
+
+func newSettingsTestConfig(t *testing.T, storedApps ...string) *Config {
+	store := &testAppStore{}
+	for _, name := range storedApps {
+		store.refs = append(store.refs, &AppRef{Name: name, Digest: digest.FromString(name)})
+	}
+	return &Config{
+		ComposeRoot: t.TempDir(),
+		LocalRoot:   t.TempDir(),
+		AppStoreFactoryFunc: func(c *Config) (AppStore, error) {
+			return store, nil
+		},
+	}
+}
+
+func TestCheckAppName(t *testing.T) {
+	for _, name := range []string{"app1", "app-1", "app_1.v2"} {
+		if err := CheckAppName(name); err != nil {
+			t.Errorf("unexpected error for valid app name %q: %s", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "../app1", "app1/..", "dir/app1", `dir\app1`} {
+		if err := CheckAppName(name); !errors.Is(err, ErrInvalidAppName) {
+			t.Errorf("expected invalid app name error for %q, got %v", name, err)
+		}
+	}
+}
+
+func TestSetAppEnabled(t *testing.T) {
+	cfg := newSettingsTestConfig(t, "stored")
+	if err := os.MkdirAll(cfg.GetAppComposeDir("installed"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"installed", "stored"} {
+		if err := SetAppEnabled(context.Background(), cfg, name, false); err != nil {
+			t.Fatalf("failed to disable %s: %s", name, err)
+		}
+		if disabled, err := IsAppDisabled(cfg, name); err != nil || !disabled {
+			t.Errorf("expected %s disabled, got %v, error: %v", name, disabled, err)
+		}
+		if err := SetAppEnabled(context.Background(), cfg, name, true); err != nil {
+			t.Fatalf("failed to enable %s: %s", name, err)
+		}
+		if disabled, err := IsAppDisabled(cfg, name); err != nil || disabled {
+			t.Errorf("expected %s enabled, got disabled: %v, error: %v", name, disabled, err)
+		}
+	}
+
+	if err := SetAppEnabled(context.Background(), cfg, "unknown", false); !errors.Is(err, ErrAppNotFound) {
+		t.Errorf("expected app not found error, got %v", err)
+	}
+	if err := SetAppEnabled(context.Background(), cfg, "../installed", false); !errors.Is(err, ErrInvalidAppName) {
+		t.Errorf("expected invalid app name error, got %v", err)
+	}
+	if _, err := os.Stat(cfg.GetAppSettingsFile("unknown")); !os.IsNotExist(err) {
+		t.Errorf("settings of an unknown app are stored")
+	}
+}
+
+func TestStartAppsSkipsDisabledApps(t *testing.T) {
+	app1 := &testApp{name: "app1", ref: &AppRef{Name: "app1"}}
+	app2 := &testApp{name: "app2", ref: &AppRef{Name: "app2"}, dependsOn: "app1"}
+	cfg := newSettingsTestConfig(t, "app1", "app2")
+	cfg.AppLoader = testAppLoader{"app1-uri": app1, "app2-uri": app2}
+	for _, app := range []*testApp{app1, app2} {
+		if err := SetAppEnabled(context.Background(), cfg, app.name, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statuses := map[string]AppStartStatus{}
+	if err := StartApps(context.Background(), cfg, []string{"app2-uri", "app1-uri"},
+		WithStartProgressHandler(func(app App, status AppStartStatus, any interface{}) {
+			statuses[app.Name()] = status
+		})); err != nil {
+		t.Fatalf("failed to start apps: %s", err)
+	}
+	for _, name := range []string{"app1", "app2"} {
+		if statuses[name] != AppStartStatusDisabled {
+			t.Errorf("expected %s start status %q, got %q", name, AppStartStatusDisabled, statuses[name])
+		}
+	}
+}
+
+func TestRunningStatusOfDisabledApps(t *testing.T) {
+	newApp := func(name string) *testApp {
+		image := &TreeNode{Type: BlobTypeImageManifest, Descriptor: &ocispec.Descriptor{
+			Digest:      digest.FromString(name + "-image"),
+			URLs:        []string{"registry.local/" + name + "@" + digest.FromString(name+"-image").String()},
+			Annotations: map[string]string{AppServiceNameAnnotationKey: "srv", AppServiceHashLabelKey: "hash"},
+		}}
+		return &testApp{name: name, ref: &AppRef{Name: name, Digest: digest.FromString(name)},
+			composeRoot: &TreeNode{Children: []*TreeNode{image}}}
+	}
+	enabled := newApp("enabled")
+	disabled := newApp("disabled")
+	running := newApp("running")
+	cfg := newSettingsTestConfig(t, "enabled", "disabled", "running")
+	if err := SetAppEnabled(context.Background(), cfg, "disabled", false); err != nil {
+		t.Fatal(err)
+	}
+	services := Services{{
+		Name:  "srv",
+		Image: running.composeRoot.Children[0].Ref(),
+		Hash:  running.composeRoot.Children[0].GetServiceHash(),
+		State: "running",
+	}}
+
+	status := &RunningStatus{
+		AppsRunningStatus: map[digest.Digest]RunningReport{},
+		NotRunningApps:    map[digest.Digest]interface{}{},
+	}
+	for _, app := range []App{enabled, disabled, running} {
+		if err := addAppRunningReport(cfg, app, services, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := status.NotRunningApps[enabled.ref.Digest]; !ok {
+		t.Errorf("enabled app with no containers is not reported as not running")
+	}
+	if _, ok := status.NotRunningApps[disabled.ref.Digest]; ok {
+		t.Errorf("disabled app is reported as not running")
+	}
+	if !status.AppsRunningStatus[disabled.ref.Digest].Disabled {
+		t.Errorf("disabled app is not reported as disabled")
+	}
+	if _, ok := status.NotRunningApps[running.ref.Digest]; ok {
+		t.Errorf("running app is reported as not running")
+	}
+	if report := status.AppsRunningStatus[running.ref.Digest]; report.Disabled || len(report.Services) != 1 ||
+		report.Services[0].State != "running" {
+		t.Errorf("unexpected running app report: %+v", report)
+	}
+}
@@ -3,16 +3,23 @@ package compose
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"github.com/compose-spec/compose-go/dotenv"
 	"github.com/containerd/containerd/platforms"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 type (
 	StartOptions struct {
 		Verbose         bool
 		ProgressHandler AppStartProgress
+		// Maximum time to wait for the apps that an app depends on to become healthy before starting the app
+		DependencyWaitTimeout time.Duration
 	}
 
 	StartOption func(*StartOptions)
@@ -25,6 +32,8 @@ const (
 	AppStartStatusStarting AppStartStatus = "starting"
 	AppStartStatusStarted  AppStartStatus = "started"
 	AppStartStatusFailed   AppStartStatus = "failed"
+	// The app is not started since it is disabled on the device
+	AppStartStatusDisabled AppStartStatus = "disabled"
 )
 
 func WithVerboseStart(verbose bool) StartOption {
@@ -39,9 +48,18 @@ func WithStartProgressHandler(handler AppStartProgress) StartOption {
 	}
 }
 
+func WithDependencyWaitTimeout(timeout time.Duration) StartOption {
+	return func(o *StartOptions) {
+		o.DependencyWaitTimeout = timeout
+	}
+}
+
+// StartApps starts the given apps in the order defined by their dependencies, see AppDependsOnAnnotationKey.
+// An app is started once all the apps it depends on are running and healthy.
 func StartApps(ctx context.Context, cfg *Config, appURIs []string, options ...StartOption) error {
 	opts := &StartOptions{
-		Verbose: false,
+		Verbose:               false,
+		DependencyWaitTimeout: DefaultDependencyWaitTimeout,
 	}
 	for _, o := range options {
 		o(opts)
@@ -52,45 +70,130 @@ func StartApps(ctx context.Context, cfg *Config, appURIs []string, options ...St
 		return err
 	}
 
-	apps := map[string]App{}
+	var apps []App
 	for _, appURI := range appURIs {
 		app, err := cfg.AppLoader.LoadAppTree(ctx, cs, platforms.OnlyStrict(cfg.Platform), appURI)
 		if err != nil {
 			return err
 		}
-		apps[appURI] = app
+		apps = append(apps, app)
+	}
+	if apps, err = SortAppsByDependencies(apps); err != nil {
+		return err
 	}
 
+	startedApps := map[string]App{}
 	for _, app := range apps {
+		if disabled, err := IsAppDisabled(cfg, app.Name()); err != nil {
+			return err
+		} else if disabled {
+			if opts.ProgressHandler != nil {
+				opts.ProgressHandler(app, AppStartStatusDisabled, nil)
+			}
+			continue
+		}
+		var deps []App
+		for _, dep := range GetAppDependencies(app) {
+			if depApp, ok := startedApps[dep]; ok {
+				deps = append(deps, depApp)
+			}
+		}
 		if opts.ProgressHandler != nil {
 			opts.ProgressHandler(app, AppStartStatusStarting, nil)
 		}
-		cmd := exec.Command("docker", "compose", "up", "-d", "--remove-orphans")
-		cmd.Dir = cfg.GetAppComposeDir(app.Name())
-		if opts.Verbose {
-			// Directly connect to stdout/stderr, so we can see the output in real time
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-		} else {
-			// Capture stdout/stderr for error reporting
-			var stdout, stderr bytes.Buffer
-			cmd.Stdout = &stdout
-			cmd.Stderr = &stderr
-		}
-		if err := cmd.Run(); err != nil {
+		if err := waitForAppsHealthy(ctx, cfg, deps, opts.DependencyWaitTimeout); err != nil {
 			if opts.ProgressHandler != nil {
 				opts.ProgressHandler(app, AppStartStatusFailed, err)
 			}
-			if opts.Verbose {
-				return fmt.Errorf("failed to start %s: %w", app, err)
-			} else {
-				return fmt.Errorf("failed to start %s: %w\n\tstdout: %s\n\tstderr: %s", app, err, cmd.Stdout, cmd.Stderr)
+			return fmt.Errorf("failed to start %s: %w", app.Name(), err)
+		}
+		if err := startApp(cfg, app, opts.Verbose); err != nil {
+			if opts.ProgressHandler != nil {
+				opts.ProgressHandler(app, AppStartStatusFailed, err)
 			}
-
+			return err
 		}
 		if opts.ProgressHandler != nil {
 			opts.ProgressHandler(app, AppStartStatusStarted, nil)
 		}
+		startedApps[app.Name()] = app
 	}
 	return nil
 }
+
+func startApp(cfg *Config, app App, verbose bool) error {
+	cmd, err := newComposeCmd(cfg, app.Name(), "up", "-d", "--remove-orphans")
+	if err != nil {
+		return err
+	}
+	if verbose {
+		// Directly connect to stdout/stderr, so we can see the output in real time
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	} else {
+		// Capture stdout/stderr for error reporting
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+	}
+	if err := cmd.Run(); err != nil {
+		if verbose {
+			return fmt.Errorf("failed to start %s: %w", app, err)
+		} else {
+			return fmt.Errorf("failed to start %s: %w\n\tstdout: %s\n\tstderr: %s", app, err, cmd.Stdout, cmd.Stderr)
+		}
+	}
+	return nil
+}
+
+// newComposeCmd returns `docker compose` command for the app project that applies the device-local settings:
+// the compose override, the device variables rendered at the app installation and the active profiles.
+func newComposeCmd(cfg *Config, appName string, args ...string) (*exec.Cmd, error) {
+	appDir := cfg.GetAppComposeDir(appName)
+	cmdArgs := []string{"compose"}
+
+	overrideFile := cfg.GetAppComposeOverrideFile(appName)
+	if _, err := os.Stat(overrideFile); err == nil {
+		// Specifying a compose file disables the lookup of the default one, so it should be specified explicitly
+		composeFile, err := FindComposeFileInDir(appDir)
+		if err != nil {
+			return nil, err
+		}
+		cmdArgs = append(cmdArgs, "-f", filepath.Join(appDir, composeFile), "-f", overrideFile)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	settings, err := LoadAppSettings(cfg, appName)
+	if err != nil {
+		return nil, err
+	}
+	for _, profile := range settings.Profiles {
+		cmdArgs = append(cmdArgs, "--profile", profile)
+	}
+
+	env := os.Environ()
+	deviceEnvFile := cfg.GetAppDeviceEnvFile(appName)
+	if deviceVars, err := dotenv.Read(deviceEnvFile); err == nil {
+		// Specifying an env file disables the default one, so it should be specified explicitly
+		if _, err := os.Stat(filepath.Join(appDir, AppEnvFile)); err == nil {
+			cmdArgs = append(cmdArgs, "--env-file", filepath.Join(appDir, AppEnvFile))
+		}
+		cmdArgs = append(cmdArgs, "--env-file", deviceEnvFile)
+		// Variables set in the caller's environment take precedence over the env files, make sure that
+		// the device variables are not overridden by them
+		env = nil
+		for _, e := range os.Environ() {
+			if _, ok := deviceVars[strings.SplitN(e, "=", 2)[0]]; !ok {
+				env = append(env, e)
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read app device variables: %w", err)
+	}
+
+	cmd := exec.Command("docker", append(cmdArgs, args...)...)
+	cmd.Dir = appDir
+	cmd.Env = env
+	return cmd, nil
+}
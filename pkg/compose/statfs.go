@@ -27,6 +27,14 @@ type (
 	}
 )
 
+const (
+	// MinUsageWatermark, MaxUsageWatermark and DefaultUsageWatermark bound the maximum allowed storage usage
+	// in percentage, passed to GetUsageInfo as the watermark.
+	MinUsageWatermark     = 20
+	MaxUsageWatermark     = 99
+	DefaultUsageWatermark = 95
+)
+
 var (
 	binaryAbbrs = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB", "ZiB", "YiB"}
 )
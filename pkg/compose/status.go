@@ -17,8 +17,11 @@ import (
 )
 
 const (
-	AppServiceHashLabelKey = "io.compose-spec.config-hash"
-	ServiceLabel           = "com.docker.compose.service"
+	AppServiceHashLabelKey      = "io.compose-spec.config-hash"
+	AppServiceNameAnnotationKey = "org.foundries.app.service.name"
+	ServiceLabel                = "com.docker.compose.service"
+	ProjectWorkingDirLabel      = "com.docker.compose.project.working_dir"
+	ProjectLabel                = "com.docker.compose.project"
 )
 
 type (
@@ -50,6 +53,8 @@ type (
 	RunningReport struct {
 		Services []*Service
 		Health   string
+		// The app is disabled on the device, so it is not expected to be running
+		Disabled bool
 	}
 	RunningStatus struct {
 		AppsRunningStatus map[digest.Digest]RunningReport
@@ -226,6 +231,10 @@ func CheckAppsFetchStatus(
 	if err != nil {
 		return nil, err
 	}
+	loadedLayers, err := GetLoadedLayers(ctx, cfg, apps)
+	if err != nil {
+		return nil, err
+	}
 	for _, app := range apps {
 		fetchReport := FetchReport{BlobsStatus: BlobsInfo{}}
 		err := app.Tree().Walk(func(node *TreeNode, depth int) error {
@@ -237,10 +246,12 @@ func CheckAppsFetchStatus(
 				if fetchStatus, err := ls.Status(ctx, node.Ref()); err == nil {
 					bi.State = BlobFetching
 					bi.BytesFetched = fetchStatus.Offset
+				} else if loadedLayers[node.Descriptor.Digest] {
+					bi.State = BlobLoaded
 				}
 			}
 			fetchReport.BlobsStatus[node.Descriptor.Digest] = bi
-			if bi.State != BlobOk {
+			if bi.State != BlobOk && bi.State != BlobLoaded {
 				fetchStatus.MissingBlobs[node.Descriptor.Digest] = bi
 			}
 			return nil
@@ -277,7 +288,8 @@ func CheckAppsInstallStatus(
 			BundleErrors: AppBundleErrs{},
 		}
 		// Check app compose installation and app images installation in the docker store
-		appBundleErrs, checkComposeErr := app.CheckComposeInstallation(ctx, blobProvider, path.Join(cfg.ComposeRoot, app.Name()))
+		appBundleErrs, checkComposeErr := app.CheckComposeInstallation(ctx, blobProvider, path.Join(cfg.ComposeRoot, app.Name()),
+			WithAllowedExtraFiles(cfg.AllowedBundleExtraFiles...))
 		if checkComposeErr != nil {
 			if errors.Is(checkComposeErr, ErrAppIndexNotFound) {
 				if appBundleErrs == nil {
@@ -327,40 +339,57 @@ func CheckAppsRunningStatus(
 	}
 
 	for _, app := range apps {
-		var running = true
-		health := "healthy"
-		var appServices []*Service
-		appComposeRoot := app.GetComposeRoot()
-		for _, imageNode := range appComposeRoot.Children {
-			if srv := foundAppServices.find(imageNode); srv != nil {
-				appServices = append(appServices, srv)
-				if srv.State != "running" && srv.Health != "healthy" {
-					// if the service is not running and not healthy, we consider the app as not running
-					// in some cases, service can be not running but healthy, e.g.,
-					// when it is exited with a success code (e.g. one shot service/container)
-					running = false
-				}
-				// if at least one service is unhealthy, the app is considered unhealthy
-				if srv.Health != "healthy" {
-					health = "unhealthy"
-				}
-			} else {
-				appServices = append(appServices, &Service{
-					State: "not created",
-				})
+		if err := addAppRunningReport(cfg, app, foundAppServices, runningStatus); err != nil {
+			return nil, err
+		}
+	}
+	return runningStatus, nil
+}
+
+// addAppRunningReport adds the running report of the app made of the found services to the running status,
+// the app is reported as not running unless all its services are running or it is disabled on the device.
+func addAppRunningReport(cfg *Config, app App, foundAppServices Services, runningStatus *RunningStatus) error {
+	var running = true
+	health := "healthy"
+	var appServices []*Service
+	appComposeRoot := app.GetComposeRoot()
+	for _, imageNode := range appComposeRoot.Children {
+		if srv := foundAppServices.find(imageNode); srv != nil {
+			appServices = append(appServices, srv)
+			if srv.State != "running" && srv.Health != "healthy" {
+				// if the service is not running and not healthy, we consider the app as not running
+				// in some cases, service can be not running but healthy, e.g.,
+				// when it is exited with a success code (e.g. one shot service/container)
 				running = false
+			}
+			// if at least one service is unhealthy, the app is considered unhealthy
+			if srv.Health != "healthy" {
 				health = "unhealthy"
 			}
-		}
-		runningStatus.AppsRunningStatus[app.Ref().Digest] = RunningReport{
-			Services: appServices,
-			Health:   health,
-		}
-		if !running {
-			runningStatus.NotRunningApps[app.Ref().Digest] = struct{}{}
+		} else {
+			appServices = append(appServices, &Service{
+				Name:  imageNode.GetServiceName(),
+				Image: imageNode.Ref(),
+				Hash:  imageNode.GetServiceHash(),
+				State: "not created",
+			})
+			running = false
+			health = "unhealthy"
 		}
 	}
-	return runningStatus, nil
+	disabled, err := IsAppDisabled(cfg, app.Name())
+	if err != nil {
+		return err
+	}
+	runningStatus.AppsRunningStatus[app.Ref().Digest] = RunningReport{
+		Services: appServices,
+		Health:   health,
+		Disabled: disabled,
+	}
+	if !running && !disabled {
+		runningStatus.NotRunningApps[app.Ref().Digest] = struct{}{}
+	}
+	return nil
 }
 
 func GetInstalledImages(ctx context.Context, cfg *Config) (*InstalledImagesInfo, error) {
@@ -508,8 +537,11 @@ func loadAppTrees(ctx context.Context,
 	for _, appRef := range appRefs {
 		app, err := cfg.AppLoader.LoadAppTree(ctx, blobProvider, platforms.OnlyStrict(cfg.Platform), appRef)
 		if fallbackLoadingFromRemote && errors.Is(err, ErrAppNotFound) {
-			app, err = cfg.AppLoader.LoadAppTree(ctx, NewRemoteBlobProviderFromConfig(cfg),
-				platforms.OnlyStrict(cfg.Platform), appRef)
+			var remoteProvider BlobProvider
+			if remoteProvider, err = NewRemoteBlobProviderFromConfig(cfg); err != nil {
+				return nil, err
+			}
+			app, err = cfg.AppLoader.LoadAppTree(ctx, remoteProvider, platforms.OnlyStrict(cfg.Platform), appRef)
 		}
 		if err != nil {
 			return nil, err
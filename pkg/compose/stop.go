@@ -4,26 +4,92 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 )
 
+// StopApps stops the given apps in the reverse order of their dependencies, so an app is stopped before the apps
+// it depends on.
 func StopApps(ctx context.Context, cfg *Config, appRefs []string) error {
 	status, err := CheckAppsStatus(ctx, cfg, appRefs)
 	if err != nil {
 		return err
 	}
-	for _, app := range status.Apps {
+	apps, err := SortAppsByDependencies(status.Apps)
+	if err != nil {
+		return err
+	}
+	for i := len(apps) - 1; i >= 0; i-- {
+		app := apps[i]
 		if _, ok := status.NotInstalledCompose[app.Ref().Digest]; ok {
 			// skip stopping apps with non-installed compose project
 			continue
 		}
-		cmd := exec.Command("docker", "compose", "down")
-		cmd.Dir = cfg.GetAppComposeDir(app.Name())
-		if _, err := cmd.CombinedOutput(); err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				return fmt.Errorf("failed to stop %s: %s; %s", app, exitErr.Error(), string(exitErr.Stderr))
-			}
+		if err := stopApp(cfg, app); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+func stopApp(cfg *Config, app App) error {
+	// Enable all profiles, so the containers of services of the profiles deactivated since the app start are removed too
+	cmd, err := newComposeCmd(cfg, app.Name(), "--profile", "*", "down")
+	if err != nil {
+		return err
+	}
+	if _, err := cmd.CombinedOutput(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("failed to stop %s: %s; %s", app.Name(), exitErr.Error(), string(exitErr.Stderr))
+		}
+		return err
+	}
+	return nil
+}
+
+// StopProject stops and removes the containers and networks of the compose project with the given name.
+// The project resources are looked up by the compose project label, so the project can be stopped even if
+// its compose directory is modified or removed, e.g. by an interrupted update.
+func StopProject(ctx context.Context, cfg *Config, projectName string) error {
+	cli, err := GetDockerClient(cfg.DockerHost)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	if _, err := removeProjectResources(ctx, cli, projectName, false); err != nil {
+		return fmt.Errorf("failed to stop %s: %w", projectName, err)
+	}
+	return nil
+}
+
+// ListRunningProjects returns names of the compose projects installed in the compose root
+// that have at least one running container.
+func ListRunningProjects(ctx context.Context, cfg *Config) ([]string, error) {
+	cli, err := GetDockerClient(cfg.DockerHost)
+	if err != nil {
+		return nil, err
+	}
+	ctrs, err := cli.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("status", "running")),
+	})
+	if err != nil {
+		return nil, err
+	}
+	composeRoot := filepath.Clean(cfg.ComposeRoot)
+	projects := map[string]struct{}{}
+	var projectNames []string
+	for _, ctr := range ctrs {
+		workDir, ok := ctr.Labels[ProjectWorkingDirLabel]
+		if !ok || filepath.Dir(filepath.Clean(workDir)) != composeRoot {
+			continue
+		}
+		name := filepath.Base(workDir)
+		if _, ok := projects[name]; !ok {
+			projects[name] = struct{}{}
+			projectNames = append(projectNames, name)
+		}
+	}
+	return projectNames, nil
+}
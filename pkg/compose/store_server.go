@@ -0,0 +1,141 @@
+package compose
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type (
+	// storeRegistryHandler serves blobs of the app store over the read-only subset of the OCI distribution API,
+	// so the store can be used as a registry mirror by other devices. The store is content addressable,
+	// hence blobs and manifests are served under any repository name, and manifests are served only by digest.
+	storeRegistryHandler struct {
+		blobsRoot string
+	}
+
+	registryError struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+)
+
+const (
+	registryAPIVersionHeader = "Docker-Distribution-API-Version"
+	registryAPIVersion       = "registry/2.0"
+	contentDigestHeader      = "Docker-Content-Digest"
+
+	// maximum size of a manifest read to detect its media type
+	maxServedManifestSize = 4 * 1024 * 1024
+)
+
+// NewStoreRegistryHandler returns the HTTP handler serving blobs of the store with the given blobs root directory
+// over the read-only OCI distribution API.
+func NewStoreRegistryHandler(blobsRoot string) http.Handler {
+	return &storeRegistryHandler{blobsRoot: blobsRoot}
+}
+
+func (h *storeRegistryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(registryAPIVersionHeader, registryAPIVersion)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeRegistryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "the registry is read-only")
+		return
+	}
+	if r.URL.Path == "/v2" || r.URL.Path == "/v2/" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	// /v2/<name>/blobs/<digest> or /v2/<name>/manifests/<digest>
+	p := strings.TrimPrefix(r.URL.Path, "/v2/")
+	if p == r.URL.Path {
+		writeRegistryError(w, http.StatusNotFound, "NOT_FOUND", "unknown endpoint")
+		return
+	}
+	var kind, ref string
+	if i := strings.LastIndex(p, "/blobs/"); i > 0 {
+		kind, ref = "blobs", p[i+len("/blobs/"):]
+	} else if i := strings.LastIndex(p, "/manifests/"); i > 0 {
+		kind, ref = "manifests", p[i+len("/manifests/"):]
+	} else {
+		writeRegistryError(w, http.StatusNotFound, "NOT_FOUND", "unknown endpoint")
+		return
+	}
+
+	unknownCode := "BLOB_UNKNOWN"
+	if kind == "manifests" {
+		unknownCode = "MANIFEST_UNKNOWN"
+	}
+	d, err := digest.Parse(ref)
+	if err != nil || d.Algorithm() != digest.SHA256 {
+		if kind == "manifests" {
+			writeRegistryError(w, http.StatusNotFound, unknownCode, "only manifests referenced by sha256 digest are served")
+		} else {
+			writeRegistryError(w, http.StatusBadRequest, "DIGEST_INVALID", "invalid sha256 digest: "+ref)
+		}
+		return
+	}
+	f, err := os.Open(filepath.Join(h.blobsRoot, d.Encoded()))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeRegistryError(w, http.StatusNotFound, unknownCode, "unknown digest: "+d.String())
+		} else {
+			writeRegistryError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		}
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		writeRegistryError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+
+	contentType := "application/octet-stream"
+	if kind == "manifests" {
+		if contentType, err = getManifestMediaType(f, fi.Size()); err != nil {
+			writeRegistryError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+			return
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set(contentDigestHeader, d.String())
+	w.Header().Set("Etag", `"`+d.String()+`"`)
+	// Serves the range requests and HEAD requests too
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
+
+// getManifestMediaType returns the media type specified in the given manifest and rewinds the manifest reader.
+func getManifestMediaType(r io.ReadSeeker, size int64) (string, error) {
+	if size > maxServedManifestSize {
+		return "", fmt.Errorf("manifest size %d exceeds the maximum allowed size %d", size, maxServedManifestSize)
+	}
+	var m struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return "", fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if len(m.MediaType) == 0 {
+		m.MediaType = ocispec.MediaTypeImageManifest
+	}
+	return m.MediaType, nil
+}
+
+func writeRegistryError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Errors []registryError `json:"errors"`
+	}{Errors: []registryError{{Code: code, Message: message}}})
+}
@@ -0,0 +1,85 @@
+package compose
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestStoreRegistryHandler(t *testing.T) {
+	blobsRoot := t.TempDir()
+	writeBlob := func(data string) digest.Digest {
+		d := digest.FromString(data)
+		if err := os.WriteFile(filepath.Join(blobsRoot, d.Encoded()), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	layer := writeBlob("layer data")
+	manifest := writeBlob(`{"schemaVersion":2,"mediaType":"` + ocispec.MediaTypeImageIndex + `","manifests":[]}`)
+
+	server := httptest.NewTLSServer(NewStoreRegistryHandler(blobsRoot))
+	defer server.Close()
+	request := func(method string, path string, header ...string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		if len(header) == 2 {
+			req.Header.Set(header[0], header[1])
+		}
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	if resp := request(http.MethodGet, "/v2/"); resp.StatusCode != http.StatusOK || resp.Header.Get(registryAPIVersionHeader) != registryAPIVersion {
+		t.Errorf("unexpected API version check response: %d", resp.StatusCode)
+	}
+	resp := request(http.MethodGet, "/v2/factory/app/blobs/"+layer.String(), "Range", "bytes=6-")
+	if b, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusPartialContent || string(b) != "data" {
+		t.Errorf("unexpected range response: %d %q", resp.StatusCode, b)
+	}
+	resp = request(http.MethodHead, "/v2/factory/app/manifests/"+manifest.String())
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ocispec.MediaTypeImageIndex ||
+		resp.Header.Get(contentDigestHeader) != manifest.String() {
+		t.Errorf("unexpected manifest response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	for path, status := range map[string]int{
+		"/v2/factory/app/blobs/" + digest.FromString("missing").String(): http.StatusNotFound,
+		"/v2/factory/app/manifests/latest":                               http.StatusNotFound,
+		"/v2/factory/app/blobs/sha256:1234":                              http.StatusBadRequest,
+		"/v2/factory/app/tags/list":                                      http.StatusNotFound,
+	} {
+		if resp := request(http.MethodGet, path); resp.StatusCode != status {
+			t.Errorf("unexpected status of %s: got %d, want %d", path, resp.StatusCode, status)
+		}
+	}
+	if resp := request(http.MethodDelete, "/v2/factory/app/blobs/"+layer.String()); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected the registry to be read-only, got %d", resp.StatusCode)
+	}
+
+	// The store is usable as a registry by the registry client
+	resolver := NewResolver(nil, server.Client())
+	ref := strings.TrimPrefix(server.URL, "https://") + "/factory/app@" + layer.String()
+	fetcher, err := resolver.Fetcher(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := fetcher.Fetch(context.Background(), ocispec.Descriptor{Digest: layer, Size: int64(len("layer data"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if b, err := io.ReadAll(rc); err != nil || string(b) != "layer data" {
+		t.Errorf("unexpected blob fetched by the registry client: %q %v", b, err)
+	}
+}
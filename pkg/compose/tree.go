@@ -72,3 +72,16 @@ func (t *TreeNode) GetServiceHash() string {
 	}
 	return t.Descriptor.Annotations[AppServiceHashLabelKey]
 }
+
+func (t *TreeNode) GetServiceName() string {
+	switch t.Type {
+	case BlobTypeImageIndex, BlobTypeSkopeoImageIndex, BlobTypeImageManifest:
+	default:
+		return ""
+	}
+
+	if t.Descriptor == nil {
+		return ""
+	}
+	return t.Descriptor.Annotations[AppServiceNameAnnotationKey]
+}
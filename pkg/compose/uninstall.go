@@ -5,21 +5,35 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/containerd/containerd/reference/docker"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 )
 
 type (
 	UninstallOpts struct {
-		Prune     bool
-		PruneType PruneType
+		Prune         bool
+		PruneType     PruneType
+		RemoveVolumes bool
+		Report        UninstallReport
 	}
 	UninstallOpt func(*UninstallOpts)
 
+	// RemovedResources lists the docker resources of an app compose project removed by the app uninstallation.
+	RemovedResources struct {
+		Containers []string `json:"containers,omitempty"`
+		Networks   []string `json:"networks,omitempty"`
+		Volumes    []string `json:"volumes,omitempty"`
+	}
+	// UninstallReport maps names of the uninstalled apps to the docker resources removed along with them.
+	UninstallReport map[string]*RemovedResources
+
 	PruneType string
 )
 
@@ -40,6 +54,23 @@ func WithImagePruning(pruneType ...PruneType) UninstallOpt {
 	}
 }
 
+// WithVolumeRemoval makes the uninstallation remove the named volumes of the app compose projects too,
+// hence the app data stored in them is lost.
+func WithVolumeRemoval(remove bool) UninstallOpt {
+	return func(opts *UninstallOpts) {
+		opts.RemoveVolumes = remove
+	}
+}
+
+// WithUninstallReport makes the uninstallation record the docker resources removed along with the apps.
+func WithUninstallReport(report UninstallReport) UninstallOpt {
+	return func(opts *UninstallOpts) {
+		opts.Report = report
+	}
+}
+
+// UninstallApps removes the compose projects of the given apps, along with the containers and networks of the projects,
+// and optionally with their volumes and images.
 func UninstallApps(ctx context.Context, cfg *Config, appRefs []string, options ...UninstallOpt) error {
 	opts := &UninstallOpts{}
 	for _, o := range options {
@@ -68,24 +99,26 @@ func UninstallApps(ctx context.Context, cfg *Config, appRefs []string, options .
 		if err != nil {
 			return err
 		}
-		appsInStore := make(map[string]int)
-		for _, ref := range appInStoreRefs {
-			appsInStore[ref.Name] += 1
-		}
-		for _, app := range status.Apps {
-			if appsInStore[app.Name()] > 1 {
-				// Multiple versions of the same app exist in the store.
-				// If the version being removed is not installed, another version may still be
-				// installed and using the same compose directory. In that case, keep the app
-				// compose directory; otherwise we could remove compose files needed by the
-				// other installed version.
-				if _, isNotInstalled := status.NotInstalledCompose[app.Ref().Digest]; isNotInstalled {
-					continue
-				}
+		cli, err := GetDockerClient(cfg.DockerHost)
+		if err != nil {
+			return fmt.Errorf("failed to create docker client: %w", err)
+		}
+		for _, app := range getAppsToUninstall(status, appInStoreRefs) {
+			// The docker resources are removed before the compose directory, so the uninstallation can be
+			// retried if it fails to remove any of them
+			removed, err := removeProjectResources(ctx, cli, app.Name(), opts.RemoveVolumes)
+			if opts.Report != nil {
+				opts.Report[app.Name()] = removed
+			}
+			if err != nil {
+				return fmt.Errorf("failed to remove docker resources of %s: %w", app.Name(), err)
 			}
 			if err = os.RemoveAll(cfg.GetAppComposeDir(app.Name())); err != nil {
 				return err
 			}
+			if err = os.RemoveAll(cfg.GetAppComposePrevDir(app.Name())); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -124,45 +157,90 @@ func UninstallApps(ctx context.Context, cfg *Config, appRefs []string, options .
 		case PruneTypeOnlyAppImages:
 			// Build a map of image refs to image summary for images that are not in use by any container.
 			// We will use this map to check if an image ref related to the uninstalled apps is used by any container before removing it.
-			imageRefsNotInUse := make(map[string]image.Summary)
-			setAllImageRefVariants := func(ref string) {
-				imageRefsNotInUse[ref] = imagesNotInUse[ref]
-				// Make sure to consider all variants of the same image ref, "normalized" and "familiar"
-				if anyRef, err := docker.ParseAnyReference(ref); err == nil {
-					imageRefsNotInUse[anyRef.String()] = imagesNotInUse[ref]
-					if familiarRef := docker.FamiliarString(anyRef); len(familiarRef) > 0 {
-						imageRefsNotInUse[familiarRef] = imagesNotInUse[ref]
-					}
-				}
-			}
-			for _, img := range imagesNotInUse {
-				for _, ref := range img.RepoDigests {
-					setAllImageRefVariants(ref)
-				}
-				for _, ref := range img.RepoTags {
-					setAllImageRefVariants(ref)
-				}
-			}
 			// Remove images that are referenced by the apps being uninstalled and are not in use by any container.
-			removeAppImageRefs(ctx, status.Apps, cli, imageRefsNotInUse)
+			removeAppImageRefs(ctx, status.Apps, cli, getImageRefsNotInUse(imagesNotInUse))
 		}
 	}
 	return err
 }
 
+// getAppsToUninstall returns the apps which compose projects are to be removed. If multiple versions of the same app
+// exist in the store and the version being removed is not installed, another version may still be installed
+// and using the same compose directory. In that case, the app compose directory is kept; otherwise we could remove
+// compose files needed by the other installed version.
+func getAppsToUninstall(status *AppsStatus, appInStoreRefs []*AppRef) []App {
+	appsInStore := make(map[string]int)
+	for _, ref := range appInStoreRefs {
+		appsInStore[ref.Name] += 1
+	}
+	var apps []App
+	for _, app := range status.Apps {
+		if appsInStore[app.Name()] > 1 {
+			if _, isNotInstalled := status.NotInstalledCompose[app.Ref().Digest]; isNotInstalled {
+				continue
+			}
+		}
+		apps = append(apps, app)
+	}
+	return apps
+}
+
+// getImageRefsNotInUse maps all refs of the given images to the images, so a ref related to the uninstalled apps
+// can be checked for being used by any container before removing it.
+func getImageRefsNotInUse(imagesNotInUse map[string]image.Summary) map[string]image.Summary {
+	imageRefsNotInUse := make(map[string]image.Summary)
+	setAllImageRefVariants := func(ref string, img image.Summary) {
+		imageRefsNotInUse[ref] = img
+		// Make sure to consider all variants of the same image ref, "normalized" and "familiar"
+		if anyRef, err := docker.ParseAnyReference(ref); err == nil {
+			imageRefsNotInUse[anyRef.String()] = img
+			if familiarRef := docker.FamiliarString(anyRef); len(familiarRef) > 0 {
+				imageRefsNotInUse[familiarRef] = img
+			}
+		}
+	}
+	for _, img := range imagesNotInUse {
+		for _, ref := range img.RepoDigests {
+			setAllImageRefVariants(ref, img)
+		}
+		for _, ref := range img.RepoTags {
+			setAllImageRefVariants(ref, img)
+		}
+	}
+	return imageRefsNotInUse
+}
+
 func removeAppImageRefs(ctx context.Context, apps []App, cli *client.Client, imageRefsNotInUse map[string]image.Summary) {
-	// Collect image refs related to the uninstalled apps.
-	var imageRefsToPrune []string
+	// Remove image refs related to the uninstalled apps and images the refs point to are not in use by any container.
+	// If the removed ref is the only ref for the image, the image will also be removed;
+	// if there are other refs for the image, only the removed ref will be removed.
+	// This is the best effort to remove images related to the uninstalled apps without
+	// affecting other apps that may share the same images.
+	// In some case it can remove an image for which there is no container but some other utility reference it
+	// by the same reference as the uninstalled app, but that is an acceptable edge case and best effort
+	// to clean up images related to the uninstalled apps.
+	for _, ref := range getAppImageRefs(apps) {
+		if _, notInUse := imageRefsNotInUse[ref]; notInUse {
+			// TODO: print debug message about which image is being removed and any error that occurs during removal.
+			_, _ = cli.ImageRemove(ctx, ref, types.ImageRemoveOptions{Force: false, PruneChildren: true})
+		}
+	}
+}
+
+// getAppImageRefs returns the digest and tag refs of the app images, and of the image manifests
+// if an app image is referenced by an image index.
+func getAppImageRefs(apps []App) []string {
+	var imageRefs []string
 	for _, app := range apps {
 		for _, imageRoot := range app.GetComposeRoot().Children {
 			curImageRoot := imageRoot
 			for {
 				imageRef := curImageRoot.Ref()
 				// Add a digest ref
-				imageRefsToPrune = append(imageRefsToPrune, imageRef)
+				imageRefs = append(imageRefs, imageRef)
 				if ref, err := ParseImageRef(imageRef); err == nil {
 					// Add a tag ref
-					imageRefsToPrune = append(imageRefsToPrune, ref.GetTagRef())
+					imageRefs = append(imageRefs, ref.GetTagRef())
 				}
 				if curImageRoot.Type == BlobTypeImageManifest || len(curImageRoot.Children) == 0 {
 					break
@@ -172,18 +250,58 @@ func removeAppImageRefs(ctx context.Context, apps []App, cli *client.Client, ima
 			}
 		}
 	}
-	// Remove image refs related to the uninstalled apps and images the refs point to are not in use by any container.
-	// If the removed ref is the only ref for the image, the image will also be removed;
-	// if there are other refs for the image, only the removed ref will be removed.
-	// This is the best effort to remove images related to the uninstalled apps without
-	// affecting other apps that may share the same images.
-	// In some case it can remove an image for which there is no container but some other utility reference it
-	// by the same reference as the uninstalled app, but that is an acceptable edge case and best effort
-	// to clean up images related to the uninstalled apps.
-	for _, ref := range imageRefsToPrune {
-		if _, notInUse := imageRefsNotInUse[ref]; notInUse {
-			// TODO: print debug message about which image is being removed and any error that occurs during removal.
-			_, _ = cli.ImageRemove(ctx, ref, types.ImageRemoveOptions{Force: false, PruneChildren: true})
+	return imageRefs
+}
+
+// removeProjectResources stops and removes containers, removes networks and optionally volumes labeled as belonging to the given
+// compose project.
+func removeProjectResources(ctx context.Context, cli *client.Client, projectName string, removeVolumes bool) (*RemovedResources, error) {
+	removed := &RemovedResources{}
+	projectFilter := filters.NewArgs(filters.Arg("label", ProjectLabel+"="+projectName))
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true, Filters: projectFilter})
+	if err != nil {
+		return removed, fmt.Errorf("failed to list containers: %w", err)
+	}
+	for _, ctr := range containers {
+		if ctr.State == "running" {
+			// Stop the container gracefully, with the stop signal and timeout of its service
+			if err := cli.ContainerStop(ctx, ctr.ID, container.StopOptions{}); err != nil && !client.IsErrNotFound(err) {
+				return removed, fmt.Errorf("failed to stop container %s: %w", ctr.ID, err)
+			}
+		}
+		if err := cli.ContainerRemove(ctx, ctr.ID, container.RemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+			return removed, fmt.Errorf("failed to remove container %s: %w", ctr.ID, err)
+		}
+		name := ctr.ID
+		if len(ctr.Names) > 0 {
+			name = strings.TrimPrefix(ctr.Names[0], "/")
+		}
+		removed.Containers = append(removed.Containers, name)
+	}
+
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{Filters: projectFilter})
+	if err != nil {
+		return removed, fmt.Errorf("failed to list networks: %w", err)
+	}
+	for _, n := range networks {
+		if err := cli.NetworkRemove(ctx, n.ID); err != nil && !client.IsErrNotFound(err) {
+			return removed, fmt.Errorf("failed to remove network %s: %w", n.Name, err)
+		}
+		removed.Networks = append(removed.Networks, n.Name)
+	}
+
+	if removeVolumes {
+		volumes, err := cli.VolumeList(ctx, volume.ListOptions{Filters: projectFilter})
+		if err != nil {
+			return removed, fmt.Errorf("failed to list volumes: %w", err)
+		}
+		for _, v := range volumes.Volumes {
+			if err := cli.VolumeRemove(ctx, v.Name, false); err != nil && !client.IsErrNotFound(err) {
+				return removed, fmt.Errorf("failed to remove volume %s: %w", v.Name, err)
+			}
+			removed.Volumes = append(removed.Volumes, v.Name)
 		}
 	}
+	return removed, nil
 }
@@ -0,0 +1,87 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestGetAppsToUninstall(t *testing.T) {
+	app1 := &testApp{name: "app1", ref: &AppRef{Name: "app1", Digest: digest.FromString("app1")}}
+	app2 := &testApp{name: "app2", ref: &AppRef{Name: "app2", Digest: digest.FromString("app2-v1")}}
+	app3 := &testApp{name: "app3", ref: &AppRef{Name: "app3", Digest: digest.FromString("app3-v1")}}
+	status := &AppsStatus{
+		Apps: []App{app1, app2, app3},
+		InstallStatus: &InstallStatus{
+			NotInstalledCompose: map[digest.Digest]interface{}{
+				app1.ref.Digest: nil,
+				app2.ref.Digest: nil,
+			},
+		},
+	}
+	inStore := []*AppRef{
+		app1.ref,
+		app2.ref, {Name: "app2", Digest: digest.FromString("app2-v2")},
+		app3.ref, {Name: "app3", Digest: digest.FromString("app3-v2")},
+	}
+
+	// app1 is the only version in the store, so its leftovers are removed even if it is not installed;
+	// another version of app2 may be installed, so its project is kept; app3 is the installed version
+	apps := getAppsToUninstall(status, inStore)
+	var names []string
+	for _, app := range apps {
+		names = append(names, app.Name())
+	}
+	if expected := []string{"app1", "app3"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected apps to uninstall %v, got %v", expected, names)
+	}
+}
+
+func TestGetAppImageRefs(t *testing.T) {
+	imageDigest := digest.FromString("image-index")
+	manifestDigest := digest.FromString("image-manifest")
+	indexRef := "registry.local/factory/image@" + imageDigest.String()
+	manifestRef := "registry.local/factory/image@" + manifestDigest.String()
+	app := &testApp{name: "app1", composeRoot: &TreeNode{
+		Children: []*TreeNode{{
+			Type:       BlobTypeImageIndex,
+			Descriptor: &ocispec.Descriptor{Digest: imageDigest, URLs: []string{indexRef}},
+			Children: []*TreeNode{{
+				Type:       BlobTypeImageManifest,
+				Descriptor: &ocispec.Descriptor{Digest: manifestDigest, URLs: []string{manifestRef}},
+			}},
+		}},
+	}}
+
+	refs := getAppImageRefs([]App{app})
+	expected := []string{
+		indexRef, "registry.local/factory/image:" + imageDigest.Encoded()[:7],
+		manifestRef, "registry.local/factory/image:" + manifestDigest.Encoded()[:7],
+	}
+	if !reflect.DeepEqual(refs, expected) {
+		t.Errorf("expected image refs %v, got %v", expected, refs)
+	}
+}
+
+func TestGetImageRefsNotInUse(t *testing.T) {
+	img := image.Summary{
+		ID:          "sha256:" + digest.FromString("image").Encoded(),
+		RepoTags:    []string{"alpine:3.20"},
+		RepoDigests: []string{"registry.local/factory/image@" + digest.FromString("manifest").String()},
+	}
+	refs := getImageRefsNotInUse(map[string]image.Summary{img.ID: img})
+	for _, ref := range []string{
+		"alpine:3.20",
+		"docker.io/library/alpine:3.20",
+		"registry.local/factory/image@" + digest.FromString("manifest").String(),
+	} {
+		if found, ok := refs[ref]; !ok {
+			t.Errorf("image ref %s is not found", ref)
+		} else if found.ID != img.ID {
+			t.Errorf("image ref %s points to image %s instead of %s", ref, found.ID, img.ID)
+		}
+	}
+}
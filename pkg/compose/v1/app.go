@@ -2,6 +2,7 @@ package v1
 
 import (
 	"archive/tar"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -9,8 +10,10 @@ import (
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 
+	"github.com/compose-spec/compose-go/dotenv"
 	"github.com/compose-spec/compose-go/loader"
 	composetypes "github.com/compose-spec/compose-go/types"
 	"github.com/containerd/containerd/errdefs"
@@ -42,9 +45,20 @@ type (
 		tree       *compose.AppTree
 		storeType  StoreType
 		nodeCount  int
+		env        map[string]string
+		profiles   []string
 	}
 
-	appLoader struct{}
+	appLoader struct {
+		envProvider      AppEnvProvider
+		profilesProvider AppProfilesProvider
+	}
+	// AppEnvProvider returns the environment used for interpolation of app compose projects
+	AppEnvProvider func() (map[string]string, error)
+	// AppProfilesProvider returns the compose profiles of the app that are active,
+	// services that belong only to inactive profiles are excluded from the app tree
+	AppProfilesProvider func(appName string) ([]string, error)
+	AppLoaderOption     func(*appLoader)
 
 	fileInfo struct {
 		name   string
@@ -56,16 +70,18 @@ type (
 const (
 	AppManifestMediaType   = "application/vnd.oci.image.manifest.v1+json"
 	AppManifestMaxSize     = 50 * 1024
-	AppComposeMaxSize      = 1024 * 1024
+	AppComposeMaxSize      = compose.AppComposeFileMaxSize
 	AppBundleFileMaxSize   = 1024*1024*1024 - AppComposeMaxSize
 	AppBundleMaxSize       = AppComposeMaxSize + AppBundleFileMaxSize
 	AppLayerMediaType      = "application/octet-stream"
 	AppLayersMetaVersion   = "v1"
 	AppServiceHashLabelKey = "io.compose-spec.config-hash"
 
-	AnnotationKeyAppBundleIndexDigest = "org.foundries.app.bundle.index.digest"
-	AnnotationKeyAppBundleIndexSize   = "org.foundries.app.bundle.index.size"
-	AnnotationKeyAppServiceName       = "org.foundries.app.service.name"
+	AnnotationKeyAppBundleIndexDigest   = "org.foundries.app.bundle.index.digest"
+	AnnotationKeyAppBundleIndexSize     = "org.foundries.app.bundle.index.size"
+	AnnotationKeyAppBundleIndexV2Digest = "org.foundries.app.bundle.index.v2.digest"
+	AnnotationKeyAppBundleIndexV2Size   = "org.foundries.app.bundle.index.v2.size"
+	AnnotationKeyAppServiceName         = "org.foundries.app.service.name"
 
 	StoreTypeSkopeo     = "skopeo store"
 	StoreTypeComposeCtl = "composectl store"
@@ -75,6 +91,10 @@ func (a *appCtx) Name() string {
 	return a.AppRef.Name
 }
 
+func (a *appCtx) Annotations() map[string]string {
+	return a.manifest.Annotations
+}
+
 func (a *appCtx) Tree() *compose.AppTree {
 	return a.tree
 }
@@ -111,8 +131,24 @@ func (a *appCtx) GetBlobRuntimeSize(desc *ocispec.Descriptor, arch string, block
 	}
 }
 
-func NewAppLoader() compose.AppLoader {
-	return &appLoader{}
+func WithAppEnvProvider(envProvider AppEnvProvider) AppLoaderOption {
+	return func(l *appLoader) {
+		l.envProvider = envProvider
+	}
+}
+
+func WithAppProfilesProvider(profilesProvider AppProfilesProvider) AppLoaderOption {
+	return func(l *appLoader) {
+		l.profilesProvider = profilesProvider
+	}
+}
+
+func NewAppLoader(options ...AppLoaderOption) compose.AppLoader {
+	l := &appLoader{}
+	for _, o := range options {
+		o(l)
+	}
+	return l
 }
 
 func (l *appLoader) LoadAppTree(ctx context.Context, provider compose.BlobProvider, platform platforms.MatchComparer, ref string) (compose.App, error) {
@@ -124,6 +160,16 @@ func (l *appLoader) LoadAppTree(ctx context.Context, provider compose.BlobProvid
 		}
 		return nil, fmt.Errorf("failed to read app manifest: %w", err)
 	}
+	if l.envProvider != nil {
+		if app.env, err = l.envProvider(); err != nil {
+			return nil, err
+		}
+	}
+	if l.profilesProvider != nil {
+		if app.profiles, err = l.profilesProvider(app.Name()); err != nil {
+			return nil, err
+		}
+	}
 	appTree := compose.AppTree{Descriptor: rootDesc, Type: compose.BlobTypeAppManifest}
 
 	// depth 1, layers meta (optional)
@@ -277,7 +323,11 @@ func (a *appCtx) GetLayersMetadataDescriptor() (*ocispec.Descriptor, error) {
 	return &desc, nil
 }
 
-func (a *appCtx) CheckComposeInstallation(ctx context.Context, provider compose.BlobProvider, installationRootDir string) (bundleErrs compose.AppBundleErrs, err error) {
+func (a *appCtx) CheckComposeInstallation(ctx context.Context, provider compose.BlobProvider, installationRootDir string, options ...compose.CheckComposeOption) (bundleErrs compose.AppBundleErrs, err error) {
+	opts := compose.CheckComposeOptions{}
+	for _, o := range options {
+		o(&opts)
+	}
 	appIndex, errBundleIndx := a.getAppBundleIndex(ctx, provider)
 	if errBundleIndx != nil {
 		if errBundleIndx == compose.ErrAppHasNoIndex {
@@ -286,26 +336,10 @@ func (a *appCtx) CheckComposeInstallation(ctx context.Context, provider compose.
 			return nil, errBundleIndx
 		}
 	}
-
-	bundleErrMap := compose.AppBundleErrs{}
-	for filePath, fileDigest := range appIndex {
-		f, err := os.Open(path.Join(installationRootDir, filePath))
-		if os.IsNotExist(err) {
-			bundleErrMap[filePath] = err.Error()
-			continue
-		}
-		r, err := compose.NewSecureReadCloser(f, compose.WithExpectedDigest(fileDigest), compose.WithReadLimit(AppBundleFileMaxSize))
-		if err != nil {
-			return nil, err
-		}
-		if _, err := io.ReadAll(r); err != nil {
-			bundleErrMap[filePath] = err.Error()
-		}
-	}
-	if len(bundleErrMap) > 0 {
-		bundleErrs = bundleErrMap
+	if _, err := os.Stat(installationRootDir); err != nil {
+		return compose.AppBundleErrs{installationRootDir: err.Error()}, nil
 	}
-	return
+	return appIndex.Check(installationRootDir, opts.AllowedExtraFiles)
 }
 
 func (a *appCtx) checkAppBundleInstallation(ctx context.Context, provider compose.BlobProvider, installationRootDir string) (bundleErrs compose.AppBundleErrs, err error) {
@@ -348,7 +382,7 @@ func (a *appCtx) checkAppBundleInstallation(ctx context.Context, provider compos
 	return
 }
 
-func (a *appCtx) getAppBundleIndex(ctx context.Context, blobProvider compose.BlobProvider) (map[string]digest.Digest, error) {
+func (a *appCtx) getAppBundleIndex(ctx context.Context, blobProvider compose.BlobProvider) (*AppBundleIndex, error) {
 	indexNode := getChildByType(a.tree.Children, compose.BlobTypeAppIndex)
 	if indexNode == nil {
 		return nil, compose.ErrAppHasNoIndex
@@ -371,8 +405,8 @@ func (a *appCtx) getAppBundleIndex(ctx context.Context, blobProvider compose.Blo
 		return nil, fmt.Errorf("failed to read app bundle index: %s", err.Error())
 	}
 
-	var appIndex map[string]digest.Digest
-	if err := json.Unmarshal(b, &appIndex); err != nil {
+	appIndex, err := ParseAppBundleIndex(b)
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal app bundle index: %s", err.Error())
 	}
 	return appIndex, nil
@@ -429,60 +463,91 @@ func getChildByType(children []*compose.TreeNode, childType compose.BlobType) *c
 	return nil
 }
 
-func readCompose(ctx context.Context, provider compose.BlobProvider, app *appCtx) ([]byte, *ocispec.Descriptor, error) {
+// readComposeFiles reads the compose project files and the env file from the app bundle.
+// It returns the files content and the path of the main compose file.
+func readComposeFiles(ctx context.Context, provider compose.BlobProvider, app *appCtx) (map[string][]byte, string, *ocispec.Descriptor, error) {
 	composeDesc, err := app.GetComposeDescriptor()
 	if err != nil {
-		return nil, nil, err
+		return nil, "", nil, err
 	}
 
-	// Read and parse App compose project
-	srcReader, err := provider.GetReadCloser(compose.WithBlobType(compose.WithAppRef(ctx, &app.AppRef), compose.BlobTypeAppBundle),
-		compose.WithRef(app.GetBlobRef(composeDesc.Digest)),
-		compose.WithExpectedDigest(composeDesc.Digest), compose.WithExpectedSize(composeDesc.Size))
+	// Any bundle file can be referenced by the compose file, so read all regular files that may be compose files
+	bundleFiles, err := compose.ReadAppBundleFiles(ctx, provider, &app.AppRef, composeDesc, AppComposeMaxSize)
 	if err != nil {
-		return nil, nil, err
+		return nil, "", nil, err
 	}
-	defer srcReader.Close()
 
-	r, err := archive.DecompressStream(srcReader)
+	mainFile, err := compose.FindComposeFile(func(fileName string) bool {
+		_, ok := bundleFiles[fileName]
+		return ok
+	})
 	if err != nil {
-		return nil, nil, err
+		return nil, "", nil, err
 	}
-	defer r.Close()
-	tr := tar.NewReader(r)
-
-	var composeBytes []byte
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			break // End of archive
-		}
-		if err != nil {
-			return nil, nil, err
-		}
-		// TODO: support different compose file names and multi-file compose projects
-		if hdr.Name == "docker-compose.yml" {
-			composeBytes, err = io.ReadAll(tr)
-			if err != nil {
-				return nil, nil, err
-			}
+	composeFiles, err := compose.GetComposeFiles(mainFile, func(filePath string) ([]byte, error) {
+		if b, ok := bundleFiles[filePath]; ok {
+			return b, nil
 		}
+		return nil, fmt.Errorf("compose file is not found in the app bundle: %s", filePath)
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	files := map[string][]byte{}
+	for _, f := range composeFiles {
+		files[f] = bundleFiles[f]
 	}
-	return composeBytes, composeDesc, nil
+	if b, ok := bundleFiles[compose.AppEnvFile]; ok {
+		files[compose.AppEnvFile] = b
+	}
+	return files, mainFile, composeDesc, nil
 }
 
 func readAndLoadComposeProject(ctx context.Context, provider compose.BlobProvider, app *appCtx) (*composetypes.Project, *ocispec.Descriptor, error) {
-	composeBytes, composeDesc, err := readCompose(ctx, provider, app)
+	files, mainFile, composeDesc, err := readComposeFiles(ctx, provider, app)
 	if err != nil {
 		return nil, nil, err
 	}
+
+	// Variables defined by the device take precedence over the ones defined in the bundled env file,
+	// the same way as `docker compose` is invoked to start the app.
+	env := map[string]string{}
+	if b, ok := files[compose.AppEnvFile]; ok {
+		if env, err = dotenv.Parse(bytes.NewReader(b)); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", compose.AppEnvFile, err)
+		}
+		delete(files, compose.AppEnvFile)
+	}
+	for k, v := range app.env {
+		env[k] = v
+	}
 	cfgDetails := composetypes.ConfigDetails{
 		ConfigFiles: []composetypes.ConfigFile{
 			{
-				Filename: "docker-compose.yml",
-				Content:  composeBytes,
+				Filename: mainFile,
+				Content:  files[mainFile],
 			},
 		},
+		Environment: env,
+	}
+	if len(files) > 1 {
+		// The compose loader reads the files referenced by `include` and `extends` from a file system
+		workingDir, err := os.MkdirTemp("", "compose-"+app.Name()+"-")
+		if err != nil {
+			return nil, nil, err
+		}
+		defer os.RemoveAll(workingDir)
+		for f, b := range files {
+			p := filepath.Join(workingDir, filepath.FromSlash(f))
+			if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+				return nil, nil, err
+			}
+			if err := os.WriteFile(p, b, 0600); err != nil {
+				return nil, nil, err
+			}
+		}
+		cfgDetails.WorkingDir = workingDir
+		cfgDetails.ConfigFiles[0].Filename = filepath.Join(workingDir, mainFile)
 	}
 
 	// Temporarily suppress logrus output below Error level during compose project loading
@@ -495,6 +560,9 @@ func readAndLoadComposeProject(ctx context.Context, provider compose.BlobProvide
 		//options.SkipNormalization = true
 		//options.SkipConsistencyCheck = true
 		options.SetProjectName(app.Name(), true)
+		options.Profiles = app.profiles
+		// Service `env_file` may refer to files that are present only on a device
+		options.SkipResolveEnvironment = true
 	})
 	if err != nil {
 		return nil, nil, err
@@ -513,12 +581,21 @@ func parseAndCheckAppRef(ref string) (*compose.AppRef, error) {
 	return appRef, nil
 }
 
+// getAppIndexNodeIfPresent returns the app bundle index node, the v2 index is preferred over the legacy one
+// if the app bundle is published with both.
 func getAppIndexNodeIfPresent(appRef *compose.AppRef, appBundleDesc *ocispec.Descriptor) *compose.TreeNode {
-	indexDigestStr, ok := appBundleDesc.Annotations[AnnotationKeyAppBundleIndexDigest]
+	if indexNode := getIndexNode(appRef, appBundleDesc, AnnotationKeyAppBundleIndexV2Digest, AnnotationKeyAppBundleIndexV2Size); indexNode != nil {
+		return indexNode
+	}
+	return getIndexNode(appRef, appBundleDesc, AnnotationKeyAppBundleIndexDigest, AnnotationKeyAppBundleIndexSize)
+}
+
+func getIndexNode(appRef *compose.AppRef, appBundleDesc *ocispec.Descriptor, digestKey string, sizeKey string) *compose.TreeNode {
+	indexDigestStr, ok := appBundleDesc.Annotations[digestKey]
 	if !ok {
 		return nil
 	}
-	indexSizeStr, ok := appBundleDesc.Annotations[AnnotationKeyAppBundleIndexSize]
+	indexSizeStr, ok := appBundleDesc.Annotations[sizeKey]
 	if !ok {
 		return nil
 	}
@@ -156,8 +156,12 @@ func (s *appStore) Prune(ctx context.Context) ([]string, error) {
 		return nil, err
 	}
 	referencedBlobs := map[string]bool{}
+	// Keep blobs of services of all profiles, since profiles can be activated on a device at any time
+	appLoader := NewAppLoader(WithAppProfilesProvider(func(string) ([]string, error) {
+		return []string{"*"}, nil
+	}))
 	for _, a := range apps {
-		app, err := NewAppLoader().LoadAppTree(ctx, s, platforms.OnlyStrict(s.platform), a.String())
+		app, err := appLoader.LoadAppTree(ctx, s, platforms.OnlyStrict(s.platform), a.String())
 		if err != nil {
 			return nil, err
 		}
@@ -187,6 +191,17 @@ func (s *appStore) Prune(ctx context.Context) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	// The markers of blobs discarded after loading them into docker are pruned along with the blobs
+	loadedBlobsRoot := compose.GetLoadedBlobsRootFor(s.root)
+	if entries, err := os.ReadDir(loadedBlobsRoot); err == nil {
+		for _, e := range entries {
+			if _, ok := referencedBlobs[e.Name()]; !ok && !e.IsDir() {
+				blobsToPrune = append(blobsToPrune, filepath.Join(loadedBlobsRoot, e.Name()))
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
 	for _, p := range blobsToPrune {
 		if err := os.RemoveAll(p); err != nil {
 			return nil, err
@@ -285,6 +300,9 @@ func (s *appStore) Info(ctx context.Context, dgst digest.Digest) (content.Info,
 func MakeAkliteHappy(ctx context.Context, store compose.AppStore, app compose.App, platformMatcher platforms.MatchComparer) error {
 	storeV1 := store.(*appStore)
 	appV1 := app.(*appCtx)
+	if err := checkImageLayersPresence(storeV1.root, app); err != nil {
+		return err
+	}
 	appDir := path.Join(storeV1.root, "apps", app.Name(), appV1.Digest.Encoded())
 	if err := os.MkdirAll(appDir, 0777); err != nil {
 		return err
@@ -321,8 +339,9 @@ func MakeAkliteHappy(ctx context.Context, store compose.AppStore, app compose.Ap
 			return err
 		}
 	}
-	if b, _, err := readCompose(ctx, storeV1.bp, appV1); err == nil {
-		if writeErr := writeAndSync(path.Join(appDir, "docker-compose.yml"), b); writeErr != nil {
+	// The legacy store layout expects the main compose file to be named `docker-compose.yml`
+	if files, mainFile, _, err := readComposeFiles(ctx, storeV1.bp, appV1); err == nil {
+		if writeErr := writeAndSync(path.Join(appDir, "docker-compose.yml"), files[mainFile]); writeErr != nil {
 			fmt.Printf("Failed to write compose file: %s\n", writeErr.Error())
 		}
 	} else {
@@ -381,6 +400,24 @@ func MakeAkliteHappy(ctx context.Context, store compose.AppStore, app compose.Ap
 	return err
 }
 
+// checkImageLayersPresence checks that each app image layer is either in the store, or it was discarded
+// from the store by the low-storage fetch after loading the image into docker.
+func checkImageLayersPresence(storeRoot string, app compose.App) error {
+	return app.GetComposeRoot().Walk(func(node *compose.TreeNode, depth int) error {
+		if node.Type != compose.BlobTypeImageLayer {
+			return nil
+		}
+		if _, err := os.Stat(path.Join(compose.GetBlobsRootFor(storeRoot), node.Descriptor.Digest.Encoded())); err == nil {
+			return nil
+		}
+		if _, err := os.Stat(path.Join(compose.GetLoadedBlobsRootFor(storeRoot), node.Descriptor.Digest.Encoded())); err == nil {
+			return nil
+		}
+		return fmt.Errorf("image layer %s of app %s is neither in the store nor loaded into docker",
+			node.Descriptor.Digest, app.Name())
+	})
+}
+
 func writeAndSync(path string, data []byte) error {
 	tmpfile := path + ".tmp"
 	f, err := os.OpenFile(tmpfile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/containerd/containerd/platforms"
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestLoadAppTreeProfiles(t *testing.T) {
+	blobsRoot := t.TempDir()
+	writeBlob := func(mediaType string, data []byte) ocispec.Descriptor {
+		d := digest.FromBytes(data)
+		if err := os.WriteFile(filepath.Join(blobsRoot, d.Encoded()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return ocispec.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(data))}
+	}
+	writeJSON := func(mediaType string, v interface{}) ocispec.Descriptor {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return writeBlob(mediaType, b)
+	}
+
+	imageDesc := writeJSON(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    writeJSON(ocispec.MediaTypeImageConfig, ocispec.Image{}),
+		Layers:    []ocispec.Descriptor{writeBlob(ocispec.MediaTypeImageLayerGzip, []byte("layer"))},
+	})
+	composeFile := []byte(`
+services:
+  web:
+    image: hub.foundries.io/factory/web@` + imageDesc.Digest.String() + `
+    labels:
+      io.compose-spec.config-hash: web
+  debug:
+    image: hub.foundries.io/factory/debug@` + imageDesc.Digest.String() + `
+    labels:
+      io.compose-spec.config-hash: debug
+    profiles: ["debug"]
+`)
+	var bundle bytes.Buffer
+	tw := tar.NewWriter(&bundle)
+	if err := tw.WriteHeader(&tar.Header{Name: "docker-compose.yml", Mode: 0644, Size: int64(len(composeFile))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(composeFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	appDesc := writeJSON(AppManifestMediaType, ocispec.Manifest{
+		MediaType: AppManifestMediaType,
+		Config:    writeJSON("application/json", map[string]string{}),
+		Layers:    []ocispec.Descriptor{writeBlob(AppLayerMediaType, bundle.Bytes())},
+	})
+	appURI := "hub.foundries.io/factory/app@" + appDesc.Digest.String()
+
+	serviceNames := func(profiles []string) []string {
+		loader := NewAppLoader(WithAppProfilesProvider(func(appName string) ([]string, error) {
+			if appName != "app" {
+				t.Errorf("unexpected app name passed to the profiles provider: %s", appName)
+			}
+			return profiles, nil
+		}))
+		app, err := loader.LoadAppTree(context.Background(), compose.NewStoreBlobProvider(blobsRoot),
+			platforms.All, appURI)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, imageNode := range app.GetComposeRoot().Children {
+			names = append(names, imageNode.GetServiceName())
+		}
+		sort.Strings(names)
+		return names
+	}
+	if names := serviceNames(nil); !reflect.DeepEqual(names, []string{"web"}) {
+		t.Errorf("unexpected services with no active profiles: %v", names)
+	}
+	if names := serviceNames([]string{"debug"}); !reflect.DeepEqual(names, []string{"debug", "web"}) {
+		t.Errorf("unexpected services with the debug profile active: %v", names)
+	}
+}
@@ -0,0 +1,255 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"github.com/opencontainers/go-digest"
+)
+
+type (
+	BundleEntryType string
+
+	// AppBundleIndexEntry describes a single entry of an app bundle archive
+	AppBundleIndexEntry struct {
+		Type BundleEntryType `json:"type"`
+		// Unix permission bits including setuid, setgid and sticky bits
+		Mode   int64         `json:"mode"`
+		Size   int64         `json:"size,omitempty"`
+		Digest digest.Digest `json:"digest,omitempty"`
+		Target string        `json:"target,omitempty"`
+	}
+
+	// AppBundleIndex is an index of all entries of an app bundle archive, regular files, directories and symlinks.
+	// The legacy (v1) index contains only content digests of the bundle's regular files,
+	// and is represented as a map of a file path to its digest.
+	AppBundleIndex struct {
+		Version string                          `json:"version"`
+		Files   map[string]*AppBundleIndexEntry `json:"files"`
+	}
+)
+
+const (
+	AppBundleIndexV1 = "v1"
+	AppBundleIndexV2 = "v2"
+
+	BundleEntryTypeFile    BundleEntryType = "file"
+	BundleEntryTypeDir     BundleEntryType = "dir"
+	BundleEntryTypeSymlink BundleEntryType = "symlink"
+)
+
+func NewAppBundleIndex() *AppBundleIndex {
+	return &AppBundleIndex{
+		Version: AppBundleIndexV2,
+		Files:   map[string]*AppBundleIndexEntry{},
+	}
+}
+
+// ParseAppBundleIndex parses both the legacy (v1) and the current (v2) app bundle index formats.
+func ParseAppBundleIndex(b []byte) (*AppBundleIndex, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	// The legacy index values are always strings (file digests) while the v2 index "files" value is an object
+	if files, ok := raw["files"]; ok && bytes.HasPrefix(bytes.TrimSpace(files), []byte("{")) {
+		index := &AppBundleIndex{}
+		if err := json.Unmarshal(b, index); err != nil {
+			return nil, err
+		}
+		if index.Version != AppBundleIndexV2 {
+			return nil, fmt.Errorf("unsupported app bundle index version: %s", index.Version)
+		}
+		return index, nil
+	}
+
+	var legacyIndex map[string]digest.Digest
+	if err := json.Unmarshal(b, &legacyIndex); err != nil {
+		return nil, err
+	}
+	index := &AppBundleIndex{Version: AppBundleIndexV1, Files: map[string]*AppBundleIndexEntry{}}
+	for filePath, fileDigest := range legacyIndex {
+		index.Files[filePath] = &AppBundleIndexEntry{Type: BundleEntryTypeFile, Digest: fileDigest}
+	}
+	return index, nil
+}
+
+// Legacy returns the index in the legacy (v1) format, which includes only digests of regular files.
+func (i *AppBundleIndex) Legacy() map[string]string {
+	legacyIndex := map[string]string{}
+	for filePath, entry := range i.Files {
+		if entry.Type == BundleEntryTypeFile {
+			legacyIndex[filePath] = entry.Digest.String()
+		}
+	}
+	return legacyIndex
+}
+
+// Check verifies the app project directory against the index. It checks content, mode and ownership of all
+// indexed entries, and reports files that are not in the index unless they match one of the allowed patterns.
+// All entries are expected to be owned by the owner of the project directory.
+// If the index is in the legacy format, then only content of regular files is verified, and extra files
+// are not reported, since apps installed from it may keep their data in the project directory.
+func (i *AppBundleIndex) Check(installationRootDir string, allowedExtraFiles []string) (compose.AppBundleErrs, error) {
+	rootInfo, err := os.Stat(installationRootDir)
+	if err != nil {
+		return nil, err
+	}
+	rootOwner, _ := getOwner(rootInfo)
+	strict := i.Version != AppBundleIndexV1
+
+	bundleErrMap := compose.AppBundleErrs{}
+	for filePath, entry := range i.Files {
+		if errMsg := checkBundleEntry(path.Join(installationRootDir, filePath), entry, strict, rootOwner); len(errMsg) > 0 {
+			bundleErrMap[filePath] = errMsg
+		}
+	}
+
+	if strict {
+		if err := checkBundleExtraFiles(installationRootDir, i.Files, allowedExtraFiles, bundleErrMap); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(bundleErrMap) > 0 {
+		return bundleErrMap, nil
+	}
+	return nil, nil
+}
+
+// checkBundleExtraFiles reports the files of the app project directory which are not in the index.
+func checkBundleExtraFiles(installationRootDir string, files map[string]*AppBundleIndexEntry,
+	allowedExtraFiles []string, bundleErrMap compose.AppBundleErrs) error {
+	// Parent directories of the indexed entries are expected even if they are not indexed
+	parentDirs := map[string]struct{}{}
+	for filePath := range files {
+		for d := path.Dir(filePath); d != "." && d != "/"; d = path.Dir(d) {
+			parentDirs[d] = struct{}{}
+		}
+	}
+	return filepath.WalkDir(installationRootDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(installationRootDir, p)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPath == "." {
+			return nil
+		}
+		if _, ok := files[relPath]; ok {
+			return nil
+		}
+		if isAllowedExtraFile(relPath, allowedExtraFiles) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := parentDirs[relPath]; ok && d.IsDir() {
+			return nil
+		}
+		bundleErrMap[relPath] = "unexpected file, it is not part of the app bundle"
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+func checkBundleEntry(filePath string, entry *AppBundleIndexEntry, strict bool, rootOwner *[2]uint32) string {
+	fi, err := os.Lstat(filePath)
+	if err != nil {
+		return err.Error()
+	}
+	if strict {
+		switch entry.Type {
+		case BundleEntryTypeFile:
+			if !fi.Mode().IsRegular() {
+				return fmt.Sprintf("not a regular file: %s", fi.Mode().Type())
+			}
+		case BundleEntryTypeDir:
+			if !fi.IsDir() {
+				return fmt.Sprintf("not a directory: %s", fi.Mode().Type())
+			}
+		case BundleEntryTypeSymlink:
+			if fi.Mode()&os.ModeSymlink == 0 {
+				return fmt.Sprintf("not a symlink: %s", fi.Mode().Type())
+			}
+			target, err := os.Readlink(filePath)
+			if err != nil {
+				return err.Error()
+			}
+			if target != entry.Target {
+				return fmt.Sprintf("symlink target mismatch: expected %s, got %s", entry.Target, target)
+			}
+		default:
+			return fmt.Sprintf("unsupported bundle entry type: %s", entry.Type)
+		}
+		if owner, ok := getOwner(fi); ok && rootOwner != nil && *owner != *rootOwner {
+			return fmt.Sprintf("ownership mismatch: expected %d:%d, got %d:%d",
+				rootOwner[0], rootOwner[1], owner[0], owner[1])
+		}
+		// Symlink permissions are not meaningful on Linux
+		if entry.Type != BundleEntryTypeSymlink {
+			if mode := getUnixMode(fi); mode != entry.Mode {
+				return fmt.Sprintf("mode mismatch: expected %#o, got %#o", entry.Mode, mode)
+			}
+		}
+	}
+	if entry.Type != BundleEntryTypeFile {
+		return ""
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err.Error()
+	}
+	defer f.Close()
+	readOpts := []compose.SecureReadOptions{compose.WithExpectedDigest(entry.Digest), compose.WithReadLimit(AppBundleFileMaxSize)}
+	if strict {
+		readOpts = append(readOpts, compose.WithExpectedSize(entry.Size))
+	}
+	r, err := compose.NewSecureReadCloser(f, readOpts...)
+	if err != nil {
+		return err.Error()
+	}
+	defer r.Close()
+	if _, err := io.ReadAll(r); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func isAllowedExtraFile(relPath string, allowedExtraFiles []string) bool {
+	for _, pattern := range allowedExtraFiles {
+		if matched, err := path.Match(pattern, relPath); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func getOwner(fi os.FileInfo) (*[2]uint32, bool) {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return &[2]uint32{st.Uid, st.Gid}, true
+	}
+	return nil, false
+}
+
+func getUnixMode(fi os.FileInfo) int64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return int64(st.Mode & 0o7777)
+	}
+	return int64(fi.Mode().Perm())
+}
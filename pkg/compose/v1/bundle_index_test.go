@@ -0,0 +1,226 @@
+package v1
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/pkg/archive"
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func installTestBundle(t *testing.T) (string, *AppBundleIndex) {
+	files := []struct {
+		hdr     tar.Header
+		content string
+	}{
+		{hdr: tar.Header{Name: "docker-compose.yml", Typeflag: tar.TypeReg, Mode: 0o644}, content: "services: {}\n"},
+		{hdr: tar.Header{Name: "conf/", Typeflag: tar.TypeDir, Mode: 0o750}},
+		{hdr: tar.Header{Name: "conf/app.conf", Typeflag: tar.TypeReg, Mode: 0o600}, content: "key=value\n"},
+		{hdr: tar.Header{Name: "app.conf", Typeflag: tar.TypeSymlink, Mode: 0o777, Linkname: "conf/app.conf"}},
+	}
+	index := NewAppBundleIndex()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		hdr := f.hdr
+		hdr.Size = int64(len(f.content))
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+		entry := &AppBundleIndexEntry{Mode: hdr.Mode}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			entry.Type = BundleEntryTypeFile
+			entry.Size = hdr.Size
+			entry.Digest = digest.FromString(f.content)
+		case tar.TypeDir:
+			entry.Type = BundleEntryTypeDir
+		case tar.TypeSymlink:
+			entry.Type = BundleEntryTypeSymlink
+			entry.Target = hdr.Linkname
+		}
+		index.Files[filepath.Clean(hdr.Name)] = entry
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := archive.Untar(&buf, dir, &archive.TarOptions{NoLchown: true}); err != nil {
+		t.Fatal(err)
+	}
+	return dir, index
+}
+
+func TestAppBundleIndexCheck(t *testing.T) {
+	t.Run("intact bundle", func(t *testing.T) {
+		dir, index := installTestBundle(t)
+		errs, err := index.Check(dir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) > 0 {
+			t.Errorf("unexpected errors: %v", errs)
+		}
+	})
+
+	tamper := map[string]struct {
+		fn       func(dir string) error
+		expected string
+	}{
+		"modified content": {
+			fn: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "conf/app.conf"), []byte("key=evil\n"), 0o600)
+			},
+			expected: "conf/app.conf",
+		},
+		"changed mode": {
+			fn: func(dir string) error {
+				return os.Chmod(filepath.Join(dir, "docker-compose.yml"), 0o666)
+			},
+			expected: "docker-compose.yml",
+		},
+		"changed symlink target": {
+			fn: func(dir string) error {
+				p := filepath.Join(dir, "app.conf")
+				if err := os.Remove(p); err != nil {
+					return err
+				}
+				return os.Symlink("/etc/passwd", p)
+			},
+			expected: "app.conf",
+		},
+		"file replaced with symlink": {
+			fn: func(dir string) error {
+				p := filepath.Join(dir, "conf/app.conf")
+				if err := os.Remove(p); err != nil {
+					return err
+				}
+				return os.Symlink("/etc/passwd", p)
+			},
+			expected: "conf/app.conf",
+		},
+		"extra file": {
+			fn: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "conf/extra.conf"), []byte("extra"), 0o644)
+			},
+			expected: "conf/extra.conf",
+		},
+		"extra directory": {
+			fn: func(dir string) error {
+				return os.Mkdir(filepath.Join(dir, "extra"), 0o755)
+			},
+			expected: "extra",
+		},
+	}
+	for name, tc := range tamper {
+		t.Run(name, func(t *testing.T) {
+			dir, index := installTestBundle(t)
+			if err := tc.fn(dir); err != nil {
+				t.Fatal(err)
+			}
+			errs, err := index.Check(dir, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := errs[tc.expected]; !ok || len(errs) != 1 {
+				t.Errorf("expected error for %s only, got: %v", tc.expected, errs)
+			}
+		})
+	}
+
+	t.Run("allowed extra file", func(t *testing.T) {
+		dir, index := installTestBundle(t)
+		if err := os.WriteFile(filepath.Join(dir, "docker-compose.override.yml"), []byte("services: {}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		errs, err := index.Check(dir, []string{"docker-compose.override.yml"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) > 0 {
+			t.Errorf("unexpected errors: %v", errs)
+		}
+	})
+
+	t.Run("legacy index ignores extra files", func(t *testing.T) {
+		dir, index := installTestBundle(t)
+		legacy := &AppBundleIndex{Version: AppBundleIndexV1, Files: map[string]*AppBundleIndexEntry{}}
+		for filePath, entry := range index.Files {
+			if entry.Type == BundleEntryTypeFile {
+				legacy.Files[filePath] = &AppBundleIndexEntry{Type: BundleEntryTypeFile, Digest: entry.Digest}
+			}
+		}
+		// The app data written through a relative bind mount
+		if err := os.MkdirAll(filepath.Join(dir, "data"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "data", "db.sqlite"), []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		errs, err := legacy.Check(dir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) > 0 {
+			t.Errorf("unexpected errors: %v", errs)
+		}
+	})
+}
+
+func TestParseAppBundleIndex(t *testing.T) {
+	legacy := []byte(`{"docker-compose.yml":"` + digest.FromString("foo").String() + `"}`)
+	index, err := ParseAppBundleIndex(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Version != AppBundleIndexV1 {
+		t.Errorf("expected legacy index version, got: %s", index.Version)
+	}
+	if entry, ok := index.Files["docker-compose.yml"]; !ok || entry.Digest != digest.FromString("foo") {
+		t.Errorf("unexpected legacy index entry: %+v", entry)
+	}
+
+	current := []byte(`{"version":"v2","files":{"conf":{"type":"dir","mode":493}}}`)
+	index, err = ParseAppBundleIndex(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Version != AppBundleIndexV2 || index.Files["conf"].Type != BundleEntryTypeDir || index.Files["conf"].Mode != 0o755 {
+		t.Errorf("unexpected index: %+v", index)
+	}
+}
+
+func TestGetAppIndexNodeIfPresent(t *testing.T) {
+	appRef, err := compose.ParseAppRef("hub.foundries.io/factory/app@" + digest.FromString("app").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyDigest := digest.FromString("legacy")
+	bundleDesc := &ocispec.Descriptor{Annotations: map[string]string{
+		AnnotationKeyAppBundleIndexDigest: legacyDigest.String(),
+		AnnotationKeyAppBundleIndexSize:   "10",
+	}}
+	if node := getAppIndexNodeIfPresent(appRef, bundleDesc); node == nil || node.Descriptor.Digest != legacyDigest {
+		t.Errorf("expected the legacy index node, got: %+v", node)
+	}
+
+	v2Digest := digest.FromString("v2")
+	bundleDesc.Annotations[AnnotationKeyAppBundleIndexV2Digest] = v2Digest.String()
+	bundleDesc.Annotations[AnnotationKeyAppBundleIndexV2Size] = "20"
+	if node := getAppIndexNodeIfPresent(appRef, bundleDesc); node == nil || node.Descriptor.Digest != v2Digest ||
+		node.Descriptor.Size != 20 || node.Type != compose.BlobTypeAppIndex {
+		t.Errorf("expected the v2 index node, got: %+v", node)
+	}
+
+	if node := getAppIndexNodeIfPresent(appRef, &ocispec.Descriptor{}); node != nil {
+		t.Errorf("expected no index node, got: %+v", node)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/containerd/containerd/platforms"
@@ -18,11 +19,14 @@ type (
 	ConfigOpts struct {
 		StoreRoot      string
 		ComposeRoot    string
+		LocalRoot      string
 		ConnectTimeout time.Duration
 		ReadTimeout    time.Duration
 		SkopeoSupport  bool
 		UpdateDBPath   string
 		Proxy          compose.ProxyProvider
+		// Files allowed to be in app project directories in addition to the app bundle files
+		AllowedBundleExtraFiles []string
 	}
 	ConfigOpt func(*ConfigOpts)
 )
@@ -31,6 +35,7 @@ const (
 	DefaultRootDir        = ".composeapps"
 	DefaultStoreDir       = "store"
 	DefaultComposeDir     = "projects"
+	DefaultLocalDir       = "local"
 	DefaultConnectTimeout = time.Duration(120) * time.Second
 	DefaultReadTimeout    = time.Duration(900) * time.Second
 	DefaultDBFileName     = "updates.db"
@@ -60,6 +65,12 @@ func WithComposeRoot(composeRoot string) ConfigOpt {
 	}
 }
 
+func WithLocalRoot(localRoot string) ConfigOpt {
+	return func(opts *ConfigOpts) {
+		opts.LocalRoot = localRoot
+	}
+}
+
 func WithConnectTimeout(timeout time.Duration) ConfigOpt {
 	return func(opts *ConfigOpts) {
 		opts.ConnectTimeout = timeout
@@ -72,6 +83,12 @@ func WithProxy(proxy compose.ProxyProvider) ConfigOpt {
 	}
 }
 
+func WithAllowedBundleExtraFiles(patterns ...string) ConfigOpt {
+	return func(opts *ConfigOpts) {
+		opts.AllowedBundleExtraFiles = patterns
+	}
+}
+
 func NewDefaultConfig(options ...ConfigOpt) (*compose.Config, error) {
 	opts := &ConfigOpts{
 		ConnectTimeout: DefaultConnectTimeout,
@@ -83,7 +100,7 @@ func NewDefaultConfig(options ...ConfigOpt) (*compose.Config, error) {
 
 	var err error
 	var homeDir string
-	if len(opts.StoreRoot) == 0 || len(opts.ComposeRoot) == 0 {
+	if len(opts.StoreRoot) == 0 || len(opts.ComposeRoot) == 0 || len(opts.LocalRoot) == 0 {
 		homeDir, err = os.UserHomeDir()
 		if err != nil {
 			// TODO: print log
@@ -100,6 +117,9 @@ func NewDefaultConfig(options ...ConfigOpt) (*compose.Config, error) {
 	if len(opts.ComposeRoot) == 0 {
 		opts.ComposeRoot = path.Join(homeDir, DefaultRootDir, DefaultComposeDir)
 	}
+	if len(opts.LocalRoot) == 0 {
+		opts.LocalRoot = path.Join(homeDir, DefaultRootDir, DefaultLocalDir)
+	}
 	if len(opts.UpdateDBPath) == 0 {
 		opts.UpdateDBPath = path.Join(opts.StoreRoot, DefaultDBFileName)
 	}
@@ -135,23 +155,65 @@ func NewDefaultConfig(options ...ConfigOpt) (*compose.Config, error) {
 		return nil, fmt.Errorf("failed to load docker configuration: %s", err.Error())
 	}
 
+	// Override or set the allowed bundle extra files if specified via environment variable
+	allowedExtraFiles := opts.AllowedBundleExtraFiles
+	if extraFilesFromEnv, err := getAllowedBundleExtraFilesFromEnvIfSet(); err != nil {
+		return nil, err
+	} else if extraFilesFromEnv != nil {
+		allowedExtraFiles = extraFilesFromEnv
+	}
+
 	platform := platforms.DefaultSpec()
 
-	return &compose.Config{
+	cfg := &compose.Config{
 		StoreRoot:      opts.StoreRoot,
 		ComposeRoot:    opts.ComposeRoot,
+		LocalRoot:      opts.LocalRoot,
 		DockerCfg:      dockerCfg,
 		Platform:       platform,
 		ConnectTimeout: opts.ConnectTimeout,
 		ReadTimeout:    opts.ReadTimeout,
-		AppLoader:      NewAppLoader(),
 		AppStoreFactoryFunc: func(c *compose.Config) (compose.AppStore, error) {
 			return NewAppStore(c.StoreRoot, c.Platform, opts.SkopeoSupport)
 		},
-		BlockSize:  s.BlockSize,
-		DBFilePath: opts.UpdateDBPath,
-		Proxy:      proxy,
-	}, nil
+		BlockSize:               s.BlockSize,
+		DBFilePath:              opts.UpdateDBPath,
+		Proxy:                   proxy,
+		AllowedBundleExtraFiles: allowedExtraFiles,
+	}
+	// Compose projects are loaded according to the device variables and app settings, their location is defined
+	// by the config, which can be altered after its creation
+	cfg.AppLoader = NewAppLoader(WithAppEnvProvider(func() (map[string]string, error) {
+		return compose.LoadDeviceVars(cfg)
+	}), WithAppProfilesProvider(func(appName string) ([]string, error) {
+		settings, err := compose.LoadAppSettings(cfg, appName)
+		if err != nil {
+			return nil, err
+		}
+		return settings.Profiles, nil
+	}))
+	return cfg, nil
+}
+
+// getAllowedBundleExtraFilesFromEnvIfSet returns the comma-separated patterns of the files allowed in app
+// project directories in addition to the app bundle files, e.g. "data/*,docker-compose.override.yml".
+func getAllowedBundleExtraFilesFromEnvIfSet() ([]string, error) {
+	extraFilesEnv := os.Getenv("COMPOSE_APPS_ALLOWED_BUNDLE_EXTRA_FILES")
+	if len(extraFilesEnv) == 0 {
+		return nil, nil
+	}
+	var patterns []string
+	for _, pattern := range strings.Split(extraFilesEnv, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if len(pattern) == 0 {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid COMPOSE_APPS_ALLOWED_BUNDLE_EXTRA_FILES pattern: %s: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
 }
 
 func getProxyProviderFromEnvIfSet() (compose.ProxyProvider, error) {
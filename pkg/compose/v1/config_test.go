@@ -0,0 +1,27 @@
+package v1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetAllowedBundleExtraFilesFromEnv(t *testing.T) {
+	t.Setenv("COMPOSE_APPS_ALLOWED_BUNDLE_EXTRA_FILES", "")
+	if patterns, err := getAllowedBundleExtraFilesFromEnvIfSet(); err != nil || patterns != nil {
+		t.Errorf("expected no patterns, got %v, error: %v", patterns, err)
+	}
+
+	t.Setenv("COMPOSE_APPS_ALLOWED_BUNDLE_EXTRA_FILES", "data, docker-compose.override.yml,,")
+	patterns, err := getAllowedBundleExtraFilesFromEnvIfSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"data", "docker-compose.override.yml"}; !reflect.DeepEqual(patterns, expected) {
+		t.Errorf("expected patterns %v, got %v", expected, patterns)
+	}
+
+	t.Setenv("COMPOSE_APPS_ALLOWED_BUNDLE_EXTRA_FILES", "data/[")
+	if _, err := getAllowedBundleExtraFilesFromEnvIfSet(); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,43 +22,157 @@ import (
 	"hash"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/compose-spec/compose-go/dotenv"
 	"github.com/compose-spec/compose-go/loader"
+	"github.com/compose-spec/compose-go/template"
 	"github.com/compose-spec/compose-go/types"
 	"github.com/foundriesio/composeapp/internal"
 	"github.com/moby/patternmatcher/ignorefile"
 	"github.com/opencontainers/go-digest"
 )
 
+type (
+	// composeFile is a file of a compose project being published
+	composeFile struct {
+		// path to the file on the local file system
+		path string
+		// path to the file in the app bundle
+		bundlePath string
+		content    map[string]interface{}
+	}
+
+	PublishOptions struct {
+		// Policy the app is checked against before publishing, the app is not checked if it is nil
+		Policy *compose.Policy
+		// Names of the apps the published app depends on
+		DependsOn []string
+	}
+	PublishOption func(*PublishOptions)
+)
+
+// WithPolicy makes DoPublish check the app against the given policy before publishing it.
+func WithPolicy(policy *compose.Policy) PublishOption {
+	return func(o *PublishOptions) {
+		o.Policy = policy
+	}
+}
+
+// WithDependsOn makes DoPublish annotate the app manifest with names of the apps the app depends on.
+func WithDependsOn(appNames ...string) PublishOption {
+	return func(o *PublishOptions) {
+		o.DependsOn = appNames
+	}
+}
+
 func loadProj(ctx context.Context, appName string, file string, content []byte) (*types.Project, error) {
-	env := make(map[string]string)
-	for _, val := range os.Environ() {
-		parts := strings.Split(val, "=")
-		env[parts[0]] = parts[1]
+	env, err := getProjEnv(path.Dir(file))
+	if err != nil {
+		return nil, err
 	}
 
 	var files []types.ConfigFile
 	files = append(files, types.ConfigFile{Filename: file, Content: content})
 	return loader.LoadWithContext(ctx, types.ConfigDetails{
-		WorkingDir:  ".",
+		WorkingDir:  path.Dir(file),
 		ConfigFiles: files,
 		Environment: env,
 	}, func(options *loader.Options) {
 		options.SetProjectName(appName, true)
+		// Services of all profiles are published, a device selects the ones to run
+		options.Profiles = []string{"*"}
 	})
 }
 
+// getProjEnv returns the environment for the compose project interpolation, the variables defined by the project's
+// env file are overridden by the ones set in the shell environment, the same as `docker compose` does.
+func getProjEnv(projDir string) (map[string]string, error) {
+	env, err := dotenv.Read(path.Join(projDir, compose.AppEnvFile))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %s: %w", compose.AppEnvFile, err)
+		}
+		env = make(map[string]string)
+	}
+	for _, val := range os.Environ() {
+		parts := strings.SplitN(val, "=", 2)
+		env[parts[0]] = parts[1]
+	}
+	return env, nil
+}
+
+// getComposeFiles returns the main compose file and all the files it refers to via `include` and `extends`.
+// If the main file name is not one of the standard compose file names, then it is published as `docker-compose.yml`.
+func getComposeFiles(file string, appDir string) ([]*composeFile, error) {
+	filePaths, err := compose.GetComposeFiles(file, os.ReadFile)
+	if err != nil {
+		return nil, err
+	}
+	mainBundlePath := path.Base(file)
+	if !slices.Contains(compose.ComposeFileNames, mainBundlePath) {
+		mainBundlePath = "docker-compose.yml"
+	}
+	if len(filePaths) > 1 {
+		if mainDir, err := filepath.Abs(path.Dir(file)); err != nil {
+			return nil, err
+		} else if bundleDir, err := filepath.Abs(appDir); err != nil {
+			return nil, err
+		} else if mainDir != bundleDir {
+			return nil, fmt.Errorf("the main file of a multi-file compose project must be in the app directory: %s", file)
+		}
+	}
+
+	var files []*composeFile
+	for i, p := range filePaths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > AppComposeMaxSize {
+			return nil, fmt.Errorf("size of compose file %s exceeds the maximum allowed;"+
+				" max allowed: %d, size: %d", p, AppComposeMaxSize, len(b))
+		}
+		content, err := loader.ParseYAML(b)
+		if err != nil {
+			return nil, err
+		}
+		bundlePath := mainBundlePath
+		if i > 0 {
+			if bundlePath, err = filepath.Rel(appDir, p); err != nil {
+				return nil, err
+			}
+			bundlePath = filepath.ToSlash(bundlePath)
+		}
+		files = append(files, &composeFile{path: p, bundlePath: bundlePath, content: content})
+	}
+	return files, nil
+}
+
 func DoPublish(ctx context.Context, appName string, file, target, digestFile string, dryRun bool, archList []string,
-	pinnedImages map[string]digest.Digest, layersMetaFile string, createAppLayersManifest bool) error {
+	pinnedImages map[string]digest.Digest, layersMetaFile string, createAppLayersManifest bool, options ...PublishOption) error {
+	opts := PublishOptions{}
+	for _, o := range options {
+		o(&opts)
+	}
+	appDir := "./"
+	if len(file) == 0 {
+		var err error
+		if file, err = compose.FindComposeFileInDir(appDir); err != nil {
+			return err
+		}
+	}
 	b, err := os.ReadFile(file)
 	if err != nil {
 		return err
 	}
-	config, err := loader.ParseYAML(b)
+	composeFiles, err := getComposeFiles(file, appDir)
 	if err != nil {
 		return err
 	}
@@ -68,23 +181,52 @@ func DoPublish(ctx context.Context, appName string, file, target, digestFile str
 	if err != nil {
 		return err
 	}
+	env, err := getProjEnv(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	if opts.Policy != nil {
+		fmt.Println("= Checking policy...")
+		if err := opts.Policy.Apply(opts.Policy.Check(appName, proj)); err != nil {
+			return err
+		}
+	}
 
 	fmt.Println("= Pinning service images...")
-	svcs, ok := config["services"]
-	if !ok {
-		return errors.New("Unable to find 'services' section of composetypes file")
+	// Services can be defined in any of the compose files, so pin images in all of them
+	pinnedRefs := map[string]string{}
+	for _, f := range composeFiles {
+		svcs, ok := f.content["services"].(map[string]interface{})
+		if !ok {
+			if f == composeFiles[0] && len(composeFiles) == 1 {
+				return errors.New("Unable to find 'services' section of composetypes file")
+			}
+			continue
+		}
+		if err := pinServiceImages(ctx, svcs, env, pinnedImages, pinnedRefs); err != nil {
+			return err
+		}
 	}
-	if err := pinServiceImages(ctx, svcs.(map[string]interface{}), proj, pinnedImages); err != nil {
-		return err
+	svcImages := map[string]string{}
+	for _, s := range proj.Services {
+		if len(s.Image) == 0 {
+			return fmt.Errorf("Service(%s) missing 'image' attribute", s.Name)
+		}
+		pinned, ok := pinnedRefs[s.Image]
+		if !ok {
+			return fmt.Errorf("Service(%s) image is not pinned: %s", s.Name, s.Image)
+		}
+		svcImages[s.Name] = pinned
 	}
 
 	fmt.Println("== Hashing services...")
-	if err := pinServiceConfigs(svcs.(map[string]interface{}), proj); err != nil {
+	if err := pinServiceConfigs(composeFiles, proj); err != nil {
 		return err
 	}
 
 	fmt.Println("= Getting app layers metadata...")
-	appLayers, err := compose.GetLayers(ctx, svcs.(map[string]interface{}), archList)
+	appLayers, err := compose.GetAppLayersFromMap(ctx, svcImages, archList)
 	if err != nil {
 		return err
 	}
@@ -112,7 +254,7 @@ func DoPublish(ctx context.Context, appName string, file, target, digestFile str
 	}
 
 	fmt.Println("= Publishing app...")
-	dgst, err := createAndPublishApp(ctx, config, target, dryRun, layerManifests, appLayersMetaBytes)
+	dgst, err := createAndPublishApp(ctx, composeFiles, appDir, target, dryRun, layerManifests, appLayersMetaBytes, opts.DependsOn)
 	if err != nil {
 		return err
 	}
@@ -122,36 +264,25 @@ func DoPublish(ctx context.Context, appName string, file, target, digestFile str
 	return nil
 }
 
-func iterateServices(services map[string]interface{}, proj *types.Project, fn types.ServiceFunc) error {
-	return proj.WithServices(nil, func(s types.ServiceConfig) error {
-		obj := services[s.Name]
-		_, ok := obj.(map[string]interface{})
-		if !ok {
-			if s.Name == "extensions" {
-				fmt.Println("Hacking around https://github.com/compose-spec/compose-go/issues/91")
-				return nil
-			}
-			return fmt.Errorf("Service(%s) has invalid format", s.Name)
-		}
-		return fn(s)
-	})
-}
-
 func createAndPublishApp(ctx context.Context,
-	config map[string]interface{},
+	composeFiles []*composeFile,
+	appDir string,
 	target string, dryRun bool,
 	layerManifests []distribution.Descriptor,
-	appLayersMetaData []byte) (string, error) {
-	pinned, err := yaml.Marshal(config)
-	if err != nil {
-		return "", err
+	appLayersMetaData []byte,
+	dependsOn []string) (string, error) {
+	pinnedFiles := map[string][]byte{}
+	for _, f := range composeFiles {
+		pinned, err := yaml.Marshal(f.content)
+		if err != nil {
+			return "", err
+		}
+		pinnedHash := sha256.Sum256(pinned)
+		fmt.Printf("  |-> pinned content hash of %s: %x\n", f.bundlePath, pinnedHash)
+		pinnedFiles[f.bundlePath] = pinned
 	}
 
-	pinnedHash := sha256.Sum256(pinned)
-	fmt.Printf("  |-> pinned content hash: %x\n", pinnedHash)
-
-	var appContentHashes map[string]string
-	buff, appContentHashes, err := createTgz(pinned, "./")
+	buff, appIndex, err := createTgz(pinnedFiles, composeFiles[0].bundlePath, appDir)
 	if err != nil {
 		return "", err
 	}
@@ -175,8 +306,10 @@ func createAndPublishApp(ctx context.Context,
 	}
 
 	if dryRun {
-		fmt.Println("Pinned compose:")
-		fmt.Println(string(pinned))
+		for _, f := range composeFiles {
+			fmt.Printf("Pinned compose (%s):\n", f.bundlePath)
+			fmt.Println(string(pinnedFiles[f.bundlePath]))
+		}
 		fmt.Println("Skipping publishing for dryrun")
 
 		if err := os.WriteFile("/tmp/compose-bundle.tgz", buff, 0755); err != nil {
@@ -196,16 +329,27 @@ func createAndPublishApp(ctx context.Context,
 	desc.MediaType = AppLayerMediaType
 	fmt.Println("  |-> app blob: ", desc.Digest.String())
 
-	if os.Getenv("APP_BUNDLE_INDEX_OFF") != "1" && appContentHashes != nil && len(appContentHashes) > 0 {
-		if d, err := publishAppBundleIndexBlob(ctx, blobStore, appContentHashes); err == nil {
-			desc.Annotations = map[string]string{
-				AnnotationKeyAppBundleIndexDigest: d.Digest.String(),
-				AnnotationKeyAppBundleIndexSize:   strconv.Itoa(int(d.Size)),
-			}
+	if os.Getenv("APP_BUNDLE_INDEX_OFF") != "1" && appIndex != nil && len(appIndex.Files) > 0 {
+		// Devices running composectl that does not support the v2 index fail to parse it, so the legacy index
+		// is published under the original annotations, and the v2 index is published along with it.
+		desc.Annotations = map[string]string{}
+		if d, err := publishAppBundleIndexBlob(ctx, blobStore, appIndex.Legacy()); err == nil {
+			desc.Annotations[AnnotationKeyAppBundleIndexDigest] = d.Digest.String()
+			desc.Annotations[AnnotationKeyAppBundleIndexSize] = strconv.Itoa(int(d.Size))
 			fmt.Println("  |-> app index: ", d.Digest.String())
 		} else {
 			fmt.Println("  |-> failed to publish app index blob: ", err.Error())
 		}
+		if d, err := publishAppBundleIndexBlob(ctx, blobStore, appIndex); err == nil {
+			desc.Annotations[AnnotationKeyAppBundleIndexV2Digest] = d.Digest.String()
+			desc.Annotations[AnnotationKeyAppBundleIndexV2Size] = strconv.Itoa(int(d.Size))
+			fmt.Println("  |-> app index v2: ", d.Digest.String())
+		} else {
+			fmt.Println("  |-> failed to publish app index v2 blob: ", err.Error())
+		}
+		if len(desc.Annotations) == 0 {
+			desc.Annotations = nil
+		}
 	}
 
 	mb := internal.NewManifestBuilder(blobStore)
@@ -228,6 +372,10 @@ func createAndPublishApp(ctx context.Context,
 	if layerManifests != nil {
 		mb.(*internal.ManifestBuilder).SetLayerMetaManifests(layerManifests)
 	}
+	if len(dependsOn) > 0 {
+		mb.(*internal.ManifestBuilder).SetAnnotation(compose.AppDependsOnAnnotationKey, strings.Join(dependsOn, ","))
+		fmt.Println("  |-> depends on: ", strings.Join(dependsOn, ", "))
+	}
 
 	manifest, err := mb.Build(ctx)
 	if err != nil {
@@ -263,10 +411,13 @@ func createAndPublishApp(ctx context.Context,
 	return digest.String(), err
 }
 
-func createTgz(composeContent []byte, appDir string) ([]byte, map[string]string, error) {
-	if len(composeContent) > AppComposeMaxSize {
-		return nil, nil, fmt.Errorf("size of app compose file exceeds the maximum allowed;"+
-			" max allowed: %d, size: %d", AppComposeMaxSize, len(composeContent))
+// createTgz creates the app bundle archive of the app directory, with the compose files replaced by the pinned ones
+func createTgz(composeFiles map[string][]byte, mainComposeFile string, appDir string) ([]byte, *AppBundleIndex, error) {
+	for name, composeContent := range composeFiles {
+		if len(composeContent) > AppComposeMaxSize {
+			return nil, nil, fmt.Errorf("size of app compose file exceeds the maximum allowed;"+
+				" file: %s, max allowed: %d, size: %d", name, AppComposeMaxSize, len(composeContent))
+		}
 	}
 	reader, err := archive.TarWithOptions(appDir, &archive.TarOptions{
 		Compression:     archive.Uncompressed,
@@ -276,9 +427,9 @@ func createTgz(composeContent []byte, appDir string) ([]byte, map[string]string,
 		return nil, nil, err
 	}
 
-	composeFound := false
+	composeFound := map[string]bool{}
 	var buf bytes.Buffer
-	appContentHashes := make(map[string]string)
+	appIndex := NewAppBundleIndex()
 	gzw := gzip.NewWriter(&buf)
 	tw := tar.NewWriter(gzw)
 	tr := tar.NewReader(reader)
@@ -301,27 +452,31 @@ func createTgz(composeContent []byte, appDir string) ([]byte, map[string]string,
 		if bundleSize > AppBundleMaxSize {
 			return nil, nil, fmt.Errorf("app bundle size exceeds the maximum allowed: %d", AppBundleMaxSize)
 		}
-		if hdr.Name == "docker-compose.yml" {
-			composeFound = true
+		entryPath := path.Clean(hdr.Name)
+		entry := &AppBundleIndexEntry{Mode: hdr.Mode & 0o7777}
+		if composeContent, ok := composeFiles[entryPath]; ok && hdr.Typeflag == tar.TypeReg {
+			composeFound[entryPath] = true
 			hdr.Size = int64(len(composeContent))
 			if err := tw.WriteHeader(hdr); err != nil {
-				return nil, nil, fmt.Errorf("Unable to add docker-compose.yml header archive: %s", err)
+				return nil, nil, fmt.Errorf("Unable to add %s header archive: %s", hdr.Name, err)
 			}
 			if _, err := tw.Write(composeContent); err != nil {
-				return nil, nil, fmt.Errorf("Unable to add docker-compose.yml to archive: %s", err)
+				return nil, nil, fmt.Errorf("Unable to add %s to archive: %s", hdr.Name, err)
 			}
 			h := sha256.Sum256(composeContent)
-			appContentHashes[hdr.Name] = "sha256:" + hex.EncodeToString(h[:])
+			entry.Type = BundleEntryTypeFile
+			entry.Size = hdr.Size
+			entry.Digest = digest.NewDigestFromBytes(digest.SHA256, h[:])
 		} else {
 			if err := tw.WriteHeader(hdr); err != nil {
 				return nil, nil, fmt.Errorf("Unable to add %s header archive: %s", hdr.Name, err)
 			}
 			var r io.Reader = tr
 			var h hash.Hash
-			if !hdr.FileInfo().IsDir() {
+			if hdr.Typeflag == tar.TypeReg {
 				if hdr.Size > AppBundleFileMaxSize {
 					return nil, nil, fmt.Errorf("size of app bundle file exceeds the maximum allowed;"+
-						" file: %s, max allowed: %d, size: %d", hdr.Name, AppBundleFileMaxSize, len(composeContent))
+						" file: %s, max allowed: %d, size: %d", hdr.Name, AppBundleFileMaxSize, hdr.Size)
 				}
 				h = sha256.New()
 				r = io.TeeReader(tr, h)
@@ -329,19 +484,51 @@ func createTgz(composeContent []byte, appDir string) ([]byte, map[string]string,
 			if _, err := io.Copy(tw, r); err != nil {
 				return nil, nil, fmt.Errorf("Unable to add %s archive: %s", hdr.Name, err)
 			}
-			if h != nil {
-				appContentHashes[hdr.Name] = "sha256:" + hex.EncodeToString(h.Sum(nil))
+			switch hdr.Typeflag {
+			case tar.TypeReg:
+				entry.Type = BundleEntryTypeFile
+				entry.Size = hdr.Size
+				entry.Digest = digest.NewDigestFromBytes(digest.SHA256, h.Sum(nil))
+			case tar.TypeLink:
+				// A hard link to the file added to the archive before
+				target, ok := appIndex.Files[path.Clean(hdr.Linkname)]
+				if !ok {
+					return nil, nil, fmt.Errorf("hard link target is not found in the app bundle: %s -> %s",
+						hdr.Name, hdr.Linkname)
+				}
+				entry.Type = BundleEntryTypeFile
+				entry.Size = target.Size
+				entry.Digest = target.Digest
+				entry.Mode = target.Mode
+			case tar.TypeDir:
+				entry.Type = BundleEntryTypeDir
+			case tar.TypeSymlink:
+				entry.Type = BundleEntryTypeSymlink
+				entry.Target = hdr.Linkname
+			default:
+				return nil, nil, fmt.Errorf("unsupported type of app bundle file: %s, type: %c", hdr.Name, hdr.Typeflag)
 			}
 		}
+		appIndex.Files[entryPath] = entry
 	}
 
-	if !composeFound {
-		return nil, nil, errors.New("A .composeappignores rule is discarding docker-compose.yml")
+	for name := range composeFiles {
+		if !composeFound[name] {
+			return nil, nil, fmt.Errorf("A .composeappignores rule is discarding %s", name)
+		}
+	}
+	// Make sure that the device picks up the same compose file as the main one
+	if name, _ := compose.FindComposeFile(func(fileName string) bool {
+		_, ok := appIndex.Files[fileName]
+		return ok
+	}); name != mainComposeFile {
+		return nil, nil, fmt.Errorf("the app bundle contains %s which takes precedence over the main compose file %s",
+			name, mainComposeFile)
 	}
 
 	tw.Close()
 	gzw.Close()
-	return buf.Bytes(), appContentHashes, nil
+	return buf.Bytes(), appIndex, nil
 }
 
 func getIgnores(appDir string) []string {
@@ -359,99 +546,156 @@ func getIgnores(appDir string) []string {
 
 func pinServiceImages(ctx context.Context,
 	services map[string]interface{},
-	proj *types.Project,
-	pinnedImages map[string]digest.Digest) error {
+	env map[string]string,
+	pinnedImages map[string]digest.Digest,
+	pinnedRefs map[string]string) error {
 	regc := internal.NewRegistryClient()
 
-	return iterateServices(services, proj, func(s types.ServiceConfig) error {
-		name := s.Name
-		obj := services[name]
-		svc := obj.(map[string]interface{})
-
-		image := s.Image
-		if len(image) == 0 {
+	var names []string
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		svc, ok := services[name].(map[string]interface{})
+		if !ok {
+			if name == "extensions" {
+				fmt.Println("Hacking around https://github.com/compose-spec/compose-go/issues/91")
+				continue
+			}
+			return fmt.Errorf("Service(%s) has invalid format", name)
+		}
+		imageValue, ok := svc["image"].(string)
+		if !ok {
+			// The image can be inherited from the extended service
+			if _, ok := svc["extends"]; ok {
+				continue
+			}
 			return fmt.Errorf("Service(%s) missing 'image' attribute", name)
 		}
-		if s.Build != nil {
+		image, err := template.Substitute(imageValue, func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		})
+		if err != nil {
+			return fmt.Errorf("Service(%s) invalid image %s: %s", name, imageValue, err)
+		}
+		if _, ok := svc["build"]; ok {
 			fmt.Printf("Removing service(%s) 'build' stanza\n", name)
 			delete(svc, "build")
 		}
 
-		fmt.Printf("Pinning %s(%s)\n", name, image)
-		named, err := reference.ParseNormalizedNamed(image)
-		if err != nil {
-			return err
+		pinned, ok := pinnedRefs[image]
+		if !ok {
+			fmt.Printf("Pinning %s(%s)\n", name, image)
+			if pinned, err = pinImage(ctx, regc, image, pinnedImages); err != nil {
+				return err
+			}
+			pinnedRefs[image] = pinned
+			pinnedRefs[pinned] = pinned
 		}
+		svc["image"] = pinned
+	}
+	return nil
+}
 
-		repo, err := regc.GetRepository(ctx, named)
-		if err != nil {
-			return err
-		}
+func pinImage(ctx context.Context, regc internal.RegistryClient, image string, pinnedImages map[string]digest.Digest) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
 
-		var digest digest.Digest
-		switch v := named.(type) {
-		case reference.Tagged:
-			tag := v.Tag()
-			desc, err := repo.Tags(ctx).Get(ctx, tag)
-			if err != nil {
-				return fmt.Errorf("Unable to find image reference(%s): %s", image, err)
-			}
-			digest = desc.Digest
-		case reference.Digested:
-			digest = v.Digest()
-		default:
-			var ok bool
-			if digest, ok = pinnedImages[named.Name()]; !ok {
-				return fmt.Errorf("Invalid reference type for %s: %T. Images must be pinned to a `:<tag>` or `@sha256:<hash>`", named, named)
-			}
-		}
+	repo, err := regc.GetRepository(ctx, named)
+	if err != nil {
+		return "", err
+	}
 
-		mansvc, err := repo.Manifests(ctx, nil)
+	var digest digest.Digest
+	switch v := named.(type) {
+	case reference.Tagged:
+		tag := v.Tag()
+		desc, err := repo.Tags(ctx).Get(ctx, tag)
 		if err != nil {
-			return fmt.Errorf("Unable to get image manifests(%s): %s", image, err)
+			return "", fmt.Errorf("Unable to find image reference(%s): %s", image, err)
 		}
-		man, err := mansvc.Get(ctx, digest)
-		if err != nil {
-			return fmt.Errorf("Unable to find image manifest(%s): %s", image, err)
+		digest = desc.Digest
+	case reference.Digested:
+		digest = v.Digest()
+	default:
+		var ok bool
+		if digest, ok = pinnedImages[named.Name()]; !ok {
+			return "", fmt.Errorf("Invalid reference type for %s: %T. Images must be pinned to a `:<tag>` or `@sha256:<hash>`", named, named)
 		}
+	}
 
-		// TODO - we should find the intersection of platforms so
-		// that we can denote the platforms this app can run on
-		pinned := reference.Domain(named) + "/" + reference.Path(named) + "@" + digest.String()
+	mansvc, err := repo.Manifests(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("Unable to get image manifests(%s): %s", image, err)
+	}
+	man, err := mansvc.Get(ctx, digest)
+	if err != nil {
+		return "", fmt.Errorf("Unable to find image manifest(%s): %s", image, err)
+	}
 
-		switch mani := man.(type) {
-		case *manifestlist.DeserializedManifestList:
-			fmt.Printf("  | ")
-			for i, m := range mani.Manifests {
-				if i != 0 {
-					fmt.Printf(", ")
-				}
-				fmt.Printf(m.Platform.Architecture)
-				if m.Platform.Architecture == "arm" {
-					fmt.Printf(m.Platform.Variant)
-				}
+	// TODO - we should find the intersection of platforms so
+	// that we can denote the platforms this app can run on
+	pinned := reference.Domain(named) + "/" + reference.Path(named) + "@" + digest.String()
+
+	switch mani := man.(type) {
+	case *manifestlist.DeserializedManifestList:
+		fmt.Printf("  | ")
+		for i, m := range mani.Manifests {
+			if i != 0 {
+				fmt.Printf(", ")
+			}
+			fmt.Printf(m.Platform.Architecture)
+			if m.Platform.Architecture == "arm" {
+				fmt.Printf(m.Platform.Variant)
 			}
-		case *schema2.DeserializedManifest:
-			break
-		default:
-			return fmt.Errorf("Unexpected manifest: %v", mani)
 		}
+	case *schema2.DeserializedManifest:
+		break
+	default:
+		return "", fmt.Errorf("Unexpected manifest: %v", mani)
+	}
 
-		fmt.Println("\n  |-> ", pinned)
-		svc["image"] = pinned
-		return nil
-	})
+	fmt.Println("\n  |-> ", pinned)
+	return pinned, nil
 }
 
-func pinServiceConfigs(services map[string]interface{}, proj *types.Project) error {
-	return iterateServices(services, proj, func(s types.ServiceConfig) error {
-		obj := services[s.Name]
-		svc := obj.(map[string]interface{})
+// pinServiceConfigs sets the config hash label of each project service in the compose file that defines the service
+func pinServiceConfigs(composeFiles []*composeFile, proj *types.Project) error {
+	return proj.WithServices(nil, func(s types.ServiceConfig) error {
+		var svc map[string]interface{}
+		var svcFile *composeFile
+		for _, f := range composeFiles {
+			if services, ok := f.content["services"].(map[string]interface{}); ok {
+				if svc, ok = services[s.Name].(map[string]interface{}); ok {
+					svcFile = f
+					break
+				}
+			}
+		}
+		if svc == nil {
+			if s.Name == "extensions" {
+				fmt.Println("Hacking around https://github.com/compose-spec/compose-go/issues/91")
+				return nil
+			}
+			return fmt.Errorf("Service(%s) has invalid format", s.Name)
+		}
 
 		marshalled, err := yaml.Marshal(svc)
 		if err != nil {
 			return err
 		}
+		// The service definition is partial if it extends another service, so hash the extended services too
+		for base, baseFile := getExtendedService(svc, svcFile, composeFiles); base != nil; base, baseFile = getExtendedService(base, baseFile, composeFiles) {
+			b, err := yaml.Marshal(base)
+			if err != nil {
+				return err
+			}
+			marshalled = append(marshalled, b...)
+		}
 
 		srvh := sha256.Sum256(marshalled)
 		fmt.Printf("   |-> %s : %x\n", s.Name, srvh)
@@ -464,12 +708,41 @@ func pinServiceConfigs(services map[string]interface{}, proj *types.Project) err
 	})
 }
 
+// getExtendedService returns the service extended by the given service and the compose file defining it
+func getExtendedService(svc map[string]interface{}, svcFile *composeFile, composeFiles []*composeFile) (map[string]interface{}, *composeFile) {
+	extends, ok := svc["extends"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	name, _ := extends["service"].(string)
+	baseFile := svcFile
+	if file, ok := extends["file"].(string); ok && len(file) > 0 {
+		baseFile = nil
+		basePath := path.Join(path.Dir(svcFile.path), file)
+		for _, f := range composeFiles {
+			if path.Clean(f.path) == basePath {
+				baseFile = f
+				break
+			}
+		}
+		if baseFile == nil {
+			return nil, nil
+		}
+	}
+	services, _ := baseFile.content["services"].(map[string]interface{})
+	base, _ := services[name].(map[string]interface{})
+	if base == nil || (baseFile == svcFile && name == "") {
+		return nil, nil
+	}
+	return base, baseFile
+}
+
 func publishAppBundleIndexBlob(
 	ctx context.Context,
 	blobStore distribution.BlobStore,
-	appContentHashes map[string]string) (desc distribution.Descriptor, err error) {
+	appIndex interface{}) (desc distribution.Descriptor, err error) {
 	var b []byte
-	if b, err = json.Marshal(appContentHashes); err == nil {
+	if b, err = json.Marshal(appIndex); err == nil {
 		desc, err = blobStore.Put(ctx, "application/json", b)
 	}
 	return
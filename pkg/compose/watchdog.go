@@ -0,0 +1,378 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/opencontainers/go-digest"
+)
+
+type (
+	WatchdogEventType string
+
+	WatchdogEvent struct {
+		Type     WatchdogEventType
+		App      App
+		Service  *Service
+		Failures int
+		Err      error
+	}
+
+	// WatchdogEventHandler is invoked for each remediation action taken by the watchdog
+	WatchdogEventHandler func(event *WatchdogEvent)
+	// WatchdogEscalationHandler is invoked after the app failed to recover `MaxFailures` times in a row,
+	// e.g. to roll back the app to its previous version.
+	WatchdogEscalationHandler func(ctx context.Context, app App) error
+
+	WatchdogOptions struct {
+		Interval          time.Duration
+		RestartUnhealthy  bool
+		RecreateMissing   bool
+		MaxFailures       int
+		StopOnEscalation  bool
+		EventHandler      WatchdogEventHandler
+		EscalationHandler WatchdogEscalationHandler
+	}
+	WatchdogOption func(*WatchdogOptions)
+)
+
+const (
+	WatchdogEventServiceRestarted   WatchdogEventType = "service-restarted"
+	WatchdogEventServiceRecreated   WatchdogEventType = "service-recreated"
+	WatchdogEventRemediationFailed  WatchdogEventType = "remediation-failed"
+	WatchdogEventAppRecovered       WatchdogEventType = "app-recovered"
+	WatchdogEventAppEscalated       WatchdogEventType = "app-escalated"
+	WatchdogEventAppStopped         WatchdogEventType = "app-stopped"
+	WatchdogEventEscalationFailed   WatchdogEventType = "escalation-failed"
+	WatchdogEventEscalationHandled  WatchdogEventType = "escalation-handled"
+	WatchdogEventStatusCheckFailure WatchdogEventType = "status-check-failed"
+	WatchdogEventAppCheckFailed     WatchdogEventType = "app-check-failed"
+
+	DefaultWatchdogInterval    = 30 * time.Second
+	DefaultWatchdogMaxFailures = 5
+)
+
+func WithWatchdogInterval(interval time.Duration) WatchdogOption {
+	return func(o *WatchdogOptions) {
+		o.Interval = interval
+	}
+}
+
+func WithUnhealthyServiceRestart(restart bool) WatchdogOption {
+	return func(o *WatchdogOptions) {
+		o.RestartUnhealthy = restart
+	}
+}
+
+func WithMissingServiceRecreation(recreate bool) WatchdogOption {
+	return func(o *WatchdogOptions) {
+		o.RecreateMissing = recreate
+	}
+}
+
+// WithEscalation sets the number of consecutive failed checks after which the watchdog gives up
+// remediating the app, and whether it should stop the app in this case.
+// Zero `maxFailures` disables the escalation.
+func WithEscalation(maxFailures int, stopApp bool) WatchdogOption {
+	return func(o *WatchdogOptions) {
+		o.MaxFailures = maxFailures
+		o.StopOnEscalation = stopApp
+	}
+}
+
+func WithWatchdogEventHandler(handler WatchdogEventHandler) WatchdogOption {
+	return func(o *WatchdogOptions) {
+		o.EventHandler = handler
+	}
+}
+
+func WithWatchdogEscalationHandler(handler WatchdogEscalationHandler) WatchdogOption {
+	return func(o *WatchdogOptions) {
+		o.EscalationHandler = handler
+	}
+}
+
+func (e *WatchdogEvent) String() string {
+	s := string(e.Type)
+	if e.App != nil {
+		s += fmt.Sprintf(": %s", e.App.Name())
+	}
+	if e.Service != nil {
+		s += fmt.Sprintf(", service: %s", e.Service.Name)
+	}
+	if e.Failures > 0 {
+		s += fmt.Sprintf(", failures: %d", e.Failures)
+	}
+	if e.Err != nil {
+		s += fmt.Sprintf(", error: %s", e.Err.Error())
+	}
+	return s
+}
+
+// RunWatchdog periodically evaluates the running status of the specified apps and remediates
+// the detected failures according to the given policy until the context is canceled.
+// If no app URIs are specified then the apps which compose projects are installed are watched.
+func RunWatchdog(ctx context.Context, cfg *Config, appURIs []string, options ...WatchdogOption) error {
+	opts := &WatchdogOptions{
+		Interval:         DefaultWatchdogInterval,
+		RestartUnhealthy: true,
+		RecreateMissing:  true,
+		MaxFailures:      DefaultWatchdogMaxFailures,
+		StopOnEscalation: true,
+	}
+	for _, o := range options {
+		o(opts)
+	}
+	if opts.Interval <= 0 {
+		return fmt.Errorf("invalid watchdog interval: %s", opts.Interval)
+	}
+
+	w := newWatchdog(cfg, opts)
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.check(ctx, appURIs); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.emit(&WatchdogEvent{Type: WatchdogEventStatusCheckFailure, Err: err})
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+type watchdog struct {
+	cfg  *Config
+	opts *WatchdogOptions
+	// number of consecutive failed checks per app
+	failures map[digest.Digest]int
+	// apps the watchdog gave up remediating
+	escalated map[digest.Digest]bool
+
+	// The docker and compose interactions, replaceable in tests
+	getStatus      func(ctx context.Context, appURIs []string) ([]App, *RunningStatus, error)
+	startApp       func(app App) error
+	stopApp        func(app App) error
+	restartService func(ctx context.Context, srv *Service) error
+}
+
+func newWatchdog(cfg *Config, opts *WatchdogOptions) *watchdog {
+	w := &watchdog{
+		cfg:       cfg,
+		opts:      opts,
+		failures:  map[digest.Digest]int{},
+		escalated: map[digest.Digest]bool{},
+		startApp: func(app App) error {
+			return startApp(cfg, app, false)
+		},
+		stopApp: func(app App) error {
+			return stopApp(cfg, app)
+		},
+		restartService: func(ctx context.Context, srv *Service) error {
+			cli, err := GetDockerClient(cfg.DockerHost)
+			if err != nil {
+				return err
+			}
+			defer cli.Close()
+			return cli.ContainerRestart(ctx, srv.CtrID, container.StopOptions{})
+		},
+	}
+	w.getStatus = func(ctx context.Context, appURIs []string) ([]App, *RunningStatus, error) {
+		apps, err := getWatchedApps(ctx, cfg, appURIs, w.emit)
+		if err != nil {
+			return nil, nil, err
+		}
+		runningStatus, err := CheckAppsRunningStatus(ctx, cfg, apps)
+		return apps, runningStatus, err
+	}
+	return w
+}
+
+func (w *watchdog) emit(event *WatchdogEvent) {
+	if w.opts.EventHandler != nil {
+		w.opts.EventHandler(event)
+	}
+}
+
+func (w *watchdog) check(ctx context.Context, appURIs []string) error {
+	apps, runningStatus, err := w.getStatus(ctx, appURIs)
+	if err != nil {
+		return err
+	}
+	for _, app := range apps {
+		appDigest := app.Ref().Digest
+		if w.escalated[appDigest] {
+			continue
+		}
+		report := runningStatus.AppsRunningStatus[appDigest]
+		if report.Disabled {
+			delete(w.failures, appDigest)
+			continue
+		}
+		failedServices := getFailedServices(report.Services)
+		if len(failedServices) == 0 {
+			if w.failures[appDigest] > 0 {
+				w.emit(&WatchdogEvent{Type: WatchdogEventAppRecovered, App: app})
+			}
+			delete(w.failures, appDigest)
+			continue
+		}
+
+		w.failures[appDigest]++
+		if w.opts.MaxFailures > 0 && w.failures[appDigest] >= w.opts.MaxFailures {
+			w.escalate(ctx, app)
+			continue
+		}
+		w.remediate(ctx, app, failedServices)
+	}
+	return nil
+}
+
+// getFailedServices returns the services which containers are missing or unhealthy.
+// A service which health check has not passed yet is still starting, so it is not considered failed.
+func getFailedServices(services []*Service) []*Service {
+	var failed []*Service
+	for _, srv := range services {
+		if srv.State == "not created" || (srv.Health != "healthy" && srv.Health != "starting") {
+			failed = append(failed, srv)
+		}
+	}
+	return failed
+}
+
+func (w *watchdog) remediate(ctx context.Context, app App, failedServices []*Service) {
+	var recreate bool
+	var unhealthy []*Service
+	for _, srv := range failedServices {
+		if srv.State == "not created" {
+			recreate = true
+		} else {
+			unhealthy = append(unhealthy, srv)
+		}
+	}
+	failures := w.failures[app.Ref().Digest]
+	if recreate && w.opts.RecreateMissing {
+		// `docker compose up` creates the missing containers and recreates containers
+		// which config does not match the app's one, so no need to restart unhealthy services separately.
+		if err := w.startApp(app); err != nil {
+			w.emit(&WatchdogEvent{Type: WatchdogEventRemediationFailed, App: app, Failures: failures, Err: err})
+		} else {
+			w.emit(&WatchdogEvent{Type: WatchdogEventServiceRecreated, App: app, Failures: failures})
+		}
+		return
+	}
+	if !w.opts.RestartUnhealthy || len(unhealthy) == 0 {
+		return
+	}
+	for _, srv := range unhealthy {
+		if err := w.restartService(ctx, srv); err != nil {
+			w.emit(&WatchdogEvent{Type: WatchdogEventRemediationFailed, App: app, Service: srv, Failures: failures, Err: err})
+		} else {
+			w.emit(&WatchdogEvent{Type: WatchdogEventServiceRestarted, App: app, Service: srv, Failures: failures})
+		}
+	}
+}
+
+func (w *watchdog) escalate(ctx context.Context, app App) {
+	appDigest := app.Ref().Digest
+	failures := w.failures[appDigest]
+	w.escalated[appDigest] = true
+	delete(w.failures, appDigest)
+	w.emit(&WatchdogEvent{Type: WatchdogEventAppEscalated, App: app, Failures: failures})
+
+	if w.opts.StopOnEscalation {
+		if err := w.stopApp(app); err != nil {
+			w.emit(&WatchdogEvent{Type: WatchdogEventEscalationFailed, App: app, Failures: failures, Err: err})
+		} else {
+			w.emit(&WatchdogEvent{Type: WatchdogEventAppStopped, App: app, Failures: failures})
+		}
+	}
+	if w.opts.EscalationHandler != nil {
+		if err := w.opts.EscalationHandler(ctx, app); err != nil {
+			w.emit(&WatchdogEvent{Type: WatchdogEventEscalationFailed, App: app, Failures: failures, Err: err})
+		} else {
+			w.emit(&WatchdogEvent{Type: WatchdogEventEscalationHandled, App: app, Failures: failures})
+		}
+	}
+}
+
+// getWatchedApps returns the specified apps, or if none is specified, all apps found in the store
+// which compose projects are installed. The installation of an app is checked only if several versions of
+// the app are in the store to find the installed one, the app is not watched if none of them is installed.
+func getWatchedApps(ctx context.Context, cfg *Config, appURIs []string, emit func(event *WatchdogEvent)) ([]App, error) {
+	appStore, err := cfg.AppStoreFactory()
+	if err != nil {
+		return nil, err
+	}
+	refs := appURIs
+	if len(refs) == 0 {
+		if refs, err = getStoreAppRefs(ctx, appStore); err != nil {
+			return nil, err
+		}
+	}
+	apps, err := loadAppTrees(ctx, cfg, appStore, refs, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(appURIs) > 0 {
+		return apps, nil
+	}
+
+	projects, err := ListComposeProjects(cfg)
+	if err != nil {
+		return nil, err
+	}
+	installed := map[string]bool{}
+	for _, project := range projects {
+		installed[project] = true
+	}
+	var appNames []string
+	appVersions := map[string][]App{}
+	for _, app := range apps {
+		if !installed[app.Name()] {
+			continue
+		}
+		if _, ok := appVersions[app.Name()]; !ok {
+			appNames = append(appNames, app.Name())
+		}
+		appVersions[app.Name()] = append(appVersions[app.Name()], app)
+	}
+	var installedApps []App
+	for _, appName := range appNames {
+		versions := appVersions[appName]
+		if len(versions) == 1 {
+			installedApps = append(installedApps, versions[0])
+			continue
+		}
+		if app, err := getInstalledAppVersion(ctx, cfg, appStore, versions); err != nil {
+			emit(&WatchdogEvent{Type: WatchdogEventAppCheckFailed, App: versions[0], Err: err})
+		} else {
+			installedApps = append(installedApps, app)
+		}
+	}
+	return installedApps, nil
+}
+
+// getInstalledAppVersion returns the version of the app which compose project is installed.
+func getInstalledAppVersion(ctx context.Context, cfg *Config, provider BlobProvider, versions []App) (App, error) {
+	var errs []string
+	for _, app := range versions {
+		bundleErrs, err := app.CheckComposeInstallation(ctx, provider, cfg.GetAppComposeDir(app.Name()),
+			WithAllowedExtraFiles(cfg.AllowedBundleExtraFiles...))
+		if err == nil && len(bundleErrs) == 0 {
+			return app, nil
+		}
+		if err == nil {
+			err = &ErrComposeInstall{Errs: bundleErrs}
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", app.Ref().Digest, err))
+	}
+	return nil, fmt.Errorf("none of the app versions found in the store is installed: %s", strings.Join(errs, "; "))
+}
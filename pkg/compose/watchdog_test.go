@@ -0,0 +1,170 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+func TestWatchdog(t *testing.T) {
+	app := &testApp{name: "app", ref: &AppRef{Digest: digest.FromString("app")}}
+	services := []*Service{
+		{Name: "web", CtrID: "web-ctr", State: "running", Health: "healthy"},
+		{Name: "db", CtrID: "db-ctr", State: "running", Health: "healthy"},
+	}
+	var events []WatchdogEventType
+	var actions []string
+	w := newWatchdog(&Config{}, &WatchdogOptions{
+		RestartUnhealthy: true,
+		RecreateMissing:  true,
+		MaxFailures:      3,
+		StopOnEscalation: true,
+		EventHandler: func(event *WatchdogEvent) {
+			events = append(events, event.Type)
+		},
+	})
+	w.getStatus = func(ctx context.Context, appURIs []string) ([]App, *RunningStatus, error) {
+		return []App{app}, &RunningStatus{
+			AppsRunningStatus: map[digest.Digest]RunningReport{app.Ref().Digest: {Services: services}},
+		}, nil
+	}
+	w.startApp = func(app App) error {
+		actions = append(actions, "start")
+		return nil
+	}
+	w.stopApp = func(app App) error {
+		actions = append(actions, "stop")
+		return nil
+	}
+	w.restartService = func(ctx context.Context, srv *Service) error {
+		actions = append(actions, "restart "+srv.Name)
+		return errors.New("restart failed")
+	}
+	check := func(expectedActions []string, expectedEvents ...WatchdogEventType) {
+		t.Helper()
+		actions, events = nil, nil
+		if err := w.check(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actions, expectedActions) {
+			t.Errorf("unexpected actions: got %v, want %v", actions, expectedActions)
+		}
+		if !reflect.DeepEqual(events, expectedEvents) {
+			t.Errorf("unexpected events: got %v, want %v", events, expectedEvents)
+		}
+	}
+
+	check(nil)
+	// A service which health check has not passed yet is not remediated
+	services[0].Health = "starting"
+	check(nil)
+
+	services[0].Health = "unhealthy"
+	check([]string{"restart web"}, WatchdogEventRemediationFailed)
+	if w.failures[app.Ref().Digest] != 1 {
+		t.Errorf("unexpected number of failures: %d", w.failures[app.Ref().Digest])
+	}
+	// A missing container is recreated by starting the app, which recreates the unhealthy ones too
+	services[1].State = "not created"
+	services[1].Health = ""
+	check([]string{"start"}, WatchdogEventServiceRecreated)
+
+	services[0].Health = "healthy"
+	services[1].State = "running"
+	services[1].Health = "healthy"
+	check(nil, WatchdogEventAppRecovered)
+	if _, ok := w.failures[app.Ref().Digest]; ok {
+		t.Error("expected the failure counter to be reset")
+	}
+
+	services[1].State = "exited"
+	services[1].Health = "unhealthy"
+	check([]string{"restart db"}, WatchdogEventRemediationFailed)
+	check([]string{"restart db"}, WatchdogEventRemediationFailed)
+	check([]string{"stop"}, WatchdogEventAppEscalated, WatchdogEventAppStopped)
+	// The watchdog gives up on the escalated app
+	check(nil)
+}
+
+func TestWatchdogSkipsDisabledApps(t *testing.T) {
+	app := &testApp{name: "app", ref: &AppRef{Digest: digest.FromString("app")}}
+	w := newWatchdog(&Config{}, &WatchdogOptions{RestartUnhealthy: true, RecreateMissing: true})
+	w.failures[app.Ref().Digest] = 2
+	w.getStatus = func(ctx context.Context, appURIs []string) ([]App, *RunningStatus, error) {
+		return []App{app}, &RunningStatus{
+			AppsRunningStatus: map[digest.Digest]RunningReport{app.Ref().Digest: {
+				Services: []*Service{{Name: "web", State: "not created"}},
+				Disabled: true,
+			}},
+		}, nil
+	}
+	w.startApp = func(app App) error {
+		t.Error("unexpected start of the disabled app")
+		return nil
+	}
+	if err := w.check(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.failures[app.Ref().Digest]; ok {
+		t.Error("expected the failure counter of the disabled app to be reset")
+	}
+}
+
+func TestGetWatchedApps(t *testing.T) {
+	cfg := newSettingsTestConfig(t)
+	store := &testAppStore{}
+	cfg.AppStoreFactoryFunc = func(c *Config) (AppStore, error) {
+		return store, nil
+	}
+	loader := testAppLoader{}
+	cfg.AppLoader = loader
+	newApp := func(name string, version string) *testApp {
+		ref, err := ParseAppRef("hub.foundries.io/factory/" + name + "@" + digest.FromString(name+version).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		app := &testApp{name: name, ref: ref, bundleFiles: map[string]string{"docker-compose.yml": version}}
+		store.refs = append(store.refs, ref)
+		loader[ref.String()] = app
+		return app
+	}
+	install := func(name string, version string) {
+		if err := os.MkdirAll(filepath.Join(cfg.GetAppComposeDir(name), "data"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(cfg.GetAppComposeDir(name), "docker-compose.yml"), []byte(version), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The only version of the app is watched if its project is installed, even if the project is modified
+	single := newApp("single", "v1")
+	install("single", "modified")
+	newApp("notinstalled", "v1")
+	// The installed version is found if several versions of the app are in the store
+	newApp("several", "v1")
+	several := newApp("several", "v2")
+	install("several", "v2")
+	newApp("unknown", "v1")
+	newApp("unknown", "v2")
+	install("unknown", "v3")
+
+	var events []*WatchdogEvent
+	apps, err := getWatchedApps(context.Background(), cfg, nil, func(event *WatchdogEvent) {
+		events = append(events, event)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(apps, []App{single, several}) {
+		t.Errorf("unexpected watched apps: %v", apps)
+	}
+	if len(events) != 1 || events[0].Type != WatchdogEventAppCheckFailed || events[0].App.Name() != "unknown" || events[0].Err == nil {
+		t.Errorf("expected app check failure event for the unknown app version, got: %v", events)
+	}
+}
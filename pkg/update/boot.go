@@ -0,0 +1,181 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+)
+
+type (
+	BootAction string
+
+	BootReconcileReport struct {
+		UpdateID        string     `json:"update_id,omitempty"`
+		UpdateState     State      `json:"update_state,omitempty"`
+		UpdateAction    BootAction `json:"update_action,omitempty"`
+		InstalledApps   []string   `json:"installed_apps,omitempty"`
+		StartedApps     []string   `json:"started_apps,omitempty"`
+		StoppedProjects []string   `json:"stopped_projects,omitempty"`
+	}
+
+	BootReconcileOptions struct {
+		// HandleCurrentUpdate allows resuming or rolling back an update that is in progress.
+		// It must be set only if no other process can run the update concurrently, e.g. at boot time.
+		HandleCurrentUpdate bool
+	}
+	BootReconcileOption func(*BootReconcileOptions)
+)
+
+const (
+	// BootActionNone - the update has not modified the installed apps yet, it is left as is to be resumed by its client
+	BootActionNone BootAction = "none"
+	// BootActionRolledBack - the update installation was interrupted, the update is canceled
+	BootActionRolledBack BootAction = "rolled-back"
+	// BootActionResumedStart - the update apps were installed, they are (re)started
+	BootActionResumedStart BootAction = "resumed-start"
+	// BootActionResumedComplete - the update completion was interrupted, the completion is resumed
+	BootActionResumedComplete BootAction = "resumed-complete"
+	// BootActionResumedCancel - the update cancellation was interrupted, the cancellation is resumed
+	BootActionResumedCancel BootAction = "resumed-cancel"
+)
+
+var (
+	ErrUpdateInProgress = errors.New("update is in progress")
+)
+
+func WithCurrentUpdateHandling(handle bool) BootReconcileOption {
+	return func(o *BootReconcileOptions) {
+		o.HandleCurrentUpdate = handle
+	}
+}
+
+// Reconcile brings the apps to the state defined by the update DB, which is useful after an unclean shutdown.
+// If there is an update in progress then it is resumed or rolled back depending on its state.
+// Then, it makes sure that the apps of the current (if it is started) or the last successful update are installed
+// and running, and that no other compose project from the compose root is running.
+func Reconcile(ctx context.Context, cfg *compose.Config, options ...BootReconcileOption) (*BootReconcileReport, error) {
+	opts := BootReconcileOptions{}
+	for _, o := range options {
+		o(&opts)
+	}
+
+	report := &BootReconcileReport{}
+	var appURIs []string
+	var updateAppsStarted bool
+
+	current, err := GetCurrentUpdate(cfg)
+	if err != nil && !errors.Is(err, ErrUpdateNotFound) {
+		return nil, err
+	}
+	if current != nil {
+		u := current.Status()
+		if !opts.HandleCurrentUpdate {
+			return nil, fmt.Errorf("%w: %s, state: %s", ErrUpdateInProgress, u.ID, u.State.String())
+		}
+		report.UpdateID = u.ID
+		report.UpdateState = u.State
+		var op string
+		switch u.State {
+		case StateCreated, StateInitializing, StateInitialized, StateFetching, StateFetched:
+			report.UpdateAction = BootActionNone
+		case StateInstalling:
+			// The installation was interrupted, some of the app compose projects and images might be partially
+			// installed, so roll back to the apps of the last successful update.
+			report.UpdateAction = BootActionRolledBack
+			op = "roll back"
+			err = current.Cancel(ctx)
+		case StateCancelling:
+			report.UpdateAction = BootActionResumedCancel
+			op = "resume cancellation of"
+			err = current.Cancel(ctx)
+		case StateInstalled, StateStarting, StateStarted:
+			report.UpdateAction = BootActionResumedStart
+			op = "resume start of"
+			err = current.Start(ctx)
+			updateAppsStarted = true
+		case StateCompleting:
+			report.UpdateAction = BootActionResumedComplete
+			op = "resume completion of"
+			err = current.Complete(ctx)
+			updateAppsStarted = true
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to %s update %s: %w", op, u.ID, err)
+		}
+		if updateAppsStarted {
+			appURIs = u.URIs
+			report.StartedApps = u.URIs
+		}
+	}
+
+	if !updateAppsStarted {
+		lastSuccessful, err := GetLastSuccessfulUpdate(cfg)
+		if err != nil {
+			if errors.Is(err, ErrUpdateNotFound) {
+				// Nothing is known about the apps that should be running
+				return report, nil
+			}
+			return report, err
+		}
+		appURIs = lastSuccessful.URIs
+		if err := ensureAppsRunning(ctx, cfg, appURIs, report); err != nil {
+			return report, err
+		}
+	}
+
+	appNames := map[string]struct{}{}
+	for _, uri := range appURIs {
+		ref, err := compose.ParseAppRef(uri)
+		if err != nil {
+			return report, err
+		}
+		appNames[ref.Name] = struct{}{}
+	}
+	runningProjects, err := compose.ListRunningProjects(ctx, cfg)
+	if err != nil {
+		return report, err
+	}
+	for _, project := range runningProjects {
+		if _, ok := appNames[project]; ok {
+			continue
+		}
+		if err := compose.StopProject(ctx, cfg, project); err != nil {
+			return report, err
+		}
+		report.StoppedProjects = append(report.StoppedProjects, project)
+	}
+	return report, nil
+}
+
+func ensureAppsRunning(ctx context.Context, cfg *compose.Config, appURIs []string, report *BootReconcileReport) error {
+	if len(appURIs) == 0 {
+		return nil
+	}
+	status, err := compose.CheckAppsStatus(ctx, cfg, appURIs, compose.WithQuickCheckFetch(true))
+	if err != nil {
+		return err
+	}
+	for _, app := range status.Apps {
+		installReport := status.AppsInstallStatus[app.Ref().Digest]
+		installed := len(installReport.BundleErrors) == 0
+		for _, imageInstalled := range installReport.Images {
+			installed = installed && imageInstalled
+		}
+		if installed {
+			continue
+		}
+		if err := compose.Install(ctx, cfg, app.Ref().String()); err != nil {
+			return err
+		}
+		report.InstalledApps = append(report.InstalledApps, app.Ref().String())
+	}
+	if len(report.InstalledApps) > 0 || !status.AreRunning() {
+		if err := compose.StartApps(ctx, cfg, appURIs); err != nil {
+			return err
+		}
+		report.StartedApps = appURIs
+	}
+	return nil
+}
@@ -11,7 +11,7 @@ import (
 	"path"
 )
 
-func (u *runnerImpl) cancel(ctx context.Context) (err error) {
+func (u *runnerImpl) cancel(ctx context.Context, b *session) (err error) {
 
 	if len(u.LoadedImages) > 0 {
 		cli, err := compose.GetDockerClient(u.config.DockerHost)
@@ -29,17 +29,48 @@ func (u *runnerImpl) cancel(ctx context.Context) (err error) {
 		}
 	}
 
-	// TODO: remove any installed compose projects
+	// Restore the compose projects replaced by the update installation
+	for appName := range u.ReplacedProjects {
+		// The previous project is missing only if it was restored by an interrupted cancellation
+		if err := compose.RestorePrevCompose(u.config, appName); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to restore compose project of %s: %w", appName, err)
+		}
+		delete(u.ReplacedProjects, appName)
+		if err := b.write(&u.Update); err != nil {
+			return fmt.Errorf("failed to save update state: %w", err)
+		}
+	}
+	// Remove the compose projects of the apps that were not installed before the update
+	for appName := range u.CreatedProjects {
+		if err := compose.RemoveCompose(u.config, appName); err != nil {
+			return fmt.Errorf("failed to remove compose project of %s: %w", appName, err)
+		}
+		delete(u.CreatedProjects, appName)
+		if err := b.write(&u.Update); err != nil {
+			return fmt.Errorf("failed to save update state: %w", err)
+		}
+	}
+
+	// Nothing has been run by the canceled update, so the volume snapshots are not needed
+	if err := u.removeVolumeSnapshots(); err != nil {
+		return fmt.Errorf("failed to remove volume snapshots: %w", err)
+	}
 
 	var errBlobs []string
 	progressStep := int(math.Round(100 / float64(len(u.Blobs))))
-	for _, b := range u.Blobs {
-		p := path.Join(u.config.GetBlobsRoot(), b.Descriptor.Digest.Encoded())
-		if err := os.Remove(p); err != nil {
-			if !os.IsNotExist(err) {
-				// TODO: add debug logging
-				errBlobs = append(errBlobs, b.Descriptor.Digest.Encoded())
-			}
+	for _, blob := range u.Blobs {
+		p := path.Join(u.config.GetBlobsRoot(), blob.Descriptor.Digest.Encoded())
+		removeErr := os.Remove(p)
+		if os.IsNotExist(removeErr) {
+			removeErr = nil
+		}
+		// The images loaded by the low-storage fetch are removed, so their discarded layers are not loaded anymore
+		if err := compose.RemoveLoadedMarker(u.config, blob.Descriptor.Digest); err != nil && removeErr == nil {
+			removeErr = err
+		}
+		if removeErr != nil {
+			// TODO: add debug logging
+			errBlobs = append(errBlobs, blob.Descriptor.Digest.Encoded())
 		}
 		// take into account the rounding error
 		if u.Progress < 100 {
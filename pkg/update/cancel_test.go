@@ -0,0 +1,46 @@
+package update
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestCancelReportsEachBlobOnce(t *testing.T) {
+	cfg := &compose.Config{StoreRoot: t.TempDir()}
+	removed := digest.FromString("removed")
+	failed := digest.FromString("failed")
+	// Neither the blob nor its loaded marker can be removed if they are non-empty directories
+	for _, path := range []string{
+		filepath.Join(cfg.GetBlobsRoot(), failed.Encoded(), "file"),
+		filepath.Join(cfg.GetLoadedBlobsRoot(), failed.Encoded(), "file"),
+		filepath.Join(cfg.GetBlobsRoot(), removed.Encoded()),
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := &runnerImpl{
+		Update: Update{ID: "update1", Blobs: compose.BlobsFetchProgress{
+			removed: {BlobInfo: compose.BlobInfo{Descriptor: &ocispec.Descriptor{Digest: removed}}},
+			failed:  {BlobInfo: compose.BlobInfo{Descriptor: &ocispec.Descriptor{Digest: failed}}},
+		}},
+		config: cfg,
+	}
+	err := u.cancel(context.Background(), nil)
+	if err == nil || err.Error() != "failed to remove blobs; number: 1" {
+		t.Errorf("expected failure to remove one blob, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.GetBlobsRoot(), removed.Encoded())); !os.IsNotExist(err) {
+		t.Errorf("blob is not removed: %v", err)
+	}
+}
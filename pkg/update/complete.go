@@ -55,6 +55,15 @@ func (u *runnerImpl) complete(ctx context.Context, options ...CompleteOpt) error
 		appNames[app.Name()] = struct{}{}
 	}
 
+	var appList []compose.App
+	for _, app := range updateApps {
+		appList = append(appList, app)
+	}
+	loadedLayers, err := compose.GetLoadedLayers(ctx, u.config, appList)
+	if err != nil {
+		return err
+	}
+
 	missingBlobs := map[string]string{}
 	appBlobs := make(map[string]struct{})
 	for appURI, app := range updateApps {
@@ -73,7 +82,7 @@ func (u *runnerImpl) complete(ctx context.Context, options ...CompleteOpt) error
 			}
 
 			appBlobs[node.Descriptor.Digest.Encoded()] = struct{}{}
-			if bs != compose.BlobOk {
+			if bs != compose.BlobOk && !loadedLayers[node.Descriptor.Digest] {
 				missingBlobs[blobURI] = node.Descriptor.Digest.Encoded()
 			}
 
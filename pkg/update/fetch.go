@@ -2,6 +2,7 @@ package update
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/foundriesio/composeapp/pkg/compose"
 )
@@ -16,10 +17,29 @@ func (u *runnerImpl) fetch(
 		o(&opts)
 	}
 
+	// The low-storage fetch fetches blobs by several FetchBlobs calls, the progress of each call is added
+	// to the progress of the previous ones
+	var prevFetchedBytes int64
+	var prevFetchedBlobs int
+	if u.LowStorage {
+		for _, bi := range u.Blobs {
+			if bi.State == compose.BlobOk {
+				prevFetchedBytes += bi.Descriptor.Size - bi.BlobInfo.BytesFetched
+				prevFetchedBlobs++
+			}
+		}
+	}
+
+	// Stop fetching if the fetch is paused by this or another process
+	fetchCtx, cancelFetch := context.WithCancel(ctx)
+	defer cancelFetch()
 	fetchOptions := options
 	// override the progress reporter if one is provided
 	fetchOptions = append(fetchOptions,
 		compose.WithFetchProgress(func(p *compose.FetchProgress) {
+			if IsFetchPaused(u.config) {
+				cancelFetch()
+			}
 			for d, b := range p.Blobs {
 				if u.Blobs[d].State == compose.BlobOk {
 					// Blob is already fetched and its state has been already updated, so move to the next blob
@@ -27,14 +47,15 @@ func (u *runnerImpl) fetch(
 				}
 				*u.Blobs[d] = *b
 			}
-			u.FetchedBytes = p.CurrentBytes
-			u.FetchedBlobs = p.FetchedCount
+			u.FetchedBytes = prevFetchedBytes + p.CurrentBytes
+			u.FetchedBlobs = prevFetchedBlobs + p.FetchedCount
 			if u.TotalBlobsBytes != 0 {
-				u.Progress = int((p.CurrentBytes * 100) / u.TotalBlobsBytes)
+				u.Progress = int((u.FetchedBytes * 100) / u.TotalBlobsBytes)
 			} else {
 				u.Progress = 100
 			}
-			if u.Progress == 100 {
+			// The low-storage fetch is not done until the last image is loaded
+			if u.Progress == 100 && !u.LowStorage {
 				u.State = StateFetched
 			}
 			if storeErr := b.write(&u.Update); storeErr != nil {
@@ -47,9 +68,27 @@ func (u *runnerImpl) fetch(
 			}
 		}))
 
-	blobsToFetch := make(compose.BlobsInfo)
-	for d, b := range u.Blobs {
-		blobsToFetch[d] = &b.BlobInfo
+	if u.LowStorage {
+		err = u.fetchLowStorage(fetchCtx, b, func(blobsToFetch compose.BlobsInfo) error {
+			if len(blobsToFetch) == 0 {
+				return nil
+			}
+			if err := compose.FetchBlobs(fetchCtx, u.config, blobsToFetch, fetchOptions...); err != nil {
+				return err
+			}
+			prevFetchedBytes, prevFetchedBlobs = u.FetchedBytes, u.FetchedBlobs
+			return nil
+		})
+	} else {
+		blobsToFetch := make(compose.BlobsInfo)
+		for d, b := range u.Blobs {
+			blobsToFetch[d] = &b.BlobInfo
+		}
+		err = compose.FetchBlobs(fetchCtx, u.config, blobsToFetch, fetchOptions...)
+	}
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.Canceled) && IsFetchPaused(u.config) {
+		// Wrap the cancellation error so the update is not marked as failed
+		err = fmt.Errorf("%w: %w", ErrUpdatePaused, err)
 	}
-	return compose.FetchBlobs(ctx, u.config, blobsToFetch, fetchOptions...)
+	return err
 }
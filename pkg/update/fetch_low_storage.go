@@ -0,0 +1,113 @@
+package update
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containerd/containerd/platforms"
+	"github.com/foundriesio/composeapp/pkg/compose"
+	v1 "github.com/foundriesio/composeapp/pkg/compose/v1"
+	"github.com/opencontainers/go-digest"
+)
+
+type (
+	lowStorageImage struct {
+		app    compose.App
+		uri    string
+		layers []*compose.TreeNode
+		loaded bool
+	}
+)
+
+// fetchLowStorage fetches the metadata blobs of the update apps at first, then fetches the layers of each app image
+// and loads the image into docker one by one. Once an image is loaded, its layers that are not needed to load
+// the remaining images are replaced by the "loaded" markers in the store.
+func (u *runnerImpl) fetchLowStorage(ctx context.Context, b *session, fetchBlobs func(compose.BlobsInfo) error) error {
+	metaBlobs := compose.BlobsInfo{}
+	for d, bi := range u.Blobs {
+		if bi.Type != compose.BlobTypeImageLayer && bi.State != compose.BlobOk {
+			metaBlobs[d] = &bi.BlobInfo
+		}
+	}
+	if err := fetchBlobs(metaBlobs); err != nil {
+		return err
+	}
+
+	appStore, err := v1.NewAppStore(u.config.StoreRoot, u.config.Platform, false)
+	if err != nil {
+		return err
+	}
+	var images []*lowStorageImage
+	// The number of images referencing a layer that are not loaded yet
+	pendingLayers := map[digest.Digest]int{}
+	for _, appURI := range u.URIs {
+		app, err := u.config.AppLoader.LoadAppTree(ctx, appStore, platforms.OnlyStrict(u.config.Platform), appURI)
+		if err != nil {
+			return err
+		}
+		// The images which layers were discarded by a previous low-storage fetch are in docker already
+		imagesToLoad, err := compose.GetImagesToLoad(ctx, u.config, app)
+		if err != nil {
+			return err
+		}
+		loadable := map[string]bool{}
+		for _, imageURI := range imagesToLoad {
+			loadable[imageURI] = true
+		}
+		for _, imageNode := range app.GetComposeRoot().Children {
+			image := &lowStorageImage{app: app, uri: imageNode.Ref(), loaded: !loadable[imageNode.Ref()]}
+			if err := imageNode.Walk(func(node *compose.TreeNode, depth int) error {
+				if node.Type == compose.BlobTypeImageLayer {
+					image.layers = append(image.layers, node)
+					pendingLayers[node.Descriptor.Digest]++
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			images = append(images, image)
+		}
+	}
+
+	if u.LoadedImages == nil {
+		u.LoadedImages = make(map[string]struct{})
+	}
+	for _, image := range images {
+		if _, ok := u.LoadedImages[image.uri]; !ok && !image.loaded {
+			if err := u.fetchAndLoadImage(ctx, b, image, fetchBlobs); err != nil {
+				return err
+			}
+		}
+		for _, layer := range image.layers {
+			pendingLayers[layer.Descriptor.Digest]--
+			// Only the layers fetched by the update are discarded, the ones that were in the store before are kept
+			if bi, ok := u.Blobs[layer.Descriptor.Digest]; ok && pendingLayers[layer.Descriptor.Digest] == 0 {
+				if err := compose.DiscardLoadedBlob(u.config, *bi.Descriptor); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (u *runnerImpl) fetchAndLoadImage(ctx context.Context, b *session, image *lowStorageImage, fetchBlobs func(compose.BlobsInfo) error) error {
+	layersToFetch := compose.BlobsInfo{}
+	for _, layer := range image.layers {
+		if bi, ok := u.Blobs[layer.Descriptor.Digest]; ok && bi.State != compose.BlobOk {
+			layersToFetch[layer.Descriptor.Digest] = &bi.BlobInfo
+		}
+	}
+	if err := fetchBlobs(layersToFetch); err != nil {
+		return err
+	}
+	if err := compose.LoadAppImages(ctx, u.config, image.app, compose.WithImages(image.uri)); err != nil {
+		return err
+	}
+	// The loaded images are removed from docker if the update is canceled
+	u.LoadedImages[image.uri] = struct{}{}
+	if err := b.write(&u.Update); err != nil {
+		return fmt.Errorf("failed to save update state: %w", err)
+	}
+	return nil
+}
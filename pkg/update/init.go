@@ -24,6 +24,12 @@ type (
 		ProgressReporter  progress.Reporter[InitProgress]
 		AllowEmptyAppList bool // Allow specifying an empty app list, which means updating to "no apps" state, hence removing all current apps.
 		CheckStatus       bool // Check the status of the specified apps and move the update state to the state that corresponds to this status.
+		// Snapshot named volumes of the changed apps before the installation, limiting the total snapshot size.
+		VolumeSnapshotMaxSize int64
+		// Import the app archive to the local store and load the update apps from the store instead of a registry
+		ArchivePath string
+		// Fetch the update in the low-storage mode
+		LowStorage bool
 	}
 
 	InitOption func(options *InitOptions)
@@ -53,6 +59,21 @@ func WithInitCheckStatus(checkStatus bool) InitOption {
 	}
 }
 
+func WithInitFromArchive(archivePath string) InitOption {
+	return func(o *InitOptions) {
+		o.ArchivePath = archivePath
+	}
+}
+
+// WithInitLowStorage makes the update fetch load each image into docker right after fetching its blobs and
+// replace its layers in the store by small "loaded" markers. So, the layers are not kept both in the store and
+// in docker, and the store holds the layers of about one image at a time.
+func WithInitLowStorage(lowStorage bool) InitOption {
+	return func(o *InitOptions) {
+		o.LowStorage = lowStorage
+	}
+}
+
 func (u *runnerImpl) initUpdate(ctx context.Context, b *session, options ...InitOption) (err error) {
 	opts := InitOptions{}
 	for _, o := range options {
@@ -65,7 +86,19 @@ func (u *runnerImpl) initUpdate(ctx context.Context, b *session, options ...Init
 		}
 	}()
 
-	srcBlobProvider := compose.NewRemoteBlobProviderFromConfig(u.config)
+	appStore, err := v1.NewAppStore(u.config.StoreRoot, u.config.Platform, false)
+	if err != nil {
+		return err
+	}
+	var srcBlobProvider compose.BlobProvider
+	if len(opts.ArchivePath) > 0 {
+		if _, err := compose.ImportArchive(ctx, u.config, opts.ArchivePath); err != nil {
+			return err
+		}
+		srcBlobProvider = appStore
+	} else if srcBlobProvider, err = compose.NewRemoteBlobProviderFromConfig(u.config); err != nil {
+		return err
+	}
 
 	p := InitProgress{
 		State:   UpdateInitStateLoadingTree,
@@ -93,10 +126,6 @@ func (u *runnerImpl) initUpdate(ctx context.Context, b *session, options ...Init
 		}
 	}
 
-	appStore, err := v1.NewAppStore(u.config.StoreRoot, u.config.Platform, false)
-	if err != nil {
-		return err
-	}
 	ls, err := local.NewStore(u.config.StoreRoot)
 	if err != nil {
 		return err
@@ -105,7 +134,6 @@ func (u *runnerImpl) initUpdate(ctx context.Context, b *session, options ...Init
 	var storeSizeTotal int64 = 0
 	var runtimeSizeTotal int64 = 0
 	var downloadSizeTotal int64 = 0
-	var totalSize int64 = 0
 
 	if opts.ProgressReporter != nil {
 		p.State = UpdateInitStateCheckingBlobs
@@ -114,6 +142,17 @@ func (u *runnerImpl) initUpdate(ctx context.Context, b *session, options ...Init
 		opts.ProgressReporter.Update(p)
 	}
 
+	// The layers discarded after loading their images by a low-storage fetch do not have to be fetched again
+	// The apps are listed in the order the low-storage fetch loads their images
+	var appList []compose.App
+	for _, appURI := range u.URIs {
+		appList = append(appList, apps[appURI])
+	}
+	loadedLayers, err := compose.GetLoadedLayers(ctx, u.config, appList)
+	if err != nil {
+		return err
+	}
+
 	u.Blobs = make(compose.BlobsFetchProgress)
 
 	for appURI, app := range apps {
@@ -137,7 +176,7 @@ func (u *runnerImpl) initUpdate(ctx context.Context, b *session, options ...Init
 				return stateCheckErr
 			}
 
-			if bs != compose.BlobOk && u.Blobs[blobDigest] == nil {
+			if bs != compose.BlobOk && !loadedLayers[blobDigest] && u.Blobs[blobDigest] == nil {
 				blobStoreSize := compose.AlignToBlockSize(node.Descriptor.Size, u.config.BlockSize)
 				blobRuntimeSize := app.GetBlobRuntimeSize(node.Descriptor, u.config.Platform.Architecture, u.config.BlockSize)
 
@@ -160,9 +199,8 @@ func (u *runnerImpl) initUpdate(ctx context.Context, b *session, options ...Init
 					BytesFetched: bytesFetched,
 				}
 				storeSizeTotal += blobStoreSize
-				runtimeSizeTotal += blobStoreSize
+				runtimeSizeTotal += blobRuntimeSize
 				downloadSizeTotal += node.Descriptor.Size - bytesFetched
-				totalSize += blobStoreSize + blobStoreSize
 			}
 			if opts.ProgressReporter != nil {
 				p.Current += 1
@@ -182,5 +220,15 @@ func (u *runnerImpl) initUpdate(ctx context.Context, b *session, options ...Init
 		}
 	}
 
-	return nil
+	u.TotalStoreBytes = storeSizeTotal
+	u.TotalRuntimeBytes = runtimeSizeTotal
+	if u.LowStorage {
+		// The layers are kept in the store only until their images are loaded into docker
+		missingBlobs := compose.BlobsInfo{}
+		for d, bi := range u.Blobs {
+			missingBlobs[d] = &bi.BlobInfo
+		}
+		u.TotalStoreBytes = compose.GetLowStoragePeakStoreSize(appList, missingBlobs)
+	}
+	return b.write(&u.Update)
 }
@@ -2,6 +2,8 @@ package update
 
 import (
 	"context"
+	"fmt"
+
 	"github.com/foundriesio/composeapp/pkg/compose"
 )
 
@@ -12,12 +14,37 @@ func (u *runnerImpl) install(
 	if u.LoadedImages == nil {
 		u.LoadedImages = make(map[string]struct{})
 	}
-	options = append(options, compose.WithLoadedImages(u.LoadedImages))
+	if u.ReplacedProjects == nil {
+		u.ReplacedProjects = make(map[string]struct{})
+	}
+	if u.CreatedProjects == nil {
+		u.CreatedProjects = make(map[string]struct{})
+	}
+	options = append(options, compose.WithLoadedImages(u.LoadedImages), compose.WithReplacedProjects(u.ReplacedProjects),
+		compose.WithCreatedProjects(u.CreatedProjects),
+		// The apps that are not part of the update are removed once it is completed,
+		// so the updated apps are checked for conflicts against each other only.
+		compose.WithTargetApps(u.URIs))
+	// The apps are checked before taking the volume snapshots, so an update that cannot be installed
+	// does not stop the running apps to snapshot their volumes
+	if err = compose.CheckInstall(ctx, u.config, u.URIs, options...); err != nil {
+		return err
+	}
+	if u.VolumeSnapshotMaxSize > 0 {
+		if err = u.snapshotVolumes(ctx, b); err != nil {
+			return err
+		}
+	}
 	for _, appURI := range u.URIs {
 		err = compose.Install(ctx, u.config, appURI, options...)
 		if err != nil {
 			return err
 		}
+		// Persist the changes made to the compose projects, so they are undone if the update is canceled
+		// after an interruption
+		if err = b.write(&u.Update); err != nil {
+			return fmt.Errorf("failed to save update state: %w", err)
+		}
 	}
 
 	// TODO: update installation progress conducted in compose.Install
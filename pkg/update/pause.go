@@ -0,0 +1,94 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+)
+
+const (
+	// The marker is created next to the update DB, it can be created and checked by any process without
+	// opening the DB, which is locked by a process fetching the update.
+	pauseMarkerSuffix = ".paused"
+	// How long to wait for the update DB to check the update state before pausing it
+	pauseLockTimeout = time.Second
+)
+
+var (
+	ErrUpdatePaused = errors.New("update fetch is paused")
+)
+
+func getPauseMarkerPath(cfg *compose.Config) string {
+	return cfg.DBFilePath + pauseMarkerSuffix
+}
+
+// PauseFetch requests to stop fetching the current update. An ongoing fetch is stopped gracefully, the partially
+// fetched blobs are kept in the store. The update cannot be fetched until ResumeFetch is called,
+// so other processes know not to resume the fetching automatically.
+func PauseFetch(cfg *compose.Config) error {
+	f, err := os.OpenFile(getPauseMarkerPath(cfg), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to pause update fetch: %w", err)
+	}
+	return f.Close()
+}
+
+// ResumeFetch removes the pause of the update fetch, it does not start the fetching.
+func ResumeFetch(cfg *compose.Config) error {
+	if err := os.Remove(getPauseMarkerPath(cfg)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to resume update fetch: %w", err)
+	}
+	return nil
+}
+
+// IsFetchPaused returns true if the update fetch is paused.
+func IsFetchPaused(cfg *compose.Config) bool {
+	_, err := os.Stat(getPauseMarkerPath(cfg))
+	return err == nil
+}
+
+// PauseCurrentUpdate pauses fetching of the current update if it is in a state that allows it.
+// The update DB is locked by a process fetching the update, so its state cannot be checked; in this case
+// only the pause is requested, and the fetching process stops and records the pause once it notices it.
+// A process that holds the DB to do something else than fetching does not notice the request,
+// it takes effect on the next fetch of the update.
+func PauseCurrentUpdate(ctx context.Context, cfg *compose.Config) error {
+	s, err := newStoreWithLockTimeout(cfg.DBFilePath, pauseLockTimeout)
+	if err == nil {
+		var u *runnerImpl
+		if u, err = getCurrentUpdate(cfg, s); err == nil {
+			err = u.Pause(ctx)
+		}
+	}
+	if errors.Is(err, errUpdateLocked) {
+		return PauseFetch(cfg)
+	}
+	return err
+}
+
+func (u *runnerImpl) Pause(ctx context.Context) error {
+	return u.store.lock(func(db *session) error {
+		if !u.State.IsOneOf(StateInitialized, StateFetching) {
+			return fmt.Errorf("cannot pause update when it is in state %q", u.State)
+		}
+		if err := PauseFetch(u.config); err != nil {
+			return err
+		}
+		u.Paused = true
+		return db.write(&u.Update)
+	})
+}
+
+func (u *runnerImpl) Resume(ctx context.Context, options ...compose.FetchOption) error {
+	if !u.State.IsOneOf(StateInitialized, StateFetching) {
+		return fmt.Errorf("cannot resume update when it is in state %q", u.State)
+	}
+	if err := ResumeFetch(u.config); err != nil {
+		return err
+	}
+	return u.Fetch(ctx, options...)
+}
@@ -0,0 +1,87 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+	"go.etcd.io/bbolt"
+)
+
+func newPauseTestUpdate(t *testing.T, state State) (*compose.Config, *runnerImpl) {
+	cfg := &compose.Config{StoreRoot: t.TempDir(), DBFilePath: filepath.Join(t.TempDir(), "updates.db")}
+	r, err := NewUpdate(cfg, "ref")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := r.(*runnerImpl)
+	u.State = state
+	if err := u.store.lock(func(db *session) error { return db.write(&u.Update) }); err != nil {
+		t.Fatal(err)
+	}
+	return cfg, u
+}
+
+func getPauseTestUpdate(t *testing.T, cfg *compose.Config) *runnerImpl {
+	r, err := GetCurrentUpdate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.(*runnerImpl)
+}
+
+func TestPauseCurrentUpdate(t *testing.T) {
+	cfg, _ := newPauseTestUpdate(t, StateCreated)
+	if err := PauseCurrentUpdate(context.Background(), cfg); err == nil {
+		t.Error("expected error when pausing update that is not initialized")
+	}
+	if IsFetchPaused(cfg) || getPauseTestUpdate(t, cfg).Paused {
+		t.Error("update that is not initialized is paused")
+	}
+
+	cfg, _ = newPauseTestUpdate(t, StateInitialized)
+	if err := PauseCurrentUpdate(context.Background(), cfg); err != nil {
+		t.Fatalf("failed to pause update: %s", err)
+	}
+	if !IsFetchPaused(cfg) || !getPauseTestUpdate(t, cfg).Paused {
+		t.Error("paused update is not recorded as paused")
+	}
+
+	u := getPauseTestUpdate(t, cfg)
+	if err := u.Fetch(context.Background()); !errors.Is(err, ErrUpdatePaused) {
+		t.Errorf("expected paused update error, got %v", err)
+	}
+	if u := getPauseTestUpdate(t, cfg); u.State != StateInitialized || !u.Paused {
+		t.Errorf("unexpected state of paused update: %s, paused: %v", u.State, u.Paused)
+	}
+
+	// The update has no blobs, so it is fetched once resumed
+	if err := u.Resume(context.Background()); err != nil {
+		t.Fatalf("failed to resume update: %s", err)
+	}
+	if u := getPauseTestUpdate(t, cfg); u.State != StateFetched || u.Paused || IsFetchPaused(cfg) {
+		t.Errorf("unexpected state of resumed update: %s, paused: %v", u.State, u.Paused)
+	}
+	if err := PauseCurrentUpdate(context.Background(), cfg); err == nil {
+		t.Error("expected error when pausing fetched update")
+	}
+}
+
+func TestPauseCurrentUpdateLocked(t *testing.T) {
+	cfg, _ := newPauseTestUpdate(t, StateFetching)
+	// Lock the DB as a process fetching the update does
+	db, err := bbolt.Open(cfg.DBFilePath, 0600, bbolt.DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := PauseCurrentUpdate(context.Background(), cfg); err != nil {
+		t.Fatalf("failed to pause update locked by another process: %s", err)
+	}
+	if !IsFetchPaused(cfg) {
+		t.Error("pause is not requested")
+	}
+}
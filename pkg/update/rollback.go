@@ -0,0 +1,57 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/foundriesio/composeapp/pkg/compose"
+)
+
+var (
+	ErrNoRollbackTarget = errors.New("no app version to roll back to")
+)
+
+// RollbackApp installs and starts the version of the given app delivered by the last successful update.
+// If the current app version was installed by a later update that snapshotted the app volumes,
+// then the volumes are restored from the snapshots before the app is started. It returns the URI of the app version that the app was rolled back to.
+func RollbackApp(ctx context.Context, cfg *compose.Config, app compose.App) (string, error) {
+	lastUpdate, err := GetLastSuccessfulUpdate(cfg)
+	if err != nil {
+		if errors.Is(err, ErrUpdateNotFound) {
+			return "", ErrNoRollbackTarget
+		}
+		return "", err
+	}
+	var targetURI string
+	for _, uri := range lastUpdate.URIs {
+		ref, err := compose.ParseAppRef(uri)
+		if err != nil {
+			return "", err
+		}
+		if ref.Name == app.Name() {
+			targetURI = uri
+			break
+		}
+	}
+	if len(targetURI) == 0 {
+		return "", fmt.Errorf("%w: %s is not found in the last successful update %s",
+			ErrNoRollbackTarget, app.Name(), lastUpdate.ID)
+	}
+	if targetURI == app.Ref().String() {
+		return "", fmt.Errorf("%w: %s is the version delivered by the last successful update %s",
+			ErrNoRollbackTarget, targetURI, lastUpdate.ID)
+	}
+	if err := compose.Install(ctx, cfg, targetURI); err != nil {
+		return "", err
+	}
+	if u, err := GetLastUpdate(cfg); err == nil && u.ID != lastUpdate.ID && len(u.VolumeSnapshots[app.Name()]) > 0 {
+		if _, err := RestoreAppVolumes(ctx, cfg, app.Name(), u.ID); err != nil {
+			return "", err
+		}
+	}
+	if err := compose.StartApps(ctx, cfg, []string{targetURI}); err != nil {
+		return "", err
+	}
+	return targetURI, nil
+}
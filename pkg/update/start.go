@@ -15,7 +15,8 @@ func (u *runnerImpl) start(ctx context.Context, b *session, options ...compose.S
 	// override the progress reporter if one is provided
 	startOptions = append(startOptions,
 		compose.WithStartProgressHandler(func(app compose.App, status compose.AppStartStatus, any interface{}) {
-			if status == compose.AppStartStatusStarted || status == compose.AppStartStatusFailed {
+			if status == compose.AppStartStatusStarted || status == compose.AppStartStatusFailed ||
+				status == compose.AppStartStatusDisabled {
 				u.Progress += progressStep
 			}
 			// invoke the progress reporter if one is provided by a caller
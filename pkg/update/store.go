@@ -12,6 +12,8 @@ import (
 type (
 	store struct {
 		path string
+		// How long to wait for the DB lock held by another process, zero means to wait indefinitely
+		lockTimeout time.Duration
 	}
 	session struct {
 		db *bbolt.DB
@@ -23,6 +25,8 @@ type (
 
 var (
 	ErrUpdateNotFound = errors.New("Update not found")
+	// The update DB is locked by another process processing the update
+	errUpdateLocked = errors.New("update is locked by another process")
 )
 
 const (
@@ -30,7 +34,13 @@ const (
 )
 
 func newStore(dbFilePath string) (*store, error) {
-	db, err := bbolt.Open(dbFilePath, 0600, bbolt.DefaultOptions)
+	return newStoreWithLockTimeout(dbFilePath, 0)
+}
+
+// newStoreWithLockTimeout returns errUpdateLocked if the DB is locked by another process longer than the timeout.
+func newStoreWithLockTimeout(dbFilePath string, lockTimeout time.Duration) (*store, error) {
+	s := &store{path: dbFilePath, lockTimeout: lockTimeout}
+	db, err := s.open(false)
 	if err != nil {
 		return nil, err
 	}
@@ -46,11 +56,19 @@ func newStore(dbFilePath string) (*store, error) {
 		return nil, err
 	}
 
-	return &store{path: dbFilePath}, nil
+	return s, nil
+}
+
+func (s *store) open(readOnly bool) (*bbolt.DB, error) {
+	db, err := bbolt.Open(s.path, 0600, &bbolt.Options{ReadOnly: readOnly, Timeout: s.lockTimeout})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, errUpdateLocked
+	}
+	return db, err
 }
 
 func (s *store) saveUpdate(key []byte, u *Update) error {
-	db, err := bbolt.Open(s.path, 0600, bbolt.DefaultOptions)
+	db, err := s.open(false)
 	if err != nil {
 		return err
 	}
@@ -66,7 +84,7 @@ func (s *store) saveUpdate(key []byte, u *Update) error {
 	})
 }
 func (s *store) lock(fn func(db *session) error) error {
-	db, err := bbolt.Open(s.path, 0600, bbolt.DefaultOptions)
+	db, err := s.open(false)
 	if err != nil {
 		return err
 	}
@@ -96,7 +114,7 @@ func (b *bucket) write(u *Update) error {
 }
 
 func (s *store) countFailedUpdates(keySuffix string) (int, error) {
-	db, err := bbolt.Open(s.path, 0600, &bbolt.Options{ReadOnly: true})
+	db, err := s.open(true)
 	if err != nil {
 		return 0, err
 	}
@@ -130,7 +148,7 @@ func (s *store) countFailedUpdates(keySuffix string) (int, error) {
 }
 
 func (s *store) getLastUpdateWithAnyOfStates(states []State) (*Update, error) {
-	db, err := bbolt.Open(s.path, 0600, &bbolt.Options{ReadOnly: true})
+	db, err := s.open(true)
 	if err != nil {
 		return nil, err
 	}
@@ -18,6 +18,8 @@ type (
 		Status() Update
 		Init(context.Context, []string, ...InitOption) error
 		Fetch(context.Context, ...compose.FetchOption) error
+		Pause(context.Context) error
+		Resume(context.Context, ...compose.FetchOption) error
 		Install(context.Context, ...compose.InstallOption) error
 		Start(context.Context, ...compose.StartOption) error
 		Cancel(context.Context) error
@@ -27,20 +29,32 @@ type (
 	State string
 
 	Update struct {
-		ID              string                     `json:"id"`
-		ClientRef       string                     `json:"client_ref"`
-		State           State                      `json:"state"`
-		Progress        int                        `json:"progress"`
-		CreationTime    time.Time                  `json:"creation_time"`
-		InitTime        time.Time                  `json:"init_time"`
-		FetchTime       time.Time                  `json:"fetch_time"`
-		UpdateTime      time.Time                  `json:"update_time"`
-		URIs            []string                   `json:"uris"`
-		Blobs           compose.BlobsFetchProgress `json:"blobs"`
-		TotalBlobsBytes int64                      `json:"total_blobs_bytes"` // total size of all blobs in bytes
-		LoadedImages    map[string]struct{}        `json:"loaded_images"`     // images that have been loaded into the docker storage
-		FetchedBytes    int64                      `json:"fetched_bytes"`     // total bytes fetched so far
-		FetchedBlobs    int                        `json:"fetched_blobs"`     // number of blobs fetched so far
+		ID               string                     `json:"id"`
+		ClientRef        string                     `json:"client_ref"`
+		State            State                      `json:"state"`
+		Progress         int                        `json:"progress"`
+		CreationTime     time.Time                  `json:"creation_time"`
+		InitTime         time.Time                  `json:"init_time"`
+		FetchTime        time.Time                  `json:"fetch_time"`
+		UpdateTime       time.Time                  `json:"update_time"`
+		URIs             []string                   `json:"uris"`
+		Blobs            compose.BlobsFetchProgress `json:"blobs"`
+		TotalBlobsBytes  int64                      `json:"total_blobs_bytes"`           // total size of all blobs in bytes
+		LoadedImages     map[string]struct{}        `json:"loaded_images"`               // images that have been loaded into the docker storage
+		FetchedBytes     int64                      `json:"fetched_bytes"`               // total bytes fetched so far
+		FetchedBlobs     int                        `json:"fetched_blobs"`               // number of blobs fetched so far
+		ReplacedProjects map[string]struct{}        `json:"replaced_projects,omitempty"` // apps which compose projects have been replaced by the update
+		CreatedProjects  map[string]struct{}        `json:"created_projects,omitempty"`  // apps which compose projects have been installed by the update from scratch
+		// Limit of the total size of volume snapshots taken before the installation, zero disables the snapshotting
+		VolumeSnapshotMaxSize int64               `json:"volume_snapshot_max_size,omitempty"`
+		VolumeSnapshots       map[string][]string `json:"volume_snapshots,omitempty"` // app name -> names of its snapshotted volumes
+		// Load each image into docker right after fetching it and discard its layers from the store, see WithInitLowStorage
+		LowStorage bool `json:"low_storage,omitempty"`
+		// The fetch is paused, the update stays in its state until the fetch is resumed, see PauseCurrentUpdate
+		Paused bool `json:"paused,omitempty"`
+		// Store and runtime sizes required by the blobs to fetch, the store size of the low-storage fetch is its peak
+		TotalStoreBytes   int64 `json:"total_store_bytes,omitempty"`
+		TotalRuntimeBytes int64 `json:"total_runtime_bytes,omitempty"`
 	}
 
 	runnerImpl struct {
@@ -80,6 +94,11 @@ func (s State) IsOneOf(states ...State) bool {
 	return false
 }
 
+// GetUsageInfo returns the storage usage info of the update fetch and installation.
+func (u *Update) GetUsageInfo(cfg *compose.Config, watermark uint) (*compose.UsageInfo, error) {
+	return compose.GetUsageInfo(cfg.StoreRoot, u.TotalStoreBytes+u.TotalRuntimeBytes, watermark)
+}
+
 func NewUpdate(cfg *compose.Config, ref string) (Runner, error) {
 	_, err := GetCurrentUpdate(cfg)
 	if err == nil {
@@ -99,6 +118,10 @@ func NewUpdate(cfg *compose.Config, ref string) (Runner, error) {
 	if err != nil {
 		return nil, err
 	}
+	// The pause of a previous update fetch is not relevant to the new update
+	if err := ResumeFetch(cfg); err != nil {
+		return nil, err
+	}
 
 	u := &runnerImpl{
 		Update: Update{
@@ -162,6 +185,10 @@ func GetCurrentUpdate(cfg *compose.Config) (Runner, error) {
 	if err != nil {
 		return nil, err
 	}
+	return getCurrentUpdate(cfg, s)
+}
+
+func getCurrentUpdate(cfg *compose.Config, s *store) (*runnerImpl, error) {
 	u, err := s.getLastUpdateWithAnyOfStates([]State{
 		StateCreated,
 		StateInitializing,
@@ -203,6 +230,8 @@ func (u *runnerImpl) Init(ctx context.Context, appURIs []string, options ...Init
 					return fmt.Errorf("no app URIs for an update are specified")
 				}
 				u.URIs = appURIs
+				u.VolumeSnapshotMaxSize = opts.VolumeSnapshotMaxSize
+				u.LowStorage = opts.LowStorage
 			}
 		case StateInitializing, StateInitialized, StateFetching, StateFetched:
 			{
@@ -265,10 +294,20 @@ func (u *runnerImpl) Fetch(ctx context.Context, options ...compose.FetchOption)
 		if !u.State.IsOneOf(StateInitialized, StateFetching, StateFetched) {
 			return fmt.Errorf("cannot fetch update when it is in state %q", u.State)
 		}
+		if IsFetchPaused(u.config) {
+			if !u.Paused {
+				u.Paused = true
+				if err := db.write(&u.Update); err != nil {
+					return err
+				}
+			}
+			return ErrUpdatePaused
+		}
 
 		var err error
 		u.State = StateFetching
 		u.Progress = 0
+		u.Paused = false
 		err = db.write(&u.Update)
 		if err != nil {
 			return err
@@ -287,6 +326,8 @@ func (u *runnerImpl) Fetch(ctx context.Context, options ...compose.FetchOption)
 						fmt.Printf("failed to add info about fetched apps to the store: %v\n", err)
 					}
 				}
+			} else if errors.Is(err, ErrUpdatePaused) {
+				u.Paused = true
 			} else if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !isConnectionTimeout(err) {
 				u.State = StateFailed
 			}
@@ -371,8 +412,9 @@ func (u *runnerImpl) Start(ctx context.Context, options ...compose.StartOption)
 
 func (u *runnerImpl) Cancel(ctx context.Context) error {
 	return u.store.lock(func(db *session) error {
+		// Allow resuming an interrupted cancellation
 		if !u.State.IsOneOf(StateCreated, StateInitializing, StateInitialized,
-			StateFetching, StateFetched, StateInstalling, StateInstalled) {
+			StateFetching, StateFetched, StateInstalling, StateInstalled, StateCancelling) {
 			return fmt.Errorf("cannot cancel update when it is in state %q", u.State)
 		}
 
@@ -396,7 +438,10 @@ func (u *runnerImpl) Cancel(ctx context.Context) error {
 			}
 		}()
 
-		err = u.cancel(ctx)
+		err = u.cancel(ctx, db)
+		if err == nil {
+			err = ResumeFetch(u.config)
+		}
 		return err
 	})
 }
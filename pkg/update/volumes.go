@@ -0,0 +1,316 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	composetypes "github.com/compose-spec/compose-go/types"
+	"github.com/containerd/containerd/platforms"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/foundriesio/composeapp/pkg/compose"
+)
+
+const (
+	// VolumeSnapshotsDir is the name of the directory in the store root that holds snapshots of app volumes
+	// taken by updates, a snapshot of each volume is stored in `<update ID>/<app name>/<volume name>.tar`.
+	VolumeSnapshotsDir = "volume-snapshots"
+
+	volumeSnapshotExt = ".tar"
+)
+
+var (
+	ErrVolumeSnapshotNotFound = errors.New("volume snapshot is not found")
+)
+
+// WithInitVolumeSnapshots enables snapshotting of named volumes of the apps changed by the update.
+// The volumes are archived before the update installation, so their content can be restored if the app is rolled
+// back. The total size of the volume snapshots of the update is limited by maxSize, volumes that do not fit into
+// the limit are not snapshotted.
+func WithInitVolumeSnapshots(maxSize int64) InitOption {
+	return func(o *InitOptions) {
+		o.VolumeSnapshotMaxSize = maxSize
+	}
+}
+
+// GetVolumeSnapshotsDir returns a path to the directory holding the volume snapshots taken by the given update.
+func GetVolumeSnapshotsDir(cfg *compose.Config, updateID string) string {
+	return filepath.Join(cfg.StoreRoot, VolumeSnapshotsDir, updateID)
+}
+
+// RestoreAppVolumes restores the app volumes from the snapshots taken by the given update.
+// Containers using the volumes are stopped and are left stopped, since they belong to the app version that
+// the volume content is not restored for; the app is supposed to be started afterward.
+// It returns the names of the restored volumes.
+func RestoreAppVolumes(ctx context.Context, cfg *compose.Config, appName string, updateID string) ([]string, error) {
+	snapshotDir := filepath.Join(GetVolumeSnapshotsDir(cfg, updateID), appName)
+	volumes, err := listVolumeSnapshots(snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(volumes) == 0 {
+		return nil, fmt.Errorf("%w: app %s, update %s", ErrVolumeSnapshotNotFound, appName, updateID)
+	}
+
+	cli, err := compose.GetDockerClient(cfg.DockerHost)
+	if err != nil {
+		return nil, err
+	}
+	for _, volumeName := range volumes {
+		if err := restoreVolume(ctx, cli, volumeName, filepath.Join(snapshotDir, volumeName+volumeSnapshotExt)); err != nil {
+			return nil, fmt.Errorf("failed to restore volume %s of %s: %w", volumeName, appName, err)
+		}
+	}
+	return volumes, nil
+}
+
+// listVolumeSnapshots returns the sorted names of the volumes which snapshots are stored in the given directory.
+func listVolumeSnapshots(snapshotDir string) ([]string, error) {
+	entries, err := os.ReadDir(snapshotDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var volumes []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), volumeSnapshotExt) {
+			volumes = append(volumes, strings.TrimSuffix(e.Name(), volumeSnapshotExt))
+		}
+	}
+	sort.Strings(volumes)
+	return volumes, nil
+}
+
+// pruneVolumeSnapshots removes the volume snapshots taken by the updates other than the given one,
+// and returns the total size of the snapshots taken by the given update.
+func pruneVolumeSnapshots(cfg *compose.Config, updateID string) (int64, error) {
+	snapshotsRoot := filepath.Join(cfg.StoreRoot, VolumeSnapshotsDir)
+	if entries, err := os.ReadDir(snapshotsRoot); err == nil {
+		for _, e := range entries {
+			if e.Name() != updateID {
+				if err := os.RemoveAll(filepath.Join(snapshotsRoot, e.Name())); err != nil {
+					return 0, err
+				}
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	var totalSize int64
+	if err := filepath.WalkDir(GetVolumeSnapshotsDir(cfg, updateID), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		fi, err := d.Info()
+		if err == nil {
+			totalSize += fi.Size()
+		}
+		return err
+	}); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	return totalSize, nil
+}
+
+// getProjectVolumeNames returns the sorted names of the volumes created by the compose project,
+// the external volumes are not managed by the project, so they are not included.
+func getProjectVolumeNames(proj *composetypes.Project) []string {
+	var volumeNames []string
+	for key, v := range proj.Volumes {
+		if v.External.External {
+			continue
+		}
+		name := v.Name
+		if len(name) == 0 {
+			name = proj.Name + "_" + key
+		}
+		volumeNames = append(volumeNames, name)
+	}
+	sort.Strings(volumeNames)
+	return volumeNames
+}
+
+// snapshotVolumes archives the existing named volumes of the update apps which compose projects are going to be
+// replaced by the update installation. Snapshots taken by the previous updates are removed.
+func (u *runnerImpl) snapshotVolumes(ctx context.Context, b *session) error {
+	if u.VolumeSnapshots == nil {
+		u.VolumeSnapshots = make(map[string][]string)
+	}
+	totalSize, err := pruneVolumeSnapshots(u.config, u.ID)
+	if err != nil {
+		return err
+	}
+	snapshotDir := GetVolumeSnapshotsDir(u.config, u.ID)
+
+	appStore, err := u.config.AppStoreFactory()
+	if err != nil {
+		return err
+	}
+	cli, err := compose.GetDockerClient(u.config.DockerHost)
+	if err != nil {
+		return err
+	}
+	for _, appURI := range u.URIs {
+		app, err := u.config.AppLoader.LoadAppTree(ctx, appStore, platforms.OnlyStrict(u.config.Platform), appURI)
+		if err != nil {
+			return err
+		}
+		if _, ok := u.VolumeSnapshots[app.Name()]; ok {
+			// Snapshots of the app volumes are already taken by the interrupted installation
+			continue
+		}
+		appDir := u.config.GetAppComposeDir(app.Name())
+		if _, err := os.Stat(appDir); os.IsNotExist(err) {
+			// A new app, no volumes to snapshot
+			continue
+		}
+		if errs, err := app.CheckComposeInstallation(ctx, appStore, appDir,
+			compose.WithAllowedExtraFiles(u.config.AllowedBundleExtraFiles...)); err == nil && len(errs) == 0 {
+			// The app is not changed by the update
+			continue
+		}
+		proj, err := app.GetCompose(ctx, appStore)
+		if err != nil {
+			return err
+		}
+		snapshotted := []string{}
+		for _, volumeName := range getProjectVolumeNames(proj) {
+			snapshotFile := filepath.Join(snapshotDir, app.Name(), volumeName+volumeSnapshotExt)
+			size, err := snapshotVolume(ctx, cli, volumeName, snapshotFile, u.VolumeSnapshotMaxSize-totalSize)
+			if err != nil {
+				if errors.Is(err, errVolumeSnapshotTooBig) {
+					fmt.Printf("volume %s of %s is not snapshotted: %s\n", volumeName, app.Name(), err.Error())
+					continue
+				}
+				return fmt.Errorf("failed to snapshot volume %s of %s: %w", volumeName, app.Name(), err)
+			}
+			if size < 0 {
+				// The volume has not been created yet
+				continue
+			}
+			totalSize += size
+			snapshotted = append(snapshotted, volumeName)
+		}
+		u.VolumeSnapshots[app.Name()] = snapshotted
+		if err := b.write(&u.Update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errVolumeSnapshotTooBig = errors.New("the volume snapshot size limit is exceeded")
+
+// snapshotVolume archives the volume content into the given file, the containers using the volume are paused
+// during the archiving, so the snapshot is consistent. It returns the snapshot size or -1 if the volume does not exist.
+func snapshotVolume(ctx context.Context, cli *client.Client, volumeName string, snapshotFile string, maxSize int64) (int64, error) {
+	vol, err := cli.VolumeInspect(ctx, volumeName)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return -1, nil
+		}
+		return 0, err
+	}
+	if len(vol.Mountpoint) == 0 {
+		return 0, fmt.Errorf("no mountpoint of volume %s", volumeName)
+	}
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("volume", volumeName), filters.Arg("status", "running")),
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range containers {
+		if err := cli.ContainerPause(ctx, c.ID); err != nil {
+			return 0, err
+		}
+		defer cli.ContainerUnpause(context.Background(), c.ID)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(snapshotFile), 0700); err != nil {
+		return 0, err
+	}
+	tmpFile := snapshotFile + ".part"
+	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpFile)
+	tr, err := archive.Tar(vol.Mountpoint, archive.Uncompressed)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	size, err := io.Copy(f, io.LimitReader(tr, maxSize+1))
+	tr.Close()
+	if err == nil && size > maxSize {
+		err = errVolumeSnapshotTooBig
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return size, os.Rename(tmpFile, snapshotFile)
+}
+
+func restoreVolume(ctx context.Context, cli *client.Client, volumeName string, snapshotFile string) error {
+	f, err := os.Open(snapshotFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	vol, err := cli.VolumeInspect(ctx, volumeName)
+	if err != nil {
+		return err
+	}
+	if len(vol.Mountpoint) == 0 {
+		return fmt.Errorf("no mountpoint of volume %s", volumeName)
+	}
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("volume", volumeName), filters.Arg("status", "running")),
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if err := cli.ContainerStop(ctx, c.ID, container.StopOptions{}); err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(vol.Mountpoint)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(vol.Mountpoint, e.Name())); err != nil {
+			return err
+		}
+	}
+	return archive.Untar(f, vol.Mountpoint, &archive.TarOptions{})
+}
+
+func (u *runnerImpl) removeVolumeSnapshots() error {
+	u.VolumeSnapshots = nil
+	return os.RemoveAll(GetVolumeSnapshotsDir(u.config, u.ID))
+}
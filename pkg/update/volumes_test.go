@@ -0,0 +1,172 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	composetypes "github.com/compose-spec/compose-go/types"
+	"github.com/containerd/containerd/platforms"
+	"github.com/foundriesio/composeapp/pkg/compose"
+)
+
+type (
+	testApp struct {
+		compose.App
+		ref  *compose.AppRef
+		proj *composetypes.Project
+	}
+
+	testAppLoader struct {
+		apps map[string]*testApp
+	}
+)
+
+func (a *testApp) Name() string {
+	return a.ref.Name
+}
+
+func (a *testApp) Ref() *compose.AppRef {
+	return a.ref
+}
+
+func (a *testApp) GetCompose(ctx context.Context, provider compose.BlobProvider) (*composetypes.Project, error) {
+	return a.proj, nil
+}
+
+func (l *testAppLoader) LoadAppTree(ctx context.Context, provider compose.BlobProvider,
+	platform platforms.MatchComparer, appURI string) (compose.App, error) {
+	app, ok := l.apps[appURI]
+	if !ok {
+		return nil, compose.ErrAppNotFound
+	}
+	return app, nil
+}
+
+func TestListVolumeSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app_data" + volumeSnapshotExt, "app_cache" + volumeSnapshotExt, "unrelated.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("snapshot"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "dir"+volumeSnapshotExt), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	volumes, err := listVolumeSnapshots(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"app_cache", "app_data"}; !reflect.DeepEqual(volumes, expected) {
+		t.Errorf("expected volume snapshots %v, got %v", expected, volumes)
+	}
+
+	volumes, err = listVolumeSnapshots(filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 0 {
+		t.Errorf("expected no volume snapshots in a missing directory, got %v", volumes)
+	}
+}
+
+func TestPruneVolumeSnapshots(t *testing.T) {
+	cfg := &compose.Config{StoreRoot: t.TempDir()}
+	snapshots := map[string]string{
+		filepath.Join(GetVolumeSnapshotsDir(cfg, "current"), "app1", "app1_data"+volumeSnapshotExt):  "12345",
+		filepath.Join(GetVolumeSnapshotsDir(cfg, "current"), "app2", "app2_data"+volumeSnapshotExt):  "123",
+		filepath.Join(GetVolumeSnapshotsDir(cfg, "previous"), "app1", "app1_data"+volumeSnapshotExt): "1234567890",
+	}
+	for path, data := range snapshots {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	size, err := pruneVolumeSnapshots(cfg, "current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 8 {
+		t.Errorf("expected size of the current update snapshots 8, got %d", size)
+	}
+	if _, err := os.Stat(GetVolumeSnapshotsDir(cfg, "previous")); !os.IsNotExist(err) {
+		t.Errorf("snapshots of the previous update are not removed: %v", err)
+	}
+	if _, err := os.Stat(GetVolumeSnapshotsDir(cfg, "current")); err != nil {
+		t.Errorf("snapshots of the current update are removed: %v", err)
+	}
+
+	// No snapshots taken yet
+	cfg = &compose.Config{StoreRoot: t.TempDir()}
+	if size, err := pruneVolumeSnapshots(cfg, "current"); err != nil || size != 0 {
+		t.Errorf("expected no snapshots and no error, got size %d, error %v", size, err)
+	}
+}
+
+func TestGetProjectVolumeNames(t *testing.T) {
+	proj := &composetypes.Project{
+		Name: "app1",
+		Volumes: composetypes.Volumes{
+			"data":   {},
+			"cache":  {Name: "shared_cache"},
+			"shared": {Name: "shared", External: composetypes.External{External: true}},
+		},
+	}
+	if names, expected := getProjectVolumeNames(proj), []string{"app1_data", "shared_cache"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected volume names %v, got %v", expected, names)
+	}
+}
+
+func TestInstallChecksAppsBeforeVolumeSnapshots(t *testing.T) {
+	appURI := "hub.foundries.io/factory/app1@sha256:" + "7b0fbe4d0d8b1b7c6a3c4a2b5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f80"
+	ref, err := compose.ParseAppRef(appURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &compose.Config{
+		StoreRoot:   t.TempDir(),
+		ComposeRoot: t.TempDir(),
+		LocalRoot:   t.TempDir(),
+		AppLoader: &testAppLoader{apps: map[string]*testApp{
+			appURI: {ref: ref, proj: &composetypes.Project{
+				Name:     "app1",
+				Services: composetypes.Services{{Name: "srv", Privileged: true}},
+				Volumes:  composetypes.Volumes{"data": {}},
+			}},
+		}},
+		AppStoreFactoryFunc: func(c *compose.Config) (compose.AppStore, error) {
+			return nil, nil
+		},
+	}
+	if err := os.WriteFile(cfg.GetPolicyFile(), []byte("mode: enforce\nforbid_privileged: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The app is installed, so its volumes would be snapshotted
+	if err := os.MkdirAll(cfg.GetAppComposeDir("app1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	u := &runnerImpl{
+		Update: Update{ID: "update1", URIs: []string{appURI}, VolumeSnapshotMaxSize: 1 << 20},
+		config: cfg,
+	}
+	err = u.install(context.Background(), nil)
+	var policyErr *compose.ErrPolicyViolation
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected policy violation error, got %v", err)
+	}
+	if u.VolumeSnapshots != nil {
+		t.Errorf("volumes are snapshotted before the apps are checked: %v", u.VolumeSnapshots)
+	}
+	if _, err := os.Stat(GetVolumeSnapshotsDir(cfg, u.ID)); !os.IsNotExist(err) {
+		t.Errorf("volume snapshots directory is created before the apps are checked: %v", err)
+	}
+}